@@ -0,0 +1,569 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+const (
+	apiDefaultLimit = 50
+	apiMaxLimit     = 500
+)
+
+// routeAPI registers version 1 of the JSON API under /api/v1.
+func (srv *server) routeAPI() {
+	srv.mux.HandleFunc("GET /api/v1/me", srv.authenticated(srv.apiMe))
+	srv.mux.HandleFunc("GET /api/v1/users", srv.authenticated(srv.apiUsers))
+	srv.mux.HandleFunc("GET /api/v1/feeds", srv.authenticated(srv.apiFeeds))
+	srv.mux.HandleFunc("POST /api/v1/feeds", srv.authenticated(srv.apiAddFeed))
+	srv.mux.HandleFunc("GET /api/v1/follows", srv.authenticated(srv.apiFollows))
+	srv.mux.HandleFunc("POST /api/v1/follows", srv.authenticated(srv.apiFollow))
+	srv.mux.HandleFunc("DELETE /api/v1/follows/{feed_id}", srv.authenticated(srv.apiUnfollow))
+	srv.mux.HandleFunc("GET /api/v1/posts", srv.authenticated(srv.apiPosts))
+	srv.mux.HandleFunc("GET /api/v1/posts/{id}", srv.authenticated(srv.apiPost))
+	srv.mux.HandleFunc("PUT /api/v1/posts/{id}/read", srv.authenticated(srv.apiSetRead(true)))
+	srv.mux.HandleFunc("DELETE /api/v1/posts/{id}/read", srv.authenticated(srv.apiSetRead(false)))
+	srv.mux.HandleFunc("PUT /api/v1/posts/{id}/star", srv.authenticated(srv.apiSetStarred(true)))
+	srv.mux.HandleFunc("DELETE /api/v1/posts/{id}/star", srv.authenticated(srv.apiSetStarred(false)))
+
+	srv.mux.HandleFunc("/api/", srv.apiNotFound)
+}
+
+// apiMethods are the methods the API has endpoints for.
+var apiMethods = []string{"GET", "POST", "PUT", "DELETE"}
+
+// apiNotFound answers requests no endpoint matches. The catch-all /api/
+// pattern takes precedence over the mux's own 405 answer, so paths that
+// exist under another method are told apart here.
+func (srv *server) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	allowed := []string{}
+
+	for _, method := range apiMethods {
+		other := r.Clone(r.Context())
+		other.Method = method
+
+		if _, pattern := srv.mux.Handler(other); pattern != "/api/" {
+			allowed = append(allowed, method)
+		}
+	}
+
+	if len(allowed) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no endpoint %v %v", r.Method, r.URL.Path))
+		return
+	}
+
+	if slices.Contains(allowed, "GET") {
+		allowed = append(allowed, "HEAD")
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%v is not allowed on %v, use %v", r.Method, r.URL.Path, strings.Join(allowed, ", ")))
+}
+
+type apiUser struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiFeed struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	CreatedBy string    `json:"created_by,omitempty"`
+}
+
+type apiFollow struct {
+	FeedID  uuid.UUID `json:"feed_id"`
+	Name    string    `json:"name"`
+	Url     string    `json:"url"`
+	SiteUrl string    `json:"site_url,omitempty"`
+	Folder  string    `json:"folder,omitempty"`
+	Unread  int64     `json:"unread"`
+}
+
+type apiPost struct {
+	ID          int64      `json:"id"`
+	UUID        uuid.UUID  `json:"uuid"`
+	FeedID      uuid.UUID  `json:"feed_id"`
+	Feed        string     `json:"feed,omitempty"`
+	Title       string     `json:"title"`
+	Url         string     `json:"url"`
+	Author      string     `json:"author,omitempty"`
+	Categories  []string   `json:"categories"`
+	Description string     `json:"description"`
+	Content     string     `json:"content,omitempty"`
+	PublishedAt *time.Time `json:"published_at"`
+	ReadAt      *time.Time `json:"read_at"`
+	Starred     bool       `json:"starred"`
+	Hidden      bool       `json:"hidden"`
+	Tags        []string   `json:"tags"`
+}
+
+// apiList is the body of list responses. NextOffset is set when there may
+// be more items.
+type apiList[T any] struct {
+	Items      []T  `json:"items"`
+	Offset     int  `json:"offset,omitempty"`
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+func (srv *server) apiMe(w http.ResponseWriter, r *http.Request, user database.User) {
+	writeJSON(w, http.StatusOK, apiUser{
+		ID:        user.ID,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt,
+	})
+}
+
+func (srv *server) apiUsers(w http.ResponseWriter, r *http.Request, user database.User) {
+	users, err := srv.s.db.GetUsers(r.Context())
+
+	if err != nil {
+		writeInternalError(w, "could not get users", err)
+		return
+	}
+
+	list := apiList[apiUser]{Items: make([]apiUser, 0, len(users))}
+
+	for _, u := range users {
+		list.Items = append(list.Items, apiUser{
+			ID:        u.ID,
+			Name:      u.Name,
+			CreatedAt: u.CreatedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (srv *server) apiFeeds(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, err := srv.s.db.GetFeeds(r.Context())
+
+	if err != nil {
+		writeInternalError(w, "could not get feeds", err)
+		return
+	}
+
+	list := apiList[apiFeed]{Items: make([]apiFeed, 0, len(feeds))}
+
+	for _, feed := range feeds {
+		list.Items = append(list.Items, apiFeed{
+			ID:        feed.ID,
+			Name:      feed.FeedName,
+			Url:       feed.Url,
+			CreatedBy: feed.UserName,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (srv *server) apiAddFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		Name        string `json:"name"`
+		Url         string `json:"url"`
+		FullContent bool   `json:"full_content"`
+	}
+
+	err := readJSON(w, r, &body)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if strings.TrimSpace(body.Name) == "" || strings.TrimSpace(body.Url) == "" {
+		writeError(w, http.StatusBadRequest, "name and url are required")
+		return
+	}
+
+	feed, err := addFeed(r.Context(), srv.s, user, body.Name, body.Url, body.FullContent)
+
+	if errors.Is(err, errFeedExists) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err != nil {
+		writeInternalError(w, "could not add feed", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, apiFeed{
+		ID:        feed.ID,
+		Name:      feed.Name,
+		Url:       feed.Url,
+		CreatedBy: user.Name,
+	})
+}
+
+func (srv *server) apiFollows(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID := uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	}
+
+	feeds, err := srv.s.db.GetFollowedFeedsForUser(r.Context(), userID)
+
+	if err != nil {
+		writeInternalError(w, "could not get followed feeds", err)
+		return
+	}
+
+	counts, err := srv.s.db.GetUnreadCountsForUser(r.Context(), userID)
+
+	if err != nil {
+		writeInternalError(w, "could not get unread counts", err)
+		return
+	}
+
+	unread := make(map[uuid.UUID]int64, len(counts))
+
+	for _, count := range counts {
+		unread[count.FeedID] = count.Unread
+	}
+
+	list := apiList[apiFollow]{Items: make([]apiFollow, 0, len(feeds))}
+
+	for _, feed := range feeds {
+		list.Items = append(list.Items, apiFollow{
+			FeedID:  feed.ID,
+			Name:    feed.Name,
+			Url:     feed.Url,
+			SiteUrl: feed.SiteUrl.String,
+			Folder:  feed.Folder.String,
+			Unread:  unread[feed.ID],
+		})
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (srv *server) apiFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		Url string `json:"url"`
+	}
+
+	err := readJSON(w, r, &body)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	feed, _, err := followFeed(r.Context(), srv.s, user, body.Url)
+
+	switch {
+	case errors.Is(err, errFeedNotFound):
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no feed with url %v, add it first", body.Url))
+		return
+	case errors.Is(err, errAlreadyFollowing):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeInternalError(w, "could not follow feed", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, apiFollow{
+		FeedID: feed.ID,
+		Name:   feed.FeedName,
+		Url:    feed.Url,
+	})
+}
+
+func (srv *server) apiUnfollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feed_id"))
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+
+	removed, err := srv.s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+		FeedID: uuid.NullUUID{
+			UUID:  feedID,
+			Valid: true,
+		},
+	})
+
+	if err != nil {
+		writeInternalError(w, "could not unfollow feed", err)
+		return
+	}
+
+	if removed == 0 {
+		writeError(w, http.StatusNotFound, "not following this feed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiPosts lists the posts of followed feeds, newest first. It takes limit
+// and offset for paging and feed, unread, starred, tag and hidden filters.
+func (srv *server) apiPosts(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+	params := database.ListPostsForUserParams{
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+	}
+
+	limit, err := queryInt(query.Get("limit"), apiDefaultLimit)
+
+	if err != nil || limit < 1 || limit > apiMaxLimit {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", apiMaxLimit))
+		return
+	}
+
+	offset, err := queryInt(query.Get("offset"), 0)
+
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must be a positive number")
+		return
+	}
+
+	if query.Get("feed") != "" {
+		feedID, err := uuid.Parse(query.Get("feed"))
+
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid feed id")
+			return
+		}
+
+		params.FeedID = uuid.NullUUID{
+			UUID:  feedID,
+			Valid: true,
+		}
+	}
+
+	for name, filter := range map[string]*bool{
+		"unread":  &params.UnreadOnly,
+		"starred": &params.StarredOnly,
+		"hidden":  &params.IncludeHidden,
+	} {
+		if query.Get(name) == "" {
+			continue
+		}
+
+		*filter, err = strconv.ParseBool(query.Get(name))
+
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%v must be true or false", name))
+			return
+		}
+	}
+
+	if query.Get("tag") != "" {
+		params.Tag = sql.NullString{
+			String: query.Get("tag"),
+			Valid:  true,
+		}
+	}
+
+	params.MaxPosts = int32(limit)
+	params.SkipPosts = int32(offset)
+
+	posts, err := srv.s.db.ListPostsForUser(r.Context(), params)
+
+	if err != nil {
+		writeInternalError(w, "could not get posts", err)
+		return
+	}
+
+	list := apiList[apiPost]{
+		Items:  make([]apiPost, 0, len(posts)),
+		Offset: offset,
+	}
+
+	for _, post := range posts {
+		list.Items = append(list.Items, apiPost{
+			ID:          post.Seq,
+			UUID:        post.ID,
+			FeedID:      post.FeedID,
+			Feed:        post.FeedName,
+			Title:       post.Title,
+			Url:         post.Url,
+			Author:      post.Author.String,
+			Categories:  nonNil(post.Categories),
+			Description: post.Description.String,
+			PublishedAt: nullTime(post.PublishedAt),
+			ReadAt:      nullTime(post.ReadAt),
+			Starred:     post.Starred,
+			Hidden:      post.Hidden,
+			Tags:        nonNil(post.Tags),
+		})
+	}
+
+	if len(posts) == limit {
+		next := offset + limit
+		list.NextOffset = &next
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (srv *server) apiPost(w http.ResponseWriter, r *http.Request, user database.User) {
+	post, ok := srv.apiLookupPost(w, r, user)
+
+	if !ok {
+		return
+	}
+
+	postState, err := srv.s.db.GetPostState(r.Context(), database.GetPostStateParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		writeInternalError(w, "could not get post state", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiPost{
+		ID:          post.Seq,
+		UUID:        post.ID,
+		FeedID:      post.FeedID,
+		Feed:        post.FeedName,
+		Title:       post.Title,
+		Url:         post.Url,
+		Author:      post.Author.String,
+		Categories:  nonNil(post.Categories),
+		Description: post.Description.String,
+		Content:     post.Content.String,
+		PublishedAt: nullTime(post.PublishedAt),
+		ReadAt:      nullTime(postState.ReadAt),
+		Starred:     postState.Starred,
+		Hidden:      postState.Hidden,
+		Tags:        nonNil(postState.Tags),
+	})
+}
+
+func (srv *server) apiSetRead(read bool) func(http.ResponseWriter, *http.Request, database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		post, ok := srv.apiLookupPost(w, r, user)
+
+		if !ok {
+			return
+		}
+
+		var err error
+
+		if read {
+			err = srv.s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+				ID:     uuid.New(),
+				UserID: user.ID,
+				PostID: post.ID,
+			})
+		} else {
+			err = srv.s.db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{
+				UserID: user.ID,
+				PostID: post.ID,
+			})
+		}
+
+		if err != nil {
+			writeInternalError(w, "could not update read state", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (srv *server) apiSetStarred(starred bool) func(http.ResponseWriter, *http.Request, database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		post, ok := srv.apiLookupPost(w, r, user)
+
+		if !ok {
+			return
+		}
+
+		err := srv.s.db.SetPostStarred(r.Context(), database.SetPostStarredParams{
+			ID:      uuid.New(),
+			UserID:  user.ID,
+			PostID:  post.ID,
+			Starred: starred,
+		})
+
+		if err != nil {
+			writeInternalError(w, "could not update starred state", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// apiLookupPost finds the post named by the {id} path value, the short id
+// browse shows, among the feeds user follows and answers with an error when
+// there is none.
+func (srv *server) apiLookupPost(w http.ResponseWriter, r *http.Request, user database.User) (database.GetPostBySeqForUserRow, bool) {
+	seq, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid post id")
+		return database.GetPostBySeqForUserRow{}, false
+	}
+
+	post, err := srv.s.db.GetPostBySeqForUser(r.Context(), database.GetPostBySeqForUserParams{
+		Seq: seq,
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no post with id %v", seq))
+		return post, false
+	}
+
+	if err != nil {
+		writeInternalError(w, "could not get post", err)
+		return post, false
+	}
+
+	return post, true
+}
+
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+// nonNil makes empty lists encode as [] rather than null.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const apiTestToken = "gator_test_token"
+
+// apiTestUserID is the id of alice, the user apiTestToken belongs to.
+var apiTestUserID = uuid.New()
+
+var userColumns = []string{"id", "created_at", "updated_at", "name", "password_hash", "fever_api_key"}
+
+var postForUserColumns = []string{
+	"id", "created_at", "updated_at", "title", "url", "description", "published_at", "feed_id", "seq",
+	"content", "simhash", "cluster_id", "author", "categories", "source_url", "feed_name", "read_at",
+	"starred", "hidden", "tags",
+}
+
+var postBySeqColumns = postForUserColumns[:16]
+
+// newAPITestServer returns a server whose database knows the user alice,
+// authenticated by apiTestToken.
+func newAPITestServer(t *testing.T) (*httptest.Server, *fakeDB) {
+	db := newFakeDB(t)
+
+	db.on("GetUserByApiToken", func(args []driver.Value) (fakeRows, error) {
+		if args[0] != hashToken(apiTestToken) {
+			return fakeRows{}, nil
+		}
+
+		return oneRow(userColumns, apiTestUserID.String(), time.Now(), time.Now(), "alice", nil, nil)(args)
+	})
+
+	srv := httptest.NewServer(newServer(db.state("alice")))
+	t.Cleanup(srv.Close)
+
+	return srv, db
+}
+
+// apiRequest sends a request with the test token, unless token is empty,
+// and decodes the JSON answer into v when it is not nil.
+func apiRequest(t *testing.T, srv *httptest.Server, method, path, token, body string, v any) *http.Response {
+	request, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := srv.Client().Do(request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	if v != nil {
+		err = json.NewDecoder(resp.Body).Decode(v)
+
+		if err != nil {
+			t.Fatalf("%v %v: could not decode answer %v", method, path, err)
+		}
+	}
+
+	return resp
+}
+
+func TestAPIAuthentication(t *testing.T) {
+	srv, _ := newAPITestServer(t)
+
+	tests := []struct {
+		name          string
+		token         string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{name: "missing token", wantStatus: 401, wantChallenge: `Bearer realm="gator"`},
+		{name: "unknown token", token: "gator_other", wantStatus: 401, wantChallenge: `Bearer realm="gator", error="invalid_token"`},
+		{name: "valid token", token: apiTestToken, wantStatus: 200},
+	}
+
+	for _, test := range tests {
+		var body map[string]any
+
+		resp := apiRequest(t, srv, "GET", "/api/v1/me", test.token, "", &body)
+
+		if resp.StatusCode != test.wantStatus {
+			t.Errorf("%v: status = %d, want %d", test.name, resp.StatusCode, test.wantStatus)
+		}
+
+		if got := resp.Header.Get("WWW-Authenticate"); got != test.wantChallenge {
+			t.Errorf("%v: WWW-Authenticate = %q, want %q", test.name, got, test.wantChallenge)
+		}
+
+		if test.wantStatus == 200 && body["name"] != "alice" {
+			t.Errorf("%v: answer = %v, want alice", test.name, body)
+		}
+
+		if test.wantStatus == 401 && body["error"] == nil {
+			t.Errorf("%v: answer = %v, want an error", test.name, body)
+		}
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	srv, db := newAPITestServer(t)
+
+	db.on("GetPostBySeqForUser", noRows)
+
+	tests := []struct {
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantAllow  string
+	}{
+		{method: "GET", path: "/api/v1/nothing", wantStatus: 404, wantCode: "not_found"},
+		{method: "PATCH", path: "/api/v1/me", wantStatus: 405, wantCode: "method_not_allowed", wantAllow: "GET, HEAD"},
+		{method: "POST", path: "/api/v1/posts/7/read", wantStatus: 405, wantCode: "method_not_allowed", wantAllow: "PUT, DELETE"},
+		{method: "PUT", path: "/api/v1/feeds", wantStatus: 405, wantCode: "method_not_allowed", wantAllow: "GET, POST, HEAD"},
+		{method: "GET", path: "/api/v1/posts/seven", wantStatus: 400, wantCode: "bad_request"},
+		{method: "GET", path: "/api/v1/posts/7", wantStatus: 404, wantCode: "not_found"},
+		{method: "GET", path: "/api/v1/posts?limit=0", wantStatus: 400, wantCode: "bad_request"},
+		{method: "GET", path: "/api/v1/posts?limit=501", wantStatus: 400, wantCode: "bad_request"},
+		{method: "GET", path: "/api/v1/posts?offset=-1", wantStatus: 400, wantCode: "bad_request"},
+		{method: "GET", path: "/api/v1/posts?unread=maybe", wantStatus: 400, wantCode: "bad_request"},
+		{method: "DELETE", path: "/api/v1/follows/not-a-uuid", wantStatus: 400, wantCode: "bad_request"},
+		{method: "POST", path: "/api/v1/feeds", body: `{"name": "Go"}`, wantStatus: 400, wantCode: "bad_request"},
+		{method: "POST", path: "/api/v1/feeds", body: `{"name": "Go", "link": "https://go.dev"}`, wantStatus: 400, wantCode: "bad_request"},
+	}
+
+	for _, test := range tests {
+		var body apiError
+
+		resp := apiRequest(t, srv, test.method, test.path, apiTestToken, test.body, &body)
+
+		if resp.StatusCode != test.wantStatus || body.Error.Status != test.wantStatus {
+			t.Errorf("%v %v: status = %d with body %d, want %d", test.method, test.path, resp.StatusCode, body.Error.Status, test.wantStatus)
+		}
+
+		if body.Error.Code != test.wantCode {
+			t.Errorf("%v %v: code = %v, want %v", test.method, test.path, body.Error.Code, test.wantCode)
+		}
+
+		if got := resp.Header.Get("Allow"); got != test.wantAllow {
+			t.Errorf("%v %v: Allow = %q, want %q", test.method, test.path, got, test.wantAllow)
+		}
+	}
+}
+
+func TestAPIPostsPagination(t *testing.T) {
+	srv, db := newAPITestServer(t)
+
+	// The user has 5 posts, with seqs 5 down to 1.
+	db.on("ListPostsForUser", func(args []driver.Value) (fakeRows, error) {
+		limit, offset := int64(args[6].(int32)), int64(args[7].(int32))
+		rows := fakeRows{columns: postForUserColumns}
+
+		for seq := 5 - offset; seq > 0 && len(rows.rows) < int(limit); seq-- {
+			rows.rows = append(rows.rows, []driver.Value{
+				uuid.NewString(), time.Now(), time.Now(), fmt.Sprintf("Post %d", seq), fmt.Sprintf("https://go.dev/blog/%d", seq),
+				"", nil, uuid.NewString(), seq, nil, nil, nil, nil, []byte("{go,release}"), nil, "Go Blog", nil,
+				false, false, []byte("{}"),
+			})
+		}
+
+		return rows, nil
+	})
+
+	tests := []struct {
+		query          string
+		wantSeqs       []int64
+		wantNextOffset *int
+	}{
+		{query: "limit=2", wantSeqs: []int64{5, 4}, wantNextOffset: intPointer(2)},
+		{query: "limit=2&offset=2", wantSeqs: []int64{3, 2}, wantNextOffset: intPointer(4)},
+		{query: "limit=2&offset=4", wantSeqs: []int64{1}},
+		{query: "", wantSeqs: []int64{5, 4, 3, 2, 1}},
+	}
+
+	for _, test := range tests {
+		var list apiList[apiPost]
+
+		resp := apiRequest(t, srv, "GET", "/api/v1/posts?"+test.query, apiTestToken, "", &list)
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("%v: status = %d, want 200", test.query, resp.StatusCode)
+		}
+
+		seqs := []int64{}
+
+		for _, post := range list.Items {
+			seqs = append(seqs, post.ID)
+		}
+
+		if fmt.Sprint(seqs) != fmt.Sprint(test.wantSeqs) {
+			t.Errorf("%v: posts = %v, want %v", test.query, seqs, test.wantSeqs)
+		}
+
+		if (list.NextOffset == nil) != (test.wantNextOffset == nil) || (list.NextOffset != nil && *list.NextOffset != *test.wantNextOffset) {
+			t.Errorf("%v: next_offset = %v, want %v", test.query, list.NextOffset, test.wantNextOffset)
+		}
+
+		if len(list.Items) > 0 && strings.Join(list.Items[0].Categories, ",") != "go,release" {
+			t.Errorf("%v: categories = %v, want go,release", test.query, list.Items[0].Categories)
+		}
+	}
+
+	// The default limit is sent when none is given.
+	calls := db.called("ListPostsForUser")
+
+	if limit := calls[len(calls)-1][6]; limit != int32(apiDefaultLimit) {
+		t.Errorf("default limit = %v, want %d", limit, apiDefaultLimit)
+	}
+}
+
+func TestAPIPostReadState(t *testing.T) {
+	srv, db := newAPITestServer(t)
+	postID := uuid.New()
+
+	// Post 7 is from a feed alice follows, post 8 from one she does not.
+	db.on("GetPostBySeqForUser", func(args []driver.Value) (fakeRows, error) {
+		if args[0] != int64(7) || args[1] != apiTestUserID.String() {
+			return fakeRows{}, nil
+		}
+
+		return oneRow(postBySeqColumns,
+			postID.String(), time.Now(), time.Now(), "Go 1.23", "https://go.dev/blog/go1.23", "", nil,
+			uuid.NewString(), int64(7), nil, nil, nil, nil, []byte("{}"), nil, "Go Blog")(args)
+	})
+	db.on("MarkPostRead", noRows)
+	db.on("MarkPostUnread", noRows)
+
+	if resp := apiRequest(t, srv, "PUT", "/api/v1/posts/7/read", apiTestToken, "", nil); resp.StatusCode != 204 {
+		t.Errorf("PUT read: status = %d, want 204", resp.StatusCode)
+	}
+
+	if resp := apiRequest(t, srv, "DELETE", "/api/v1/posts/7/read", apiTestToken, "", nil); resp.StatusCode != 204 {
+		t.Errorf("DELETE read: status = %d, want 204", resp.StatusCode)
+	}
+
+	for _, name := range []string{"MarkPostRead", "MarkPostUnread"} {
+		calls := db.called(name)
+
+		if len(calls) != 1 || !containsValue(calls[0], postID.String()) {
+			t.Errorf("%v calls = %v, want one for post %v", name, calls, postID)
+		}
+	}
+}
+
+func TestAPIPostFromUnfollowedFeed(t *testing.T) {
+	srv, db := newAPITestServer(t)
+
+	// The post exists, but in a feed alice does not follow, so the lookup
+	// scoped to her follows finds nothing.
+	db.on("GetPostBySeqForUser", noRows)
+
+	for _, method := range []string{"GET", "PUT"} {
+		path := "/api/v1/posts/8"
+
+		if method == "PUT" {
+			path += "/read"
+		}
+
+		var body apiError
+
+		resp := apiRequest(t, srv, method, path, apiTestToken, "", &body)
+
+		if resp.StatusCode != 404 || body.Error.Code != "not_found" {
+			t.Errorf("%v %v: status = %d with code %v, want 404 not_found", method, path, resp.StatusCode, body.Error.Code)
+		}
+	}
+
+	for _, call := range db.called("GetPostBySeqForUser") {
+		if call[0] != int64(8) || call[1] != apiTestUserID.String() {
+			t.Errorf("GetPostBySeqForUser args = %v, want post 8 for alice", call)
+		}
+	}
+
+	if calls := db.called("MarkPostRead"); len(calls) != 0 {
+		t.Errorf("MarkPostRead calls = %v, want none", calls)
+	}
+}
+
+func intPointer(i int) *int {
+	return &i
+}
+
+func containsValue(values []driver.Value, want driver.Value) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+
+	return false
+}
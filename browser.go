@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// openBrowser opens link with the first command in $BROWSER, replacing %s
+// with the link when present, or with the platform's default opener. Links
+// come from feeds, so only http and https links are opened, never files,
+// scripts or the handlers of other protocols.
+func openBrowser(link string) error {
+	parsed, err := url.Parse(link)
+
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("refusing to open %q, expecting an http or https url", link)
+	}
+
+	var name string
+	var args []string
+
+	browser := strings.Split(os.Getenv("BROWSER"), ":")[0]
+
+	switch {
+	case browser != "":
+		fields := strings.Fields(browser)
+		name = fields[0]
+
+		substituted := false
+
+		for _, field := range fields[1:] {
+			if strings.Contains(field, "%s") {
+				field = strings.ReplaceAll(field, "%s", link)
+				substituted = true
+			}
+
+			args = append(args, field)
+		}
+
+		if !substituted {
+			args = append(args, link)
+		}
+	case runtime.GOOS == "darwin":
+		name, args = "open", []string{link}
+	case runtime.GOOS == "windows":
+		name, args = "rundll32", []string{"url.dll,FileProtocolHandler", link}
+	default:
+		name, args = "xdg-open", []string{link}
+	}
+
+	cmd := exec.Command(name, args...)
+
+	err = cmd.Start()
+
+	if err != nil {
+		return err
+	}
+
+	go cmd.Wait()
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/cluster"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+// clusterWindow is how far back a new post looks for the same story in
+// other feeds.
+const clusterWindow = 72 * time.Hour
+
+// clusterPost fingerprints a new post and puts it in the story cluster of a
+// matching recent post from another feed, or in a cluster of its own.
+func clusterPost(ctx context.Context, s *state, post database.Post) error {
+	fingerprint := cluster.Fingerprint(post.Title, post.Description.String)
+
+	rows, err := s.db.GetClusterCandidates(ctx, database.GetClusterCandidatesParams{
+		FeedID:    post.FeedID,
+		CreatedAt: time.Now().Add(-clusterWindow),
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not get cluster candidates %v", err)
+	}
+
+	candidates := make([]cluster.Candidate, 0, len(rows))
+
+	for _, row := range rows {
+		clusterID := row.ID
+
+		if row.ClusterID.Valid {
+			clusterID = row.ClusterID.UUID
+		}
+
+		candidates = append(candidates, cluster.Candidate{
+			ID:          row.ID,
+			ClusterID:   clusterID,
+			FeedID:      row.FeedID,
+			Url:         row.Url,
+			Fingerprint: uint64(row.Simhash.Int64),
+		})
+	}
+
+	clusterID, found := cluster.Match(post.FeedID, post.Url, fingerprint, candidates)
+
+	if !found {
+		clusterID = post.ID
+	}
+
+	err = s.db.SetPostCluster(ctx, database.SetPostClusterParams{
+		ID: post.ID,
+		Simhash: sql.NullInt64{
+			Int64: int64(fingerprint),
+			Valid: true,
+		},
+		ClusterID: uuid.NullUUID{
+			UUID:  clusterID,
+			Valid: true,
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not save post cluster %v", err)
+	}
+
+	return nil
+}
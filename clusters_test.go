@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+var clusterCandidateColumns = []string{"id", "feed_id", "url", "simhash", "cluster_id"}
+
+func TestClusterPost(t *testing.T) {
+	post := database.Post{
+		ID:     uuid.New(),
+		FeedID: uuid.New(),
+		Title:  "Go 1.23 is released",
+		Url:    "https://go.dev/blog/go1.23",
+	}
+
+	sameUrl := uuid.New()
+	unrelated := uuid.New()
+
+	tests := []struct {
+		name        string
+		candidates  [][]driver.Value
+		wantCluster uuid.UUID
+	}{
+		{
+			name:        "no candidates",
+			wantCluster: post.ID,
+		},
+		{
+			name: "canonical url",
+			candidates: [][]driver.Value{
+				{uuid.NewString(), uuid.NewString(), "https://go.dev/blog/go1.22", int64(0), unrelated.String()},
+				{uuid.NewString(), uuid.NewString(), post.Url, int64(0), sameUrl.String()},
+			},
+			wantCluster: sameUrl,
+		},
+	}
+
+	for _, test := range tests {
+		db := newFakeDB(t)
+		db.on("GetClusterCandidates", func(args []driver.Value) (fakeRows, error) {
+			return fakeRows{columns: clusterCandidateColumns, rows: test.candidates}, nil
+		})
+		db.on("SetPostCluster", noRows)
+
+		start := time.Now()
+
+		err := clusterPost(context.Background(), db.state("alice"), post)
+
+		if err != nil {
+			t.Fatalf("%v: clusterPost: %v", test.name, err)
+		}
+
+		// Only posts of other feeds from the last 72 hours are candidates.
+		args := db.called("GetClusterCandidates")[0]
+
+		if args[0] != post.FeedID.String() {
+			t.Errorf("%v: candidates left out feed %v, want %v", test.name, args[0], post.FeedID)
+		}
+
+		since := args[1].(time.Time)
+
+		if since.Before(start.Add(-clusterWindow)) || since.After(time.Now().Add(-clusterWindow)) {
+			t.Errorf("%v: candidates since %v, want %v ago", test.name, since, clusterWindow)
+		}
+
+		set := db.called("SetPostCluster")
+
+		if len(set) != 1 || set[0][0] != post.ID.String() || set[0][2] != test.wantCluster.String() {
+			t.Errorf("%v: SetPostCluster calls = %v, want post %v in cluster %v", test.name, set, post.ID, test.wantCluster)
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mambo-dev/gator/internal/output"
+)
+
+type command struct {
+	ctx       context.Context
+	name      string
+	arguments []string
+	flags     *flag.FlagSet
+	// out is where the command's result is written, for commands that
+	// print something other than a result.
+	out io.Writer
+}
+
+// commandSpec declares a command: how it is invoked, the positional
+// arguments it takes and its flags. Arguments are written as they appear in
+// the usage line: <required>, [optional] and a trailing ... for variadic.
+// complete returns shell completion candidates for the positional argument at
+// position, and rawArgs passes every argument through without flag parsing.
+type commandSpec struct {
+	name        string
+	description string
+	args        []string
+	flags       func(*flag.FlagSet)
+	handler     func(*state, command) (any, error)
+	complete    func(ctx context.Context, s *state, position int) []string
+	subcommands []*commandSpec
+	hidden      bool
+	rawArgs     bool
+}
+
+type commands struct {
+	commands map[string]*commandSpec
+}
+
+func newCommands() *commands {
+	return &commands{
+		commands: make(map[string]*commandSpec),
+	}
+}
+
+func (c *commands) register(spec *commandSpec) {
+
+	_, ok := c.commands[spec.name]
+
+	if ok {
+		panic(fmt.Sprintf("command %v already registered", spec.name))
+	}
+
+	c.commands[spec.name] = spec
+
+}
+
+// visible returns the commands shown in help and completion, sorted by name.
+func (c *commands) visible() []*commandSpec {
+	specs := []*commandSpec{}
+
+	for _, spec := range c.commands {
+		if !spec.hidden {
+			specs = append(specs, spec)
+		}
+	}
+
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].name < specs[j].name
+	})
+
+	return specs
+}
+
+// resolve finds the command named by args, descending into subcommands, and
+// returns it with its full name and the remaining arguments.
+func (c *commands) resolve(args []string) (*commandSpec, string, []string, error) {
+	if len(args) < 1 {
+		return nil, "", nil, errors.New("expecting a command")
+	}
+
+	spec, ok := c.commands[args[0]]
+
+	if !ok {
+		return nil, "", nil, unknownCommandError("", args[0], commandNames(c.visible()))
+	}
+
+	name := spec.name
+	args = args[1:]
+
+	for len(spec.subcommands) > 0 {
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			break
+		}
+
+		sub := spec.subcommand(args[0])
+
+		if sub == nil {
+			if spec.handler != nil && len(spec.args) > 0 {
+				break
+			}
+
+			return nil, "", nil, unknownCommandError(name, args[0], commandNames(spec.subcommands))
+		}
+
+		spec = sub
+		name += " " + sub.name
+		args = args[1:]
+	}
+
+	return spec, name, args, nil
+}
+
+func (spec *commandSpec) subcommand(name string) *commandSpec {
+	for _, sub := range spec.subcommands {
+		if sub.name == name {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// run runs the command named by args and renders its result to w.
+func (c *commands) run(ctx context.Context, s *state, args []string, w io.Writer) error {
+	spec, name, arguments, err := c.resolve(args)
+
+	if err != nil {
+		return err
+	}
+
+	flags := spec.flagSet(name)
+	positional := arguments
+
+	if !spec.rawArgs {
+		positional, err = parseFlags(flags, arguments)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return spec.printHelp(w, name)
+	}
+
+	if err != nil {
+		message, unknown := strings.CutPrefix(err.Error(), "flag provided but not defined: -")
+
+		if unknown {
+			message = "unknown flag --" + message
+		}
+
+		return fmt.Errorf("%v\nrun \"gator %v --help\" for usage", message, name)
+	}
+
+	if spec.handler == nil {
+		return fmt.Errorf("%v expects a subcommand: %v", name, strings.Join(commandNames(spec.subcommands), ", "))
+	}
+
+	err = spec.checkArgs(name, positional)
+
+	if err != nil {
+		return err
+	}
+
+	format, err := output.ParseFormat(flags.Lookup("output").Value.String())
+
+	if err != nil {
+		return err
+	}
+
+	result, err := spec.handler(s, command{
+		ctx:       ctx,
+		name:      name,
+		arguments: positional,
+		flags:     flags,
+		out:       w,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return output.Render(w, format, result)
+}
+
+// flagSet builds the flags of a command together with the global ones.
+func (spec *commandSpec) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {}
+
+	flags.String("output", string(output.Text), "`format` of the output: text, json, ndjson, csv or table, -o for short")
+	flags.String("o", string(output.Text), "shorthand for --output")
+
+	if spec.flags != nil {
+		spec.flags(flags)
+	}
+
+	return flags
+}
+
+// parseFlags parses flags wherever they appear between the positional
+// arguments; everything after a bare -- is positional.
+func parseFlags(flags *flag.FlagSet, arguments []string) ([]string, error) {
+	positional := []string{}
+	rest := []string{}
+
+	for i, argument := range arguments {
+		if argument == "--" {
+			rest = arguments[i+1:]
+			arguments = arguments[:i]
+			break
+		}
+	}
+
+	for {
+		err := flags.Parse(arguments)
+
+		if err != nil {
+			return nil, err
+		}
+
+		arguments = flags.Args()
+
+		if len(arguments) == 0 {
+			break
+		}
+
+		positional = append(positional, arguments[0])
+		arguments = arguments[1:]
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "o" {
+			flags.Set("output", f.Value.String())
+		}
+	})
+
+	return append(positional, rest...), nil
+}
+
+func (spec *commandSpec) checkArgs(name string, arguments []string) error {
+	required := 0
+	variadic := false
+
+	for _, arg := range spec.args {
+		if strings.HasPrefix(arg, "<") {
+			required++
+		}
+
+		if strings.HasSuffix(arg, "...") {
+			variadic = true
+		}
+	}
+
+	if len(arguments) < required || (!variadic && len(arguments) > len(spec.args)) {
+		return fmt.Errorf("usage: %v", spec.usage(name))
+	}
+
+	return nil
+}
+
+func (spec *commandSpec) usage(name string) string {
+	parts := []string{"gator", name}
+
+	if len(spec.subcommands) > 0 {
+		parts = append(parts, "<subcommand>")
+	}
+
+	parts = append(parts, spec.args...)
+	parts = append(parts, "[flags]")
+
+	return strings.Join(parts, " ")
+}
+
+func (spec *commandSpec) printHelp(w io.Writer, name string) error {
+	fmt.Fprintf(w, "Usage: %v\n\n%v\n", spec.usage(name), spec.description)
+
+	if len(spec.subcommands) > 0 {
+		fmt.Fprintln(w, "\nSubcommands:")
+		printCommandList(w, spec.subcommands)
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	if spec.flags != nil {
+		spec.flags(flags)
+	}
+
+	printFlags(w, "Flags", flags)
+	printFlags(w, "Global flags", (&commandSpec{}).flagSet(name))
+
+	return nil
+}
+
+func printCommandList(w io.Writer, specs []*commandSpec) {
+	width := 0
+
+	for _, spec := range specs {
+		width = max(width, len(spec.name))
+	}
+
+	for _, spec := range specs {
+		if !spec.hidden {
+			fmt.Fprintf(w, "  %-*v  %v\n", width, spec.name, spec.description)
+		}
+	}
+}
+
+func printFlags(w io.Writer, title string, flags *flag.FlagSet) {
+	lines := []string{}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) == 1 {
+			return
+		}
+
+		typeName, usage := flag.UnquoteUsage(f)
+		line := "  --" + f.Name
+
+		if typeName != "" {
+			line += " " + typeName
+		}
+
+		line += "\n      " + usage
+
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			line += fmt.Sprintf(" (default %v)", f.DefValue)
+		}
+
+		lines = append(lines, line)
+	})
+
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%v:\n%v\n", title, strings.Join(lines, "\n"))
+}
+
+func (c *commands) printHelp(w io.Writer) {
+	fmt.Fprint(w, "gator aggregates RSS feeds into Postgres.\n\nUsage: gator <command> [arguments] [flags]\n\nCommands:\n")
+	printCommandList(w, c.visible())
+	printFlags(w, "Global flags", (&commandSpec{}).flagSet("gator"))
+	fmt.Fprintln(w, "\nRun \"gator help <command>\" for more information about a command.")
+}
+
+func handlerHelp(c *commands) func(*state, command) (any, error) {
+	return func(s *state, cmd command) (any, error) {
+		if len(cmd.arguments) == 0 {
+			c.printHelp(cmd.out)
+			return nil, nil
+		}
+
+		spec, name, rest, err := c.resolve(cmd.arguments)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rest) > 0 {
+			return nil, unknownCommandError(name, rest[0], commandNames(spec.subcommands))
+		}
+
+		return nil, spec.printHelp(cmd.out, name)
+	}
+}
+
+func (c command) flag(name string) any {
+	return c.flags.Lookup(name).Value.(flag.Getter).Get()
+}
+
+func (c command) boolFlag(name string) bool {
+	return c.flag(name).(bool)
+}
+
+func (c command) stringFlag(name string) string {
+	return c.flag(name).(string)
+}
+
+func (c command) intFlag(name string) int {
+	return c.flag(name).(int)
+}
+
+func (c command) durationFlag(name string) time.Duration {
+	return c.flag(name).(time.Duration)
+}
+
+// isSet reports whether the flag was given on the command line.
+func (c command) isSet(name string) bool {
+	set := false
+
+	c.flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+func commandNames(specs []*commandSpec) []string {
+	names := []string{}
+
+	for _, spec := range specs {
+		if !spec.hidden {
+			names = append(names, spec.name)
+		}
+	}
+
+	return names
+}
+
+// unknownCommandError reports a mistyped command, or subcommand of parent,
+// suggesting the closest candidates.
+func unknownCommandError(parent, name string, candidates []string) error {
+	full := strings.TrimSpace(parent + " " + name)
+	suggestions := []string{}
+
+	for _, candidate := range suggest(name, candidates) {
+		suggestions = append(suggestions, fmt.Sprintf("%q", strings.TrimSpace(parent+" "+candidate)))
+	}
+
+	if len(suggestions) == 0 {
+		return fmt.Errorf("unknown command %q\nrun \"%v\" for a list of commands", full, strings.TrimSpace("gator help "+parent))
+	}
+
+	return fmt.Errorf("unknown command %q, did you mean %v?", full, strings.Join(suggestions, " or "))
+}
+
+// suggest returns the candidates within a small edit distance of name, or
+// starting with it.
+func suggest(name string, candidates []string) []string {
+	suggestions := []string{}
+
+	for _, candidate := range candidates {
+		limit := max(1, len(candidate)/3)
+
+		if levenshtein(name, candidate) <= limit || strings.HasPrefix(candidate, name) {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+
+	return suggestions
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
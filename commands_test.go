@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHelpWritesToRunWriter(t *testing.T) {
+	c := newCommands()
+	c.register(&commandSpec{
+		name:        "feeds",
+		description: "List all feeds.",
+		handler: func(s *state, cmd command) (any, error) {
+			return nil, nil
+		},
+	})
+	c.register(&commandSpec{
+		name:        "help",
+		description: "Show help for gator or one of its commands.",
+		args:        []string{"[command]..."},
+		handler:     handlerHelp(c),
+	})
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"help"}, want: "Usage: gator <command>"},
+		{args: []string{"help", "feeds"}, want: "List all feeds."},
+		{args: []string{"feeds", "--help"}, want: "List all feeds."},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+
+		err := c.run(context.Background(), &state{}, test.args, &out)
+
+		if err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+
+		if !strings.Contains(out.String(), test.want) {
+			t.Errorf("%v: wrote %q, want it to contain %q", test.args, out.String(), test.want)
+		}
+	}
+}
+
+func TestResolveListSubcommands(t *testing.T) {
+	c := newCommands()
+	c.register(ruleCommand)
+	c.register(webhookCommand)
+
+	tests := []struct {
+		args     []string
+		wantName string
+		parent   *commandSpec
+	}{
+		{args: []string{"rule", "list"}, wantName: "rule list", parent: ruleCommand},
+		{args: []string{"webhook", "list"}, wantName: "webhook list", parent: webhookCommand},
+	}
+
+	for _, test := range tests {
+		spec, name, rest, err := c.resolve(test.args)
+
+		if err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+
+		if name != test.wantName || len(rest) != 0 {
+			t.Errorf("%v: resolved %q with %v left, want %q", test.args, name, rest, test.wantName)
+		}
+
+		if spec.handler == nil || spec == test.parent {
+			t.Errorf("%v: resolved to %v, want its list subcommand", test.args, spec.name)
+		}
+	}
+}
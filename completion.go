@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/output"
+)
+
+var completionCommand = &commandSpec{
+	name:        "completion",
+	description: "Print the completion script for bash, zsh or fish.",
+	args:        []string{"<bash|zsh|fish>"},
+	handler:     handlerCompletion,
+	complete: func(ctx context.Context, s *state, position int) []string {
+		return []string{"bash", "zsh", "fish"}
+	},
+}
+
+const bashCompletion = `# bash completion for gator
+_gator() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null 2>&1; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+
+    local IFS=$'\n'
+    COMPREPLY=($(gator __complete "${words[@]:1:cword}" 2>/dev/null))
+
+    if declare -F __ltrim_colon_completions >/dev/null 2>&1; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -o default -F _gator gator
+`
+
+const zshCompletion = `#compdef gator
+# zsh completion for gator
+_gator() {
+    local -a completions
+    completions=("${(@f)$(gator __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -- "${completions[@]}"
+}
+
+if [ "$funcstack[1]" = "_gator" ]; then
+    _gator "$@"
+else
+    compdef _gator gator
+fi
+`
+
+const fishCompletion = `# fish completion for gator
+function __gator_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    gator __complete $tokens[2..-1] 2>/dev/null
+end
+
+complete -c gator -f -a '(__gator_complete)'
+`
+
+type completionResult []string
+
+func (r completionResult) Text() string {
+	return strings.Join(r, "\n")
+}
+
+func handlerCompletion(s *state, cmd command) (any, error) {
+	switch cmd.arguments[0] {
+	case "bash":
+		return messageResult{Message: bashCompletion}, nil
+	case "zsh":
+		return messageResult{Message: zshCompletion}, nil
+	case "fish":
+		return messageResult{Message: fishCompletion}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported shell %v, expecting bash, zsh or fish", cmd.arguments[0])
+}
+
+// handlerComplete backs the completion scripts: it receives the words typed
+// after "gator", the last one being the word under the cursor, and returns
+// the candidates for it.
+func handlerComplete(c *commands) func(*state, command) (any, error) {
+	return func(s *state, cmd command) (any, error) {
+		return completionResult(c.complete(cmd.ctx, s, cmd.arguments)), nil
+	}
+}
+
+func (c *commands) complete(ctx context.Context, s *state, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+
+	current := words[len(words)-1]
+	previous := words[:len(words)-1]
+
+	if len(previous) == 0 {
+		return matching(commandNames(c.visible()), current)
+	}
+
+	spec, name, rest, err := c.resolve(previous)
+
+	if err != nil {
+		return nil
+	}
+
+	flags := spec.flagSet(name)
+
+	if len(rest) > 0 {
+		last := rest[len(rest)-1]
+		f := lookupFlag(flags, last)
+
+		if f != nil && !strings.Contains(last, "=") && !isBoolFlag(f) {
+			if f.Name == "output" || f.Name == "o" {
+				formats := []string{}
+
+				for _, format := range output.Formats {
+					formats = append(formats, string(format))
+				}
+
+				return matching(formats, current)
+			}
+
+			return nil
+		}
+	}
+
+	if strings.HasPrefix(current, "-") {
+		names := []string{}
+
+		flags.VisitAll(func(f *flag.Flag) {
+			if len(f.Name) > 1 {
+				names = append(names, "--"+f.Name)
+			}
+		})
+
+		return matching(names, current)
+	}
+
+	position := positionalCount(flags, rest)
+	candidates := []string{}
+
+	if len(spec.subcommands) > 0 && position == 0 {
+		candidates = append(candidates, commandNames(spec.subcommands)...)
+	}
+
+	if spec.complete != nil {
+		candidates = append(candidates, spec.complete(ctx, s, position)...)
+	}
+
+	return matching(candidates, current)
+}
+
+func lookupFlag(flags *flag.FlagSet, argument string) *flag.Flag {
+	if !strings.HasPrefix(argument, "-") {
+		return nil
+	}
+
+	name, _, _ := strings.Cut(strings.TrimLeft(argument, "-"), "=")
+
+	return flags.Lookup(name)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+
+	return ok && boolFlag.IsBoolFlag()
+}
+
+// positionalCount counts the positional arguments among words, skipping
+// flags and the values of non boolean flags.
+func positionalCount(flags *flag.FlagSet, words []string) int {
+	count := 0
+
+	for i := 0; i < len(words); i++ {
+		f := lookupFlag(flags, words[i])
+
+		if f == nil {
+			if !strings.HasPrefix(words[i], "-") {
+				count++
+			}
+
+			continue
+		}
+
+		if !strings.Contains(words[i], "=") && !isBoolFlag(f) {
+			i++
+		}
+	}
+
+	return count
+}
+
+func matching(candidates []string, prefix string) []string {
+	matches := []string{}
+	seen := make(map[string]bool)
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) && !seen[candidate] {
+			seen[candidate] = true
+			matches = append(matches, candidate)
+		}
+	}
+
+	sort.Strings(matches)
+
+	return matches
+}
+
+func completeUsernames(ctx context.Context, s *state, position int) []string {
+	if position != 0 {
+		return nil
+	}
+
+	users, err := s.db.GetUsers(ctx)
+
+	if err != nil {
+		return nil
+	}
+
+	names := []string{}
+
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+
+	return names
+}
+
+func completeFeedUrls(ctx context.Context, s *state, position int) []string {
+	if position != 0 {
+		return nil
+	}
+
+	feeds, err := s.db.GetFeeds(ctx)
+
+	if err != nil {
+		return nil
+	}
+
+	urls := []string{}
+
+	for _, feed := range feeds {
+		urls = append(urls, feed.Url)
+	}
+
+	return urls
+}
+
+func completeFollowedUrls(ctx context.Context, s *state, position int) []string {
+	if position != 0 {
+		return nil
+	}
+
+	user, err := s.db.GetUser(ctx, s.config.CurrentUserName)
+
+	if err != nil {
+		return nil
+	}
+
+	feeds, err := s.db.GetFollowedFeedsForUser(ctx, uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	})
+
+	if err != nil {
+		return nil
+	}
+
+	urls := []string{}
+
+	for _, feed := range feeds {
+		urls = append(urls, feed.Url)
+	}
+
+	return urls
+}
+
+func completeRetentionTargets(ctx context.Context, s *state, position int) []string {
+	if position != 0 {
+		return nil
+	}
+
+	return append([]string{globalRetentionPolicy}, completeFeedUrls(ctx, s, position)...)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mambo-dev/gator/internal/systemd"
+)
+
+const (
+	// drainTimeout bounds how long agg keeps working on the feed in flight
+	// after it is asked to stop.
+	drainTimeout = 30 * time.Second
+	// stallTimeout is how much longer than its interval a round of agg may
+	// take before /healthz reports it stalled and the watchdog is starved.
+	stallTimeout = 10 * time.Minute
+)
+
+// daemon tracks the health of a long running command for /healthz and
+// /readyz, and for systemd when gator runs as a Type=notify service.
+type daemon struct {
+	// stallAfter is how long the command may go without a heartbeat and
+	// still be alive. Zero means it is alive as long as it runs.
+	stallAfter time.Duration
+	lastBeat   atomic.Int64
+	ready      atomic.Bool
+	readyOnce  sync.Once
+}
+
+func newDaemon(stallAfter time.Duration) *daemon {
+	d := &daemon{stallAfter: stallAfter}
+	d.lastBeat.Store(time.Now().UnixNano())
+
+	return d
+}
+
+// heartbeat records that the command is making progress. The first one
+// marks it ready.
+func (d *daemon) heartbeat(status string) {
+	d.lastBeat.Store(time.Now().UnixNano())
+
+	d.readyOnce.Do(func() {
+		d.ready.Store(true)
+		notifySystemd(systemd.Ready + "\n" + systemd.Status(status))
+	})
+}
+
+// stopping marks the command not ready while it finishes its work.
+func (d *daemon) stopping() {
+	d.ready.Store(false)
+	notifySystemd(systemd.Stopping)
+}
+
+func (d *daemon) alive() bool {
+	if d.stallAfter == 0 {
+		return true
+	}
+
+	return time.Since(time.Unix(0, d.lastBeat.Load())) < d.stallAfter
+}
+
+// watchdog pings the systemd watchdog at half its interval until ctx is
+// cancelled. Pings stop while the command is stalled, so systemd restarts
+// it.
+func (d *daemon) watchdog(ctx context.Context) {
+	interval := systemd.WatchdogInterval()
+
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !d.alive() {
+			log.Printf("Stalled for over %v, skipping watchdog\n", d.stallAfter)
+			continue
+		}
+
+		notifySystemd(systemd.Watchdog)
+	}
+}
+
+func (d *daemon) route(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", d.healthz)
+	mux.HandleFunc("GET /readyz", d.readyz)
+}
+
+// healthz answers whether the command is alive, for liveness probes.
+func (d *daemon) healthz(w http.ResponseWriter, r *http.Request) {
+	if !d.alive() {
+		http.Error(w, "stalled", http.StatusServiceUnavailable)
+		return
+	}
+
+	io.WriteString(w, "ok\n")
+}
+
+// readyz answers whether the command has started and is not stopping, for
+// readiness probes.
+func (d *daemon) readyz(w http.ResponseWriter, r *http.Request) {
+	if !d.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	io.WriteString(w, "ready\n")
+}
+
+func notifySystemd(state string) {
+	_, err := systemd.Notify(state)
+
+	if err != nil {
+		log.Printf("Failed to notify systemd %v\n", err.Error())
+	}
+}
+
+// serveStatus serves /metrics, /healthz and /readyz on addr until ctx is
+// cancelled.
+func serveStatus(ctx context.Context, addr string, d *daemon) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", registry)
+	d.route(mux)
+
+	statusServer := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+
+		statusServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Serving metrics and health checks on %v\n", addr)
+
+	err := statusServer.ListenAndServe()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Failed to serve metrics %v\n", err.Error())
+	}
+}
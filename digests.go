@@ -0,0 +1,473 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/digest"
+	"github.com/mambo-dev/gator/internal/mail"
+)
+
+// digestRetryDelay is how long agg waits before trying a digest again that
+// could not be sent.
+const digestRetryDelay = 15 * time.Minute
+
+var digestCommand = &commandSpec{
+	name:        "digest",
+	description: "Show your email digest schedule. agg mails a summary of unread posts on schedule when smtp is configured.",
+	handler:     middlewareLoggedIn(handlerDigest),
+	subcommands: []*commandSpec{
+		{
+			name:        "set",
+			description: "Send a digest of the unread posts of the feeds you follow to an email address.",
+			args:        []string{"<email>"},
+			flags: func(flags *flag.FlagSet) {
+				flags.String("frequency", string(digest.Daily), "`frequency`: daily or weekly")
+				flags.String("at", "08:00", "`time` of day to send at, HH:MM")
+				flags.String("weekday", "monday", "`day` weekly digests are sent on")
+				flags.String("timezone", "UTC", "IANA `timezone` of --at, like Europe/Berlin")
+				flags.Int("limit", 50, "include at most `N` posts")
+			},
+			handler: middlewareLoggedIn(handlerDigestSet),
+		},
+		{
+			name:        "off",
+			description: "Stop sending digests.",
+			handler:     middlewareLoggedIn(handlerDigestOff),
+		},
+		{
+			name:        "preview",
+			description: "Print the digest that would be sent now, as plain text or HTML, without sending it.",
+			flags: func(flags *flag.FlagSet) {
+				flags.Bool("html", false, "print the HTML version")
+			},
+			handler: middlewareLoggedIn(handlerDigestPreview),
+		},
+		{
+			name:        "send",
+			description: "Send your digest now instead of waiting for the schedule.",
+			handler:     middlewareLoggedIn(handlerDigestSend),
+		},
+	},
+}
+
+type digestResult struct {
+	Email    string     `json:"email"`
+	Schedule string     `json:"schedule"`
+	Limit    int32      `json:"limit"`
+	NextAt   time.Time  `json:"next_at"`
+	LastSent *time.Time `json:"last_sent,omitempty"`
+}
+
+func (r digestResult) Text() string {
+	text := fmt.Sprintf("Digest of up to %d posts to %v, %v, next on %v", r.Limit, r.Email, r.Schedule, r.NextAt.Format("Mon 2 Jan 15:04 MST"))
+
+	if r.LastSent != nil {
+		text += ", last sent " + r.LastSent.Format(time.DateTime)
+	}
+
+	return text
+}
+
+func newDigestResult(row database.Digest) (digestResult, error) {
+	schedule, err := digestSchedule(row)
+
+	if err != nil {
+		return digestResult{}, err
+	}
+
+	result := digestResult{
+		Email:    row.Email,
+		Schedule: schedule.String(),
+		Limit:    row.MaxPosts,
+		NextAt:   row.NextSendAt.In(schedule.Location),
+	}
+
+	if row.LastSentAt.Valid {
+		result.LastSent = &row.LastSentAt.Time
+	}
+
+	return result, nil
+}
+
+func handlerDigest(s *state, cmd command, user database.User) (any, error) {
+	row, err := s.db.GetDigestForUser(cmd.ctx, user.ID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return messageResult{Message: "No digest, set one up with \"gator digest set <email>\"."}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get digest %v", err)
+	}
+
+	return newDigestResult(row)
+}
+
+func handlerDigestSet(s *state, cmd command, user database.User) (any, error) {
+	address, err := netmail.ParseAddress(cmd.arguments[0])
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid email address %q", cmd.arguments[0])
+	}
+
+	frequency, err := digest.ParseFrequency(cmd.stringFlag("frequency"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	minute, err := digest.ParseClock(cmd.stringFlag("at"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	weekday, err := digest.ParseWeekday(cmd.stringFlag("weekday"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(cmd.stringFlag("timezone"))
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", cmd.stringFlag("timezone"))
+	}
+
+	limit := cmd.intFlag("limit")
+
+	if limit < 1 || limit > apiMaxLimit {
+		return nil, fmt.Errorf("expecting a --limit between 1 and %d.", apiMaxLimit)
+	}
+
+	schedule := digest.Schedule{
+		Frequency: frequency,
+		Weekday:   weekday,
+		Minute:    minute,
+		Location:  location,
+	}
+
+	row, err := s.db.SetDigest(cmd.ctx, database.SetDigestParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     user.ID,
+		Email:      address.Address,
+		Frequency:  string(frequency),
+		Weekday:    int32(weekday),
+		SendMinute: int32(minute),
+		Timezone:   location.String(),
+		MaxPosts:   int32(limit),
+		NextSendAt: schedule.Next(time.Now()),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not set digest %v", err)
+	}
+
+	if s.config.SMTP == nil {
+		log.Println("No smtp server is configured, digests will not be sent until one is.")
+	}
+
+	return newDigestResult(row)
+}
+
+func handlerDigestOff(s *state, cmd command, user database.User) (any, error) {
+	removed, err := s.db.DeleteDigest(cmd.ctx, user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not remove digest %v", err)
+	}
+
+	if removed == 0 {
+		return nil, errors.New("no digest is set up")
+	}
+
+	return messageResult{Message: "digests stopped"}, nil
+}
+
+// handlerDigestPreview prints the digest itself, so it returns no result.
+// Without a schedule it previews a daily digest.
+func handlerDigestPreview(s *state, cmd command, user database.User) (any, error) {
+	row, err := s.db.GetDigestForUser(cmd.ctx, user.ID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		row = database.Digest{
+			UserID:    user.ID,
+			Frequency: string(digest.Daily),
+			Timezone:  "UTC",
+			MaxPosts:  50,
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get digest %v", err)
+	}
+
+	document, err := buildDigest(cmd.ctx, s, row, user.Name, time.Now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	text, html, err := digest.Render(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body := text
+
+	if cmd.boolFlag("html") {
+		body = html
+	}
+
+	fmt.Fprintf(cmd.out, "Subject: %v\n\n%v", document.Subject(), body)
+
+	return nil, nil
+}
+
+func handlerDigestSend(s *state, cmd command, user database.User) (any, error) {
+	row, err := s.db.GetDigestForUser(cmd.ctx, user.ID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("no digest is set up, set one up with \"gator digest set <email>\"")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get digest %v", err)
+	}
+
+	sent, err := sendDigest(cmd.ctx, s, row, user.Name, time.Now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sent == 0 {
+		return messageResult{Message: "no unread posts, nothing sent"}, nil
+	}
+
+	return messageResult{Message: fmt.Sprintf("sent a digest of %d posts to %v", sent, row.Email)}, nil
+}
+
+// sendDueDigests sends the digests whose time has come. A digest that
+// cannot be sent is tried again after digestRetryDelay.
+func sendDueDigests(ctx context.Context, s *state) error {
+	if s.config.SMTP == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	rows, err := s.db.GetDueDigests(ctx, now)
+
+	if err != nil {
+		return fmt.Errorf("could not get due digests %v", err)
+	}
+
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		digestRow := database.Digest{
+			ID:         row.ID,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+			UserID:     row.UserID,
+			Email:      row.Email,
+			Frequency:  row.Frequency,
+			Weekday:    row.Weekday,
+			SendMinute: row.SendMinute,
+			Timezone:   row.Timezone,
+			MaxPosts:   row.MaxPosts,
+			LastSentAt: row.LastSentAt,
+			NextSendAt: row.NextSendAt,
+		}
+
+		sent, err := sendDigest(ctx, s, digestRow, row.UserName, now)
+
+		if err != nil {
+			log.Printf("Failed to send digest to %v %v\n", row.Email, err.Error())
+
+			err = s.db.MarkDigestSent(ctx, database.MarkDigestSentParams{
+				ID:         row.ID,
+				LastSentAt: row.LastSentAt,
+				NextSendAt: now.Add(digestRetryDelay),
+			})
+
+			if err != nil {
+				return fmt.Errorf("could not reschedule digest %v", err)
+			}
+
+			continue
+		}
+
+		log.Printf("Sent a digest of %d posts to %v\n", sent, row.Email)
+	}
+
+	return nil
+}
+
+// sendDigest mails the unread posts since the last digest and schedules the
+// next one. Nothing is mailed when there are no unread posts.
+func sendDigest(ctx context.Context, s *state, row database.Digest, userName string, now time.Time) (int, error) {
+	schedule, err := digestSchedule(row)
+
+	if err != nil {
+		return 0, err
+	}
+
+	document, err := buildDigest(ctx, s, row, userName, now)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if document.Total > 0 {
+		if s.config.SMTP == nil {
+			return 0, errors.New("no smtp server is configured, add \"smtp\" to .gatorconfig.json")
+		}
+
+		text, html, err := digest.Render(document)
+
+		if err != nil {
+			return 0, err
+		}
+
+		err = mail.Send(mail.Server{
+			Host:     s.config.SMTP.Host,
+			Port:     s.config.SMTP.Port,
+			Username: s.config.SMTP.Username,
+			Password: s.config.SMTP.Password,
+			TLS:      s.config.SMTP.TLS,
+		}, mail.Message{
+			From:    s.config.SMTP.From,
+			To:      row.Email,
+			Subject: document.Subject(),
+			Text:    text,
+			HTML:    html,
+			Date:    now,
+		})
+
+		if err != nil {
+			return 0, fmt.Errorf("could not send digest %v", err)
+		}
+	}
+
+	err = s.db.MarkDigestSent(ctx, database.MarkDigestSentParams{
+		ID: row.ID,
+		LastSentAt: sql.NullTime{
+			Time:  now,
+			Valid: true,
+		},
+		NextSendAt: schedule.Next(now),
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("could not mark digest sent %v", err)
+	}
+
+	return document.Total, nil
+}
+
+// buildDigest collects the unread posts fetched since the last digest, or
+// over the schedule's period for the first one, grouped by feed.
+func buildDigest(ctx context.Context, s *state, row database.Digest, userName string, now time.Time) (digest.Digest, error) {
+	schedule, err := digestSchedule(row)
+
+	if err != nil {
+		return digest.Digest{}, err
+	}
+
+	since := now.Add(-schedule.Period())
+
+	if row.LastSentAt.Valid {
+		since = row.LastSentAt.Time
+	}
+
+	posts, err := s.db.ListStreamPostsForUser(ctx, database.ListStreamPostsForUserParams{
+		UserID: uuid.NullUUID{
+			UUID:  row.UserID,
+			Valid: true,
+		},
+		UnreadOnly: true,
+		NewerThan: sql.NullTime{
+			Time:  since,
+			Valid: true,
+		},
+		Seqs:     []int64{},
+		MaxPosts: row.MaxPosts,
+	})
+
+	if err != nil {
+		return digest.Digest{}, fmt.Errorf("could not get posts %v", err)
+	}
+
+	document := digest.Digest{
+		User:  userName,
+		Since: since.In(schedule.Location),
+		Total: len(posts),
+	}
+
+	feeds := map[uuid.UUID]int{}
+
+	for _, post := range posts {
+		index, ok := feeds[post.FeedID]
+
+		if !ok {
+			index = len(document.Feeds)
+			feeds[post.FeedID] = index
+
+			document.Feeds = append(document.Feeds, digest.Feed{
+				Name: post.FeedName,
+				Url:  post.FeedSiteUrl.String,
+			})
+		}
+
+		published := post.CreatedAt
+
+		if post.PublishedAt.Valid {
+			published = post.PublishedAt.Time
+		}
+
+		document.Feeds[index].Posts = append(document.Feeds[index].Posts, digest.Post{
+			Title:     post.Title,
+			Url:       post.Url,
+			Author:    post.Author.String,
+			Excerpt:   excerpt(post.Description.String),
+			Published: published.In(schedule.Location),
+		})
+	}
+
+	slices.SortStableFunc(document.Feeds, func(a, b digest.Feed) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	return document, nil
+}
+
+func digestSchedule(row database.Digest) (digest.Schedule, error) {
+	location, err := time.LoadLocation(row.Timezone)
+
+	if err != nil {
+		return digest.Schedule{}, fmt.Errorf("unknown digest timezone %q", row.Timezone)
+	}
+
+	return digest.Schedule{
+		Frequency: digest.Frequency(row.Frequency),
+		Weekday:   time.Weekday(row.Weekday),
+		Minute:    int(row.SendMinute),
+		Location:  location,
+	}, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal"
+	"github.com/mambo-dev/gator/internal/digest"
+	"github.com/mambo-dev/gator/internal/mail"
+	"github.com/mambo-dev/gator/internal/mail/mailtest"
+)
+
+var dueDigestColumns = []string{
+	"id", "created_at", "updated_at", "user_id", "email", "frequency", "weekday", "send_minute",
+	"timezone", "max_posts", "last_sent_at", "next_send_at", "user_name",
+}
+
+var streamPostColumns = []string{
+	"id", "created_at", "updated_at", "title", "url", "description", "published_at", "feed_id", "seq",
+	"content", "simhash", "cluster_id", "author", "categories", "source_url", "feed_seq", "feed_name",
+	"feed_site_url", "feed_url", "folder", "read_at", "starred",
+}
+
+func TestSendDueDigests(t *testing.T) {
+	server := mailtest.NewServer()
+	defer server.Close()
+
+	server.Reject("carol@example.com")
+
+	alice, carol, dave := uuid.New(), uuid.New(), uuid.New()
+	aliceDigest, carolDigest, daveDigest := uuid.New(), uuid.New(), uuid.New()
+	lastSent := time.Now().Add(-24 * time.Hour)
+
+	db := newFakeDB(t)
+	db.on("GetDueDigests", func(args []driver.Value) (fakeRows, error) {
+		row := func(id, user uuid.UUID, name string, lastSentAt driver.Value) []driver.Value {
+			return []driver.Value{
+				id.String(), lastSent, lastSent, user.String(), name + "@example.com", "daily", int64(1), int64(8 * 60),
+				"Europe/Berlin", int64(50), lastSentAt, time.Now().Add(-time.Minute), name,
+			}
+		}
+
+		return fakeRows{
+			columns: dueDigestColumns,
+			rows: [][]driver.Value{
+				row(aliceDigest, alice, "alice", nil),
+				row(carolDigest, carol, "carol", lastSent),
+				row(daveDigest, dave, "dave", lastSent),
+			},
+		}, nil
+	})
+	db.on("ListStreamPostsForUser", func(args []driver.Value) (fakeRows, error) {
+		rows := fakeRows{columns: streamPostColumns}
+
+		if args[0] == dave.String() {
+			return rows, nil
+		}
+
+		feedID := uuid.NewString()
+
+		for _, title := range []string{"Go 1.23 is released", "Range over func"} {
+			rows.rows = append(rows.rows, []driver.Value{
+				uuid.NewString(), time.Now(), time.Now(), title, "https://go.dev/blog/" + strings.ReplaceAll(title, " ", "-"),
+				"<p>What is new.</p>", nil, feedID, int64(1), nil, nil, nil, "Gopher", []byte("{}"), nil,
+				int64(1), "The Go Blog", "https://go.dev/blog", "https://go.dev/blog/feed.atom", nil, nil, false,
+			})
+		}
+
+		return rows, nil
+	})
+	db.on("MarkDigestSent", noRows)
+
+	s := db.state("alice")
+	s.config.SMTP = &internal.SMTPConfig{
+		Host: server.Host,
+		Port: server.Port,
+		From: "gator <gator@example.com>",
+		TLS:  mail.TLSNone,
+	}
+
+	start := time.Now()
+
+	err := sendDueDigests(context.Background(), s)
+
+	if err != nil {
+		t.Fatalf("sendDueDigests: %v", err)
+	}
+
+	messages := server.Messages()
+
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want only alice's", len(messages))
+	}
+
+	if to := strings.Join(messages[0].To, ","); to != "alice@example.com" {
+		t.Errorf("digest sent to %v, want alice@example.com", to)
+	}
+
+	for _, want := range []string{"Subject: ", "Go 1.23 is released", "Range over func", "The Go Blog", "https://go.dev/blog/Go-1.23-is-released"} {
+		if !strings.Contains(string(messages[0].Data), want) {
+			t.Errorf("digest does not contain %q:\n%s", want, messages[0].Data)
+		}
+	}
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	schedule := digest.Schedule{Frequency: digest.Daily, Minute: 8 * 60, Location: berlin}
+	marked := map[string][]driver.Value{}
+
+	for _, args := range db.called("MarkDigestSent") {
+		marked[args[0].(string)] = args
+	}
+
+	// Sent digests and digests without posts are scheduled for their next
+	// time, a failed one is retried soon and keeps its last sent time so no
+	// post is left out.
+	for _, id := range []uuid.UUID{aliceDigest, daveDigest} {
+		args := marked[id.String()]
+
+		sentAt, ok := args[1].(time.Time)
+
+		if !ok || sentAt.Before(start) {
+			t.Errorf("digest %v marked sent at %v, want now", id, args[1])
+			continue
+		}
+
+		if next := args[2].(time.Time); !next.Equal(schedule.Next(sentAt)) {
+			t.Errorf("digest %v next at %v, want %v", id, next, schedule.Next(sentAt))
+		}
+	}
+
+	args := marked[carolDigest.String()]
+
+	if sentAt, ok := args[1].(time.Time); !ok || !sentAt.Equal(lastSent) {
+		t.Errorf("failed digest last sent at %v, want it kept at %v", args[1], lastSent)
+	}
+
+	if next := args[2].(time.Time); next.Before(start.Add(digestRetryDelay)) || next.After(time.Now().Add(digestRetryDelay)) {
+		t.Errorf("failed digest next at %v, want in %v", next, digestRetryDelay)
+	}
+
+	// The first digest looks back over a whole period, later ones since
+	// the last.
+	for _, args := range db.called("ListStreamPostsForUser") {
+		newerThan := args[5].(time.Time)
+
+		switch args[0] {
+		case alice.String():
+			if newerThan.Before(start.Add(-schedule.Period())) || newerThan.After(time.Now().Add(-schedule.Period())) {
+				t.Errorf("first digest looks back to %v, want a day", newerThan)
+			}
+		default:
+			if !newerThan.Equal(lastSent) {
+				t.Errorf("digest looks back to %v, want %v", newerThan, lastSent)
+			}
+		}
+	}
+}
+
+func TestSendDueDigestsWithoutSMTP(t *testing.T) {
+	db := newFakeDB(t)
+
+	err := sendDueDigests(context.Background(), db.state("alice"))
+
+	if err != nil {
+		t.Fatalf("sendDueDigests: %v", err)
+	}
+
+	if len(db.calls) != 0 {
+		t.Errorf("queries = %v, want none without smtp", db.calls)
+	}
+}
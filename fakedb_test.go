@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/mambo-dev/gator/internal"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+// fakeRows is the answer of a fake query: the columns it returns, in the
+// order the generated code scans them, and one slice of values per row.
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// fakeQuery answers a query of the database package given its arguments.
+type fakeQuery func(args []driver.Value) (fakeRows, error)
+
+// fakeCall is a query gator ran against a fakeDB.
+type fakeCall struct {
+	name string
+	args []driver.Value
+}
+
+// fakeDB is a database/sql driver answering the queries of the database
+// package by name, so commands can be tested without Postgres. Queries
+// without an answer fail the test.
+type fakeDB struct {
+	t       *testing.T
+	mu      sync.Mutex
+	queries map[string]fakeQuery
+	calls   []fakeCall
+}
+
+func newFakeDB(t *testing.T) *fakeDB {
+	return &fakeDB{
+		t:       t,
+		queries: map[string]fakeQuery{},
+	}
+}
+
+// on answers the query named name with answer.
+func (f *fakeDB) on(name string, answer fakeQuery) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queries[name] = answer
+}
+
+// state returns a state using the fake database, logged in as userName.
+func (f *fakeDB) state(userName string) *state {
+	db := sql.OpenDB(f)
+	f.t.Cleanup(func() { db.Close() })
+
+	return &state{
+		config: &internal.Config{CurrentUserName: userName},
+		db:     database.New(instrumentedDB{db: db}),
+		sqlDB:  db,
+	}
+}
+
+// called returns the arguments of every call of the query named name.
+func (f *fakeDB) called(name string) [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls [][]driver.Value
+
+	for _, call := range f.calls {
+		if call.name == name {
+			calls = append(calls, call.args)
+		}
+	}
+
+	return calls
+}
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) (fakeRows, error) {
+	name := queryName(query)
+	values := make([]driver.Value, len(args))
+
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeCall{name: name, args: values})
+	answer, ok := f.queries[name]
+	f.mu.Unlock()
+
+	if !ok {
+		f.t.Errorf("unexpected query %v", name)
+		return fakeRows{}, fmt.Errorf("unexpected query %v", name)
+	}
+
+	return answer(values)
+}
+
+func (f *fakeDB) Connect(ctx context.Context) (driver.Conn, error) {
+	return fakeConn{db: f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, fmt.Errorf("open a fakeDB with sql.OpenDB")
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx records transactions as the calls BEGIN, COMMIT and ROLLBACK, so
+// tests can check which queries ran in one.
+func (c fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.db.calls = append(c.db.calls, fakeCall{name: "BEGIN"})
+	c.db.mu.Unlock()
+
+	return fakeTx{db: c.db}, nil
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	answer, err := c.db.run(query, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(len(answer.rows)), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	answer, err := c.db.run(query, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeResultRows{answer: answer}, nil
+}
+
+// CheckNamedValue passes arguments on as they are, so tests see the values
+// gator sent rather than their driver encoding.
+func (c fakeConn) CheckNamedValue(value *driver.NamedValue) error {
+	if valuer, ok := value.Value.(driver.Valuer); ok {
+		converted, err := valuer.Value()
+
+		if err != nil {
+			return err
+		}
+
+		value.Value = converted
+	}
+
+	return nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (t fakeTx) end(name string) error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+
+	t.db.calls = append(t.db.calls, fakeCall{name: name})
+
+	return nil
+}
+
+func (t fakeTx) Commit() error {
+	return t.end("COMMIT")
+}
+
+func (t fakeTx) Rollback() error {
+	return t.end("ROLLBACK")
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("fakeDB only runs queries with a context")
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("fakeDB only runs queries with a context")
+}
+
+type fakeResultRows struct {
+	answer fakeRows
+	next   int
+}
+
+func (r *fakeResultRows) Columns() []string {
+	return r.answer.columns
+}
+
+func (r *fakeResultRows) Close() error {
+	return nil
+}
+
+func (r *fakeResultRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.answer.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.answer.rows[r.next])
+	r.next++
+
+	return nil
+}
+
+// noRows answers a query with no rows.
+func noRows(args []driver.Value) (fakeRows, error) {
+	return fakeRows{}, nil
+}
+
+// oneRow answers a query with a single row of values, one per column.
+func oneRow(columns []string, values ...driver.Value) fakeQuery {
+	return func(args []driver.Value) (fakeRows, error) {
+		return fakeRows{
+			columns: columns,
+			rows:    [][]driver.Value{values},
+		}, nil
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+const (
+	maxFaviconSize = 64 << 10
+	faviconTimeout = 10 * time.Second
+)
+
+// storeFavicon fetches the favicon of a feed's site once. A failed fetch is
+// stored as an empty favicon so it is not retried on every scrape.
+func storeFavicon(ctx context.Context, s *state, feedID uuid.UUID, siteUrl string) {
+	fetchCtx, cancel := context.WithTimeout(ctx, faviconTimeout)
+	defer cancel()
+
+	favicon, err := fetchFavicon(fetchCtx, siteUrl)
+
+	if err != nil {
+		log.Printf("Failed to fetch favicon %v\n", err.Error())
+	}
+
+	err = s.db.SetFeedFavicon(ctx, database.SetFeedFaviconParams{
+		ID: feedID,
+		Favicon: sql.NullString{
+			String: favicon,
+			Valid:  true,
+		},
+	})
+
+	if err != nil {
+		log.Printf("Failed to set feed favicon %v\n", err.Error())
+	}
+}
+
+// fetchFavicon downloads /favicon.ico of a site and returns it the way the
+// Fever API wants it: a mime type and base64 data, as in
+// image/x-icon;base64,AAABAA...
+func fetchFavicon(ctx context.Context, siteUrl string) (string, error) {
+	site, err := url.Parse(siteUrl)
+
+	if err != nil || site.Host == "" {
+		return "", fmt.Errorf("invalid site url %v", siteUrl)
+	}
+
+	iconUrl := url.URL{Scheme: site.Scheme, Host: site.Host, Path: "/favicon.ico"}
+	request, err := http.NewRequestWithContext(ctx, "GET", iconUrl.String(), nil)
+
+	if err != nil {
+		return "", fmt.Errorf("error fetching favicon: %v", err)
+	}
+
+	request.Header.Set("User-Agent", "gator")
+
+	resp, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		return "", fmt.Errorf("error fetching favicon: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return "", fmt.Errorf("favicon request failed with status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconSize+1))
+
+	if err != nil {
+		return "", fmt.Errorf("error reading favicon: %v", err)
+	}
+
+	if len(body) > maxFaviconSize {
+		return "", fmt.Errorf("favicon of %v is larger than %d bytes", site.Host, maxFaviconSize)
+	}
+
+	mimeType := http.DetectContentType(body)
+
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("favicon of %v is %v, not an image", site.Host, mimeType)
+	}
+
+	return mimeType + ";base64," + base64.StdEncoding.EncodeToString(body), nil
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+const (
+	feverApiVersion = 3
+	feverMaxItems   = 50
+)
+
+// Fever has no folders, only groups with integer ids. Group 0 is every
+// feed and -1 the sparks, which gator does not have.
+const (
+	feverKindling = 0
+	feverSparks   = -1
+)
+
+type feverGroup struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int64  `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	Url               string `json:"url"`
+	SiteUrl           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverFavicon struct {
+	ID   int64  `json:"id"`
+	Data string `json:"data"`
+}
+
+type feverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	Html          string `json:"html"`
+	Url           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+// routeFever registers the Fever API, for clients like Reeder or Unread.
+// They sign in with a gator user name and the password set with passwd.
+func (srv *server) routeFever() {
+	srv.mux.HandleFunc("/fever", srv.fever)
+	srv.mux.HandleFunc("/fever/{$}", srv.fever)
+}
+
+// fever answers a Fever API call. Everything is one endpoint: query
+// parameters name what to read and form values what to change, and the
+// response is a single object with the requested parts.
+func (srv *server) fever(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+
+	err := r.ParseForm()
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+
+	query := r.URL.Query()
+
+	if !query.Has("api") {
+		writeError(w, http.StatusBadRequest, "missing api parameter")
+		return
+	}
+
+	response := map[string]any{
+		"api_version": feverApiVersion,
+		"auth":        0,
+	}
+
+	apiKey := strings.ToLower(strings.TrimSpace(r.Form.Get("api_key")))
+
+	if apiKey == "" {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	user, err := srv.s.db.GetUserByFeverApiKey(r.Context(), sql.NullString{
+		String: apiKey,
+		Valid:  true,
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	if err != nil {
+		writeInternalError(w, "could not check api key", err)
+		return
+	}
+
+	userID := uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	}
+
+	feeds, err := srv.s.db.GetFollowedFeedsForUser(r.Context(), userID)
+
+	if err != nil {
+		writeInternalError(w, "could not get feeds", err)
+		return
+	}
+
+	response["auth"] = 1
+	response["last_refreshed_on_time"] = feverLastRefreshed(feeds)
+
+	if r.Form.Has("mark") {
+		status, message, err := srv.feverMark(r, user, feeds)
+
+		if err != nil {
+			writeInternalError(w, message, err)
+			return
+		}
+
+		if status != http.StatusOK {
+			writeError(w, status, message)
+			return
+		}
+	}
+
+	if query.Has("groups") || query.Has("feeds") {
+		groups, feedsGroups := feverGroups(feeds)
+
+		if query.Has("groups") {
+			response["groups"] = groups
+		}
+
+		response["feeds_groups"] = feedsGroups
+	}
+
+	if query.Has("feeds") {
+		list := make([]feverFeed, 0, len(feeds))
+
+		for _, feed := range feeds {
+			list = append(list, feverFeedFromRow(feed))
+		}
+
+		response["feeds"] = list
+	}
+
+	if query.Has("favicons") {
+		favicons, err := srv.s.db.GetFaviconsForUser(r.Context(), userID)
+
+		if err != nil {
+			writeInternalError(w, "could not get favicons", err)
+			return
+		}
+
+		list := make([]feverFavicon, 0, len(favicons))
+
+		for _, favicon := range favicons {
+			list = append(list, feverFavicon{
+				ID:   favicon.Seq,
+				Data: favicon.Favicon.String,
+			})
+		}
+
+		response["favicons"] = list
+	}
+
+	if query.Has("items") {
+		items, total, status, err := srv.feverItems(r, userID)
+
+		if err != nil {
+			writeInternalError(w, "could not get items", err)
+			return
+		}
+
+		if status != http.StatusOK {
+			writeError(w, status, "since_id, max_id and with_ids must be numbers")
+			return
+		}
+
+		response["items"] = items
+		response["total_items"] = total
+	}
+
+	// Hot links need a ranking of linked urls that gator does not keep.
+	if query.Has("links") {
+		response["links"] = []any{}
+	}
+
+	if query.Has("unread_item_ids") {
+		seqs, err := srv.s.db.GetUnreadPostSeqsForUser(r.Context(), userID)
+
+		if err != nil {
+			writeInternalError(w, "could not get unread items", err)
+			return
+		}
+
+		response["unread_item_ids"] = joinIDs(seqs)
+	}
+
+	if query.Has("saved_item_ids") {
+		seqs, err := srv.s.db.GetStarredPostSeqsForUser(r.Context(), userID)
+
+		if err != nil {
+			writeInternalError(w, "could not get saved items", err)
+			return
+		}
+
+		response["saved_item_ids"] = joinIDs(seqs)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// feverItems returns up to feverMaxItems posts: those after since_id
+// oldest first, those before max_id newest first, or those in with_ids.
+func (srv *server) feverItems(r *http.Request, userID uuid.NullUUID) ([]feverItem, int64, int, error) {
+	params := database.ListPostsBySeqForUserParams{
+		UserID:   userID,
+		Seqs:     []int64{},
+		MaxPosts: feverMaxItems,
+	}
+
+	query := r.URL.Query()
+
+	for name, param := range map[string]*sql.NullInt64{
+		"since_id": &params.SinceSeq,
+		"max_id":   &params.MaxSeq,
+	} {
+		if query.Get(name) == "" {
+			continue
+		}
+
+		value, err := strconv.ParseInt(query.Get(name), 10, 64)
+
+		if err != nil {
+			return nil, 0, http.StatusBadRequest, nil
+		}
+
+		*param = sql.NullInt64{
+			Int64: value,
+			Valid: true,
+		}
+	}
+
+	// Fever clients send max_id=0 for the newest items.
+	if params.MaxSeq.Valid && params.MaxSeq.Int64 == 0 {
+		params.MaxSeq.Int64 = 1<<63 - 1
+	}
+
+	if query.Get("with_ids") != "" {
+		for _, id := range strings.Split(query.Get("with_ids"), ",") {
+			seq, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+
+			if err != nil {
+				return nil, 0, http.StatusBadRequest, nil
+			}
+
+			params.Seqs = append(params.Seqs, seq)
+		}
+	}
+
+	posts, err := srv.s.db.ListPostsBySeqForUser(r.Context(), params)
+
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	total, err := srv.s.db.CountPostsForUser(r.Context(), userID)
+
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	items := make([]feverItem, 0, len(posts))
+
+	for _, post := range posts {
+		content := post.Content.String
+
+		if content == "" {
+			content = post.Description.String
+		}
+
+		published := post.CreatedAt
+
+		if post.PublishedAt.Valid {
+			published = post.PublishedAt.Time
+		}
+
+		items = append(items, feverItem{
+			ID:            post.Seq,
+			FeedID:        post.FeedSeq,
+			Title:         post.Title,
+			Author:        post.Author.String,
+			Html:          content,
+			Url:           post.Url,
+			IsSaved:       feverBool(post.Starred),
+			IsRead:        feverBool(post.ReadAt.Valid),
+			CreatedOnTime: published.Unix(),
+		})
+	}
+
+	return items, total, http.StatusOK, nil
+}
+
+// feverMark applies mark=item|feed|group with as=read|unread|saved|unsaved.
+// Feeds and groups can only be marked read, up to the before timestamp.
+func (srv *server) feverMark(r *http.Request, user database.User, feeds []database.GetFollowedFeedsForUserRow) (int, string, error) {
+	mark := r.Form.Get("mark")
+	as := r.Form.Get("as")
+
+	id, err := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+
+	if err != nil {
+		return http.StatusBadRequest, "id must be a number", nil
+	}
+
+	if mark == "item" {
+		// Items of feeds the user does not follow are treated as missing.
+		post, err := srv.s.db.GetPostBySeqForUser(r.Context(), database.GetPostBySeqForUserParams{
+			Seq: id,
+			UserID: uuid.NullUUID{
+				UUID:  user.ID,
+				Valid: true,
+			},
+		})
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, "no item with this id", nil
+		}
+
+		if err != nil {
+			return 0, "could not get item", err
+		}
+
+		switch as {
+		case "read":
+			err = srv.s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+				ID:     uuid.New(),
+				UserID: user.ID,
+				PostID: post.ID,
+			})
+		case "unread":
+			err = srv.s.db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{
+				UserID: user.ID,
+				PostID: post.ID,
+			})
+		case "saved", "unsaved":
+			err = srv.s.db.SetPostStarred(r.Context(), database.SetPostStarredParams{
+				ID:      uuid.New(),
+				UserID:  user.ID,
+				PostID:  post.ID,
+				Starred: as == "saved",
+			})
+		default:
+			return http.StatusBadRequest, "as must be read, unread, saved or unsaved", nil
+		}
+
+		if err != nil {
+			return 0, "could not mark item", err
+		}
+
+		return http.StatusOK, "", nil
+	}
+
+	if mark != "feed" && mark != "group" {
+		return http.StatusBadRequest, "mark must be item, feed or group", nil
+	}
+
+	if as != "read" {
+		return http.StatusBadRequest, "feeds and groups can only be marked read", nil
+	}
+
+	var feedIDs []uuid.UUID
+
+	for _, feed := range feeds {
+		switch {
+		case mark == "feed" && feed.Seq == id,
+			mark == "group" && id == feverKindling,
+			mark == "group" && feed.Folder.String != "" && feverGroupID(feed.Folder.String) == id:
+			feedIDs = append(feedIDs, feed.ID)
+		}
+	}
+
+	if len(feedIDs) == 0 {
+		if mark == "group" && id == feverSparks {
+			return http.StatusOK, "", nil
+		}
+
+		return http.StatusNotFound, "no " + mark + " with this id", nil
+	}
+
+	before := time.Now()
+
+	if r.Form.Get("before") != "" {
+		seconds, err := strconv.ParseInt(r.Form.Get("before"), 10, 64)
+
+		if err != nil {
+			return http.StatusBadRequest, "before must be a unix timestamp", nil
+		}
+
+		before = time.Unix(seconds, 0)
+	}
+
+	err = srv.s.db.MarkPostsReadBefore(r.Context(), database.MarkPostsReadBeforeParams{
+		UserID:  user.ID,
+		FeedIds: feedIDs,
+		Before:  before,
+	})
+
+	if err != nil {
+		return 0, "could not mark " + mark + " read", err
+	}
+
+	return http.StatusOK, "", nil
+}
+
+// feverGroups turns folders into groups. Feeds without a folder belong to
+// no group and show up at the top level of clients.
+func feverGroups(feeds []database.GetFollowedFeedsForUserRow) ([]feverGroup, []feverFeedsGroup) {
+	groups := []feverGroup{}
+	feedsGroups := []feverFeedsGroup{}
+	members := map[int64][]int64{}
+
+	for _, feed := range feeds {
+		if feed.Folder.String == "" {
+			continue
+		}
+
+		id := feverGroupID(feed.Folder.String)
+
+		if _, ok := members[id]; !ok {
+			groups = append(groups, feverGroup{
+				ID:    id,
+				Title: feed.Folder.String,
+			})
+		}
+
+		members[id] = append(members[id], feed.Seq)
+	}
+
+	for _, group := range groups {
+		feedsGroups = append(feedsGroups, feverFeedsGroup{
+			GroupID: group.ID,
+			FeedIDs: joinIDs(members[group.ID]),
+		})
+	}
+
+	return groups, feedsGroups
+}
+
+// feverGroupID derives a stable group id from a folder name, as folders
+// have no id of their own.
+func feverGroupID(folder string) int64 {
+	return int64(crc32.ChecksumIEEE([]byte(folder)))
+}
+
+func feverFeedFromRow(feed database.GetFollowedFeedsForUserRow) feverFeed {
+	result := feverFeed{
+		ID:      feed.Seq,
+		Title:   feed.Name,
+		Url:     feed.Url,
+		SiteUrl: feed.SiteUrl.String,
+	}
+
+	if feed.HasFavicon {
+		result.FaviconID = feed.Seq
+	}
+
+	if feed.LastFetchedAt.Valid {
+		result.LastUpdatedOnTime = feed.LastFetchedAt.Time.Unix()
+	}
+
+	return result
+}
+
+func feverLastRefreshed(feeds []database.GetFollowedFeedsForUserRow) int64 {
+	var last int64
+
+	for _, feed := range feeds {
+		if feed.LastFetchedAt.Valid {
+			last = max(last, feed.LastFetchedAt.Time.Unix())
+		}
+	}
+
+	return last
+}
+
+func feverBool(value bool) int {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+
+	return strings.Join(parts, ",")
+}
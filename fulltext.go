@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/readability"
+)
+
+// maxArticleSize caps how much of a linked page is read for extraction.
+const maxArticleSize = 5 << 20
+
+var fulltextCommand = &commandSpec{
+	name:        "fulltext",
+	description: "Turn fetching the full article of new posts of a feed on or off.",
+	args:        []string{"<feed-url>", "<on|off>"},
+	handler:     handlerFulltext,
+	complete: func(ctx context.Context, s *state, position int) []string {
+		if position == 1 {
+			return []string{"on", "off"}
+		}
+
+		return completeFeedUrls(ctx, s, position)
+	},
+}
+
+var refetchCommand = &commandSpec{
+	name:        "refetch",
+	description: "Download the article of a post again and store its extracted content.",
+	args:        []string{"<post-id>"},
+	handler:     handlerRefetch,
+}
+
+func handlerFulltext(s *state, cmd command) (any, error) {
+	var enabled bool
+
+	switch cmd.arguments[1] {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		return nil, fmt.Errorf("expecting on or off, got %q", cmd.arguments[1])
+	}
+
+	feed, err := s.db.GetFeed(cmd.ctx, canonicalUrl(cmd.ctx, s, cmd.arguments[0]))
+
+	if err != nil {
+		return nil, errors.New("could not get specified feed.")
+	}
+
+	err = s.db.SetFeedFetchFullContent(cmd.ctx, database.SetFeedFetchFullContentParams{
+		ID:               feed.ID,
+		FetchFullContent: enabled,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not update feed %v", err)
+	}
+
+	return messageResult{Message: fmt.Sprintf("full article fetching for %v turned %v", feed.Url, cmd.arguments[1])}, nil
+}
+
+func handlerRefetch(s *state, cmd command) (any, error) {
+	post, err := lookupPost(cmd.ctx, s, cmd.arguments[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := storeFullContent(cmd.ctx, s, post.ID, post.Url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return messageResult{Message: fmt.Sprintf("refetched %v, %d characters of content", post.Url, len(content))}, nil
+}
+
+// storeFullContent downloads the article at link and saves its main content
+// as the full content of the post.
+func storeFullContent(ctx context.Context, s *state, postID uuid.UUID, link string) (string, error) {
+	content, err := fetchArticle(ctx, link)
+
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.SetPostContent(ctx, database.SetPostContentParams{
+		ID: postID,
+		Content: sql.NullString{
+			String: content,
+			Valid:  true,
+		},
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("could not save post content %v", err)
+	}
+
+	return content, nil
+}
+
+// fetchArticle downloads the page at link and extracts its main content as
+// sanitized HTML.
+func fetchArticle(ctx context.Context, link string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+
+	if err != nil {
+		return "", fmt.Errorf("error fetching article: %v", err)
+	}
+
+	request.Header.Set("User-Agent", "gator")
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		return "", fmt.Errorf("error fetching article: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return "", fmt.Errorf("article request failed with status code: %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return "", fmt.Errorf("article is %v, not html", contentType)
+	}
+
+	article, err := readability.Extract(io.LimitReader(resp.Body, maxArticleSize), resp.Request.URL)
+
+	if err != nil {
+		return "", fmt.Errorf("could not extract article from %v: %v", link, err)
+	}
+
+	return article.Content, nil
+}
@@ -5,4 +5,9 @@ go 1.23.5
 require (
 	github.com/google/uuid v1.6.0
 	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.41.0
+	golang.org/x/net v0.43.0
+	golang.org/x/term v0.34.0
 )
+
+require golang.org/x/sys v0.35.0 // indirect
@@ -0,0 +1,1014 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+const (
+	greaderSessionDuration = 365 * 24 * time.Hour
+	greaderDefaultCount    = 20
+	greaderMaxItems        = 1000
+	greaderMaxItemIDs      = 10000
+)
+
+// Stream and tag ids of the Google Reader API. Clients may also send them
+// with their user id in place of the dash.
+const (
+	greaderReadingList = "user/-/state/com.google/reading-list"
+	greaderRead        = "user/-/state/com.google/read"
+	greaderStarred     = "user/-/state/com.google/starred"
+	greaderKeptUnread  = "user/-/state/com.google/kept-unread"
+	greaderLabelPrefix = "user/-/label/"
+	greaderFeedPrefix  = "feed/"
+	greaderItemPrefix  = "tag:google.com,2005:reader/item/"
+)
+
+var errStreamNotFound = errors.New("stream not found")
+
+type greaderSubscription struct {
+	ID         string            `json:"id"`
+	Title      string            `json:"title"`
+	Categories []greaderCategory `json:"categories"`
+	Url        string            `json:"url"`
+	HtmlUrl    string            `json:"htmlUrl"`
+	IconUrl    string            `json:"iconUrl"`
+}
+
+type greaderCategory struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type greaderTag struct {
+	ID   string `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderUnreadCount struct {
+	ID    string `json:"id"`
+	Count int64  `json:"count"`
+}
+
+type greaderItemRef struct {
+	ID string `json:"id"`
+}
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderOrigin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HtmlUrl  string `json:"htmlUrl"`
+}
+
+type greaderContent struct {
+	Direction string `json:"direction"`
+	Content   string `json:"content"`
+}
+
+type greaderItem struct {
+	ID            string         `json:"id"`
+	CrawlTimeMsec string         `json:"crawlTimeMsec"`
+	TimestampUsec string         `json:"timestampUsec"`
+	Published     int64          `json:"published"`
+	Updated       int64          `json:"updated"`
+	Title         string         `json:"title"`
+	Author        string         `json:"author,omitempty"`
+	Canonical     []greaderLink  `json:"canonical"`
+	Alternate     []greaderLink  `json:"alternate"`
+	Categories    []string       `json:"categories"`
+	Origin        greaderOrigin  `json:"origin"`
+	Summary       greaderContent `json:"summary"`
+}
+
+type greaderStream struct {
+	Direction    string        `json:"direction"`
+	ID           string        `json:"id"`
+	Title        string        `json:"title,omitempty"`
+	Updated      int64         `json:"updated"`
+	Items        []greaderItem `json:"items"`
+	Continuation string        `json:"continuation,omitempty"`
+}
+
+// greaderFilter is what a stream id selects among the followed feeds.
+type greaderFilter struct {
+	FeedID      uuid.NullUUID
+	Folder      sql.NullString
+	StarredOnly bool
+	feeds       []uuid.UUID
+}
+
+// routeGReader registers the Google Reader API used by clients like
+// NetNewsWire or FeedMe. They sign in through ClientLogin with a gator
+// user name and the password set with passwd.
+func (srv *server) routeGReader() {
+	srv.mux.HandleFunc("/accounts/ClientLogin", srv.greaderLogin)
+	srv.mux.HandleFunc("GET /reader/api/0/token", srv.greaderAuthenticated(srv.greaderToken))
+	srv.mux.HandleFunc("GET /reader/api/0/user-info", srv.greaderAuthenticated(srv.greaderUserInfo))
+	srv.mux.HandleFunc("GET /reader/api/0/subscription/list", srv.greaderAuthenticated(srv.greaderSubscriptions))
+	srv.mux.HandleFunc("GET /reader/api/0/tag/list", srv.greaderAuthenticated(srv.greaderTags))
+	srv.mux.HandleFunc("GET /reader/api/0/unread-count", srv.greaderAuthenticated(srv.greaderUnreadCounts))
+	srv.mux.HandleFunc("GET /reader/api/0/stream/items/ids", srv.greaderAuthenticated(srv.greaderItemIDs))
+	srv.mux.HandleFunc("GET /reader/api/0/stream/contents", srv.greaderAuthenticated(srv.greaderStreamContents))
+	srv.mux.HandleFunc("GET /reader/api/0/stream/contents/{stream...}", srv.greaderAuthenticated(srv.greaderStreamContents))
+	srv.mux.HandleFunc("/reader/api/0/stream/items/contents", srv.greaderAuthenticated(srv.greaderItemContents))
+	srv.mux.HandleFunc("POST /reader/api/0/edit-tag", srv.greaderAuthenticated(srv.greaderEditTag))
+	srv.mux.HandleFunc("POST /reader/api/0/subscription/edit", srv.greaderAuthenticated(srv.greaderEditSubscription))
+	srv.mux.HandleFunc("POST /reader/api/0/subscription/quickadd", srv.greaderAuthenticated(srv.greaderQuickAdd))
+	srv.mux.HandleFunc("POST /reader/api/0/mark-all-as-read", srv.greaderAuthenticated(srv.greaderMarkAllRead))
+}
+
+// greaderLogin checks Email and Passwd and answers with an auth token for
+// the GoogleLogin Authorization header. Tokens are stored as sessions.
+func (srv *server) greaderLogin(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+
+	err := r.ParseForm()
+
+	if err != nil {
+		http.Error(w, "Error=BadRequest", http.StatusBadRequest)
+		return
+	}
+
+	user, ok, err := srv.checkPassword(r.Context(), strings.TrimSpace(r.Form.Get("Email")), r.Form.Get("Passwd"))
+
+	if err != nil {
+		webInternalError(w, "could not get user", err)
+		return
+	}
+
+	if !ok {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := srv.newSession(r.Context(), user, time.Now().Add(greaderSessionDuration))
+
+	if err != nil {
+		webInternalError(w, "could not create session", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "SID=%v\nLSID=null\nAuth=%v\n", token, token)
+}
+
+// greaderAuthenticated resolves the user of a request from its
+// "GoogleLogin auth=" Authorization header.
+func (srv *server) greaderAuthenticated(handler func(w http.ResponseWriter, r *http.Request, user database.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+
+		if !ok || strings.TrimSpace(token) == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := srv.s.db.GetUserBySession(r.Context(), hashToken(strings.TrimSpace(token)))
+
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err != nil {
+			webInternalError(w, "could not check session", err)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+
+		err = r.ParseForm()
+
+		if err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+
+		handler(w, r, user)
+	}
+}
+
+// greaderToken hands out the T token clients send with edits. Edits are
+// authenticated by the Authorization header, which browsers never add on
+// their own, so the token is not checked.
+func (srv *server) greaderToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	token, err := randomToken()
+
+	if err != nil {
+		webInternalError(w, "could not create token", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, token)
+}
+
+func (srv *server) greaderUserInfo(w http.ResponseWriter, r *http.Request, user database.User) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"userId":        user.ID.String(),
+		"userName":      user.Name,
+		"userProfileId": user.ID.String(),
+		"userEmail":     "",
+	})
+}
+
+func (srv *server) greaderSubscriptions(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, ok := srv.greaderFeeds(w, r, user)
+
+	if !ok {
+		return
+	}
+
+	subscriptions := make([]greaderSubscription, 0, len(feeds))
+
+	for _, feed := range feeds {
+		subscription := greaderSubscription{
+			ID:         greaderFeedID(feed.Seq),
+			Title:      feed.Name,
+			Categories: []greaderCategory{},
+			Url:        feed.Url,
+			HtmlUrl:    feed.SiteUrl.String,
+		}
+
+		if feed.Folder.String != "" {
+			subscription.Categories = append(subscription.Categories, greaderCategory{
+				ID:    greaderLabelPrefix + feed.Folder.String,
+				Label: feed.Folder.String,
+			})
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"subscriptions": subscriptions})
+}
+
+// greaderTags lists the starred state and the folders, which clients show
+// as labels.
+func (srv *server) greaderTags(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, ok := srv.greaderFeeds(w, r, user)
+
+	if !ok {
+		return
+	}
+
+	tags := []greaderTag{{ID: greaderStarred}}
+	seen := map[string]bool{}
+
+	for _, feed := range feeds {
+		if feed.Folder.String == "" || seen[feed.Folder.String] {
+			continue
+		}
+
+		seen[feed.Folder.String] = true
+		tags = append(tags, greaderTag{
+			ID:   greaderLabelPrefix + feed.Folder.String,
+			Type: "folder",
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"tags": tags})
+}
+
+func (srv *server) greaderUnreadCounts(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, ok := srv.greaderFeeds(w, r, user)
+
+	if !ok {
+		return
+	}
+
+	counts, err := srv.s.db.GetUnreadCountsForUser(r.Context(), uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	})
+
+	if err != nil {
+		webInternalError(w, "could not get unread counts", err)
+		return
+	}
+
+	unread := make(map[uuid.UUID]int64, len(counts))
+
+	for _, count := range counts {
+		unread[count.FeedID] = count.Unread
+	}
+
+	var total int64
+	folders := map[string]int64{}
+	list := []greaderUnreadCount{}
+
+	for _, feed := range feeds {
+		total += unread[feed.ID]
+
+		if feed.Folder.String != "" {
+			folders[feed.Folder.String] += unread[feed.ID]
+		}
+
+		list = append(list, greaderUnreadCount{
+			ID:    greaderFeedID(feed.Seq),
+			Count: unread[feed.ID],
+		})
+	}
+
+	for folder, count := range folders {
+		list = append(list, greaderUnreadCount{
+			ID:    greaderLabelPrefix + folder,
+			Count: count,
+		})
+	}
+
+	list = append(list, greaderUnreadCount{
+		ID:    greaderReadingList,
+		Count: total,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"max":          total,
+		"unreadcounts": list,
+	})
+}
+
+// greaderItemIDs lists the ids of a stream, which clients use to sync read
+// and starred state.
+func (srv *server) greaderItemIDs(w http.ResponseWriter, r *http.Request, user database.User) {
+	params, ok := srv.greaderStreamParams(w, r, user, r.Form.Get("s"), greaderMaxItemIDs)
+
+	if !ok {
+		return
+	}
+
+	seqs, err := srv.s.db.ListStreamPostSeqsForUser(r.Context(), database.ListStreamPostSeqsForUserParams{
+		UserID:      params.UserID,
+		FeedID:      params.FeedID,
+		Folder:      params.Folder,
+		UnreadOnly:  params.UnreadOnly,
+		StarredOnly: params.StarredOnly,
+		NewerThan:   params.NewerThan,
+		OlderThan:   params.OlderThan,
+		OldestFirst: params.OldestFirst,
+		MaxPosts:    params.MaxPosts,
+		SkipPosts:   params.SkipPosts,
+	})
+
+	if err != nil {
+		webInternalError(w, "could not get items", err)
+		return
+	}
+
+	refs := make([]greaderItemRef, 0, len(seqs))
+
+	for _, seq := range seqs {
+		refs = append(refs, greaderItemRef{ID: strconv.FormatInt(seq, 10)})
+	}
+
+	response := map[string]any{"itemRefs": refs}
+
+	if len(seqs) == int(params.MaxPosts) {
+		response["continuation"] = strconv.Itoa(int(params.SkipPosts + params.MaxPosts))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (srv *server) greaderStreamContents(w http.ResponseWriter, r *http.Request, user database.User) {
+	streamID := r.PathValue("stream")
+
+	if streamID == "" {
+		streamID = r.Form.Get("s")
+	}
+
+	params, ok := srv.greaderStreamParams(w, r, user, streamID, greaderMaxItems)
+
+	if !ok {
+		return
+	}
+
+	posts, err := srv.s.db.ListStreamPostsForUser(r.Context(), params)
+
+	if err != nil {
+		webInternalError(w, "could not get items", err)
+		return
+	}
+
+	stream := greaderStreamFromPosts(streamID, posts)
+
+	if len(posts) == int(params.MaxPosts) {
+		stream.Continuation = strconv.Itoa(int(params.SkipPosts + params.MaxPosts))
+	}
+
+	writeJSON(w, http.StatusOK, stream)
+}
+
+// greaderItemContents returns the items named by the i form values.
+func (srv *server) greaderItemContents(w http.ResponseWriter, r *http.Request, user database.User) {
+	seqs, err := greaderItemSeqs(r.Form["i"])
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(seqs) == 0 || len(seqs) > greaderMaxItems {
+		http.Error(w, fmt.Sprintf("between 1 and %d items can be fetched at once", greaderMaxItems), http.StatusBadRequest)
+		return
+	}
+
+	posts, err := srv.s.db.ListStreamPostsForUser(r.Context(), database.ListStreamPostsForUserParams{
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+		Seqs:     seqs,
+		MaxPosts: int32(len(seqs)),
+	})
+
+	if err != nil {
+		webInternalError(w, "could not get items", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, greaderStreamFromPosts(greaderReadingList, posts))
+}
+
+// greaderEditTag adds (a) and removes (r) the read and starred tags of the
+// items named by i. Other tags are ignored.
+func (srv *server) greaderEditTag(w http.ResponseWriter, r *http.Request, user database.User) {
+	seqs, err := greaderItemSeqs(r.Form["i"])
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Like the contents of items, only the posts of followed feeds can be
+	// tagged, others are skipped as missing.
+	for _, seq := range seqs {
+		post, err := srv.s.db.GetPostBySeqForUser(r.Context(), database.GetPostBySeqForUserParams{
+			Seq: seq,
+			UserID: uuid.NullUUID{
+				UUID:  user.ID,
+				Valid: true,
+			},
+		})
+
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+
+		if err != nil {
+			webInternalError(w, "could not get item", err)
+			return
+		}
+
+		for _, change := range []struct {
+			tags []string
+			add  bool
+		}{
+			{r.Form["a"], true},
+			{r.Form["r"], false},
+		} {
+			for _, tag := range change.tags {
+				err = srv.greaderApplyTag(r.Context(), user, post.ID, greaderNormalize(tag), change.add)
+
+				if err != nil {
+					webInternalError(w, "could not update item", err)
+					return
+				}
+			}
+		}
+	}
+
+	writeOK(w)
+}
+
+func (srv *server) greaderApplyTag(ctx context.Context, user database.User, postID uuid.UUID, tag string, add bool) error {
+	switch {
+	case tag == greaderRead && add, tag == greaderKeptUnread && !add:
+		return srv.s.db.MarkPostRead(ctx, database.MarkPostReadParams{
+			ID:     uuid.New(),
+			UserID: user.ID,
+			PostID: postID,
+		})
+	case tag == greaderRead, tag == greaderKeptUnread:
+		return srv.s.db.MarkPostUnread(ctx, database.MarkPostUnreadParams{
+			UserID: user.ID,
+			PostID: postID,
+		})
+	case tag == greaderStarred:
+		return srv.s.db.SetPostStarred(ctx, database.SetPostStarredParams{
+			ID:      uuid.New(),
+			UserID:  user.ID,
+			PostID:  postID,
+			Starred: add,
+		})
+	}
+
+	return nil
+}
+
+// greaderEditSubscription follows (ac=subscribe), unfollows
+// (ac=unsubscribe) or moves (ac=edit) the feeds named by s. Labels added
+// with a and removed with r set the folder. Titles are shared by every
+// user, so t only names feeds gator did not know yet.
+func (srv *server) greaderEditSubscription(w http.ResponseWriter, r *http.Request, user database.User) {
+	action := r.Form.Get("ac")
+
+	for _, streamID := range r.Form["s"] {
+		var err error
+
+		switch action {
+		case "subscribe":
+			_, err = srv.greaderSubscribe(r.Context(), user, strings.TrimPrefix(streamID, greaderFeedPrefix), r.Form.Get("t"), r.Form.Get("a"))
+		case "unsubscribe":
+			err = srv.greaderUnsubscribe(r.Context(), user, streamID)
+		case "edit":
+			err = srv.greaderMove(r.Context(), user, streamID, r.Form.Get("a"), r.Form.Get("r"))
+		default:
+			http.Error(w, "ac must be subscribe, unsubscribe or edit", http.StatusBadRequest)
+			return
+		}
+
+		if errors.Is(err, errStreamNotFound) {
+			http.Error(w, fmt.Sprintf("not following %v", streamID), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			webInternalError(w, "could not edit subscription", err)
+			return
+		}
+	}
+
+	writeOK(w)
+}
+
+func (srv *server) greaderQuickAdd(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedUrl := strings.TrimPrefix(r.Form.Get("quickadd"), greaderFeedPrefix)
+
+	if feedUrl == "" {
+		http.Error(w, "missing quickadd url", http.StatusBadRequest)
+		return
+	}
+
+	feedID, err := srv.greaderSubscribe(r.Context(), user, feedUrl, "", "")
+
+	if err != nil {
+		webInternalError(w, "could not subscribe", err)
+		return
+	}
+
+	feeds, ok := srv.greaderFeeds(w, r, user)
+
+	if !ok {
+		return
+	}
+
+	for _, feed := range feeds {
+		if feed.ID == feedID {
+			writeJSON(w, http.StatusOK, map[string]any{
+				"numResults": 1,
+				"query":      feedUrl,
+				"streamId":   greaderFeedID(feed.Seq),
+				"streamName": feed.Name,
+			})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"numResults": 0,
+		"query":      feedUrl,
+	})
+}
+
+// greaderMarkAllRead marks the posts of stream s read, up to ts in
+// microseconds when given.
+func (srv *server) greaderMarkAllRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, ok := srv.greaderFeeds(w, r, user)
+
+	if !ok {
+		return
+	}
+
+	filter, err := greaderResolveStream(r.Form.Get("s"), feeds)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	before := time.Now()
+
+	if r.Form.Get("ts") != "" {
+		usec, err := strconv.ParseInt(r.Form.Get("ts"), 10, 64)
+
+		if err != nil {
+			http.Error(w, "ts must be a timestamp in microseconds", http.StatusBadRequest)
+			return
+		}
+
+		before = time.UnixMicro(usec)
+	}
+
+	if len(filter.feeds) > 0 {
+		err = srv.s.db.MarkPostsReadBefore(r.Context(), database.MarkPostsReadBeforeParams{
+			UserID:  user.ID,
+			FeedIds: filter.feeds,
+			Before:  before,
+		})
+
+		if err != nil {
+			webInternalError(w, "could not mark stream read", err)
+			return
+		}
+	}
+
+	writeOK(w)
+}
+
+// greaderSubscribe follows the feed at feedUrl, adding it first when gator
+// does not know it yet, and returns its id.
+func (srv *server) greaderSubscribe(ctx context.Context, user database.User, feedUrl, title, label string) (uuid.UUID, error) {
+	var feedID uuid.UUID
+
+	feed, _, err := followFeed(ctx, srv.s, user, feedUrl)
+
+	switch {
+	case errors.Is(err, errFeedNotFound):
+		if title == "" {
+			title = feedUrl
+		}
+
+		created, err := addFeed(ctx, srv.s, user, title, feedUrl, false)
+
+		if err != nil {
+			return feedID, err
+		}
+
+		feedID = created.ID
+	case err != nil && !errors.Is(err, errAlreadyFollowing):
+		return feedID, err
+	default:
+		feedID = feed.ID
+	}
+
+	label = strings.TrimPrefix(greaderNormalize(label), greaderLabelPrefix)
+
+	if label == "" {
+		return feedID, nil
+	}
+
+	return feedID, srv.setFolder(ctx, user, feedID, label)
+}
+
+func (srv *server) greaderUnsubscribe(ctx context.Context, user database.User, streamID string) error {
+	feedID, err := srv.greaderFollowedFeed(ctx, user, streamID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = srv.s.db.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+		FeedID: uuid.NullUUID{
+			UUID:  feedID,
+			Valid: true,
+		},
+	})
+
+	return err
+}
+
+// greaderMove puts a followed feed in the folder of label add, or takes it
+// out of its folder when a label is only removed.
+func (srv *server) greaderMove(ctx context.Context, user database.User, streamID, add, remove string) error {
+	if add == "" && remove == "" {
+		return nil
+	}
+
+	feedID, err := srv.greaderFollowedFeed(ctx, user, streamID)
+
+	if err != nil {
+		return err
+	}
+
+	return srv.setFolder(ctx, user, feedID, strings.TrimPrefix(greaderNormalize(add), greaderLabelPrefix))
+}
+
+func (srv *server) setFolder(ctx context.Context, user database.User, feedID uuid.UUID, folder string) error {
+	return srv.s.db.SetFeedFollowFolder(ctx, database.SetFeedFollowFolderParams{
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+		FeedID: uuid.NullUUID{
+			UUID:  feedID,
+			Valid: true,
+		},
+		Folder: sql.NullString{
+			String: folder,
+			Valid:  folder != "",
+		},
+	})
+}
+
+// greaderFollowedFeed finds the followed feed of a feed/ stream id.
+func (srv *server) greaderFollowedFeed(ctx context.Context, user database.User, streamID string) (uuid.UUID, error) {
+	feeds, err := srv.s.db.GetFollowedFeedsForUser(ctx, uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	})
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	filter, err := greaderResolveStream(streamID, feeds)
+
+	if err != nil || !filter.FeedID.Valid {
+		return uuid.Nil, errStreamNotFound
+	}
+
+	return filter.FeedID.UUID, nil
+}
+
+func (srv *server) greaderFeeds(w http.ResponseWriter, r *http.Request, user database.User) ([]database.GetFollowedFeedsForUserRow, bool) {
+	feeds, err := srv.s.db.GetFollowedFeedsForUser(r.Context(), uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	})
+
+	if err != nil {
+		webInternalError(w, "could not get feeds", err)
+		return nil, false
+	}
+
+	return feeds, true
+}
+
+// greaderStreamParams reads the stream query of ids and contents requests:
+// n items from continuation c, newest first unless r=o, excluding read
+// items for xt=read and limited to crawl times between ot and nt.
+func (srv *server) greaderStreamParams(w http.ResponseWriter, r *http.Request, user database.User, streamID string, maxCount int) (database.ListStreamPostsForUserParams, bool) {
+	params := database.ListStreamPostsForUserParams{
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+		Seqs:        []int64{},
+		OldestFirst: r.Form.Get("r") == "o",
+	}
+
+	feeds, ok := srv.greaderFeeds(w, r, user)
+
+	if !ok {
+		return params, false
+	}
+
+	filter, err := greaderResolveStream(streamID, feeds)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return params, false
+	}
+
+	params.FeedID = filter.FeedID
+	params.Folder = filter.Folder
+	params.StarredOnly = filter.StarredOnly
+
+	for _, excluded := range r.Form["xt"] {
+		if greaderNormalize(excluded) == greaderRead {
+			params.UnreadOnly = true
+		}
+	}
+
+	for _, included := range r.Form["it"] {
+		if greaderNormalize(included) == greaderStarred {
+			params.StarredOnly = true
+		}
+	}
+
+	count, err := queryInt(r.Form.Get("n"), greaderDefaultCount)
+
+	if err != nil || count < 1 {
+		http.Error(w, "n must be a positive number", http.StatusBadRequest)
+		return params, false
+	}
+
+	offset, err := queryInt(r.Form.Get("c"), 0)
+
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid continuation", http.StatusBadRequest)
+		return params, false
+	}
+
+	params.MaxPosts = int32(min(count, maxCount))
+	params.SkipPosts = int32(offset)
+
+	for name, bound := range map[string]*sql.NullTime{
+		"ot": &params.NewerThan,
+		"nt": &params.OlderThan,
+	} {
+		if r.Form.Get(name) == "" {
+			continue
+		}
+
+		seconds, err := strconv.ParseInt(r.Form.Get(name), 10, 64)
+
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%v must be a unix timestamp", name), http.StatusBadRequest)
+			return params, false
+		}
+
+		*bound = sql.NullTime{
+			Time:  time.Unix(seconds, 0),
+			Valid: true,
+		}
+	}
+
+	return params, true
+}
+
+// greaderResolveStream maps a stream id onto the followed feeds: the
+// reading list, starred items, a label (folder) or a feed, by the id
+// subscription/list gives or by url.
+func greaderResolveStream(streamID string, feeds []database.GetFollowedFeedsForUserRow) (greaderFilter, error) {
+	var filter greaderFilter
+
+	streamID = greaderNormalize(streamID)
+
+	switch {
+	case streamID == "", streamID == greaderReadingList, streamID == greaderStarred:
+		filter.StarredOnly = streamID == greaderStarred
+
+		for _, feed := range feeds {
+			filter.feeds = append(filter.feeds, feed.ID)
+		}
+
+		return filter, nil
+	case strings.HasPrefix(streamID, greaderLabelPrefix):
+		folder := strings.TrimPrefix(streamID, greaderLabelPrefix)
+		filter.Folder = sql.NullString{
+			String: folder,
+			Valid:  true,
+		}
+
+		for _, feed := range feeds {
+			if feed.Folder.String == folder {
+				filter.feeds = append(filter.feeds, feed.ID)
+			}
+		}
+
+		return filter, nil
+	case strings.HasPrefix(streamID, greaderFeedPrefix):
+		id := strings.TrimPrefix(streamID, greaderFeedPrefix)
+
+		for _, feed := range feeds {
+			if strconv.FormatInt(feed.Seq, 10) == id || feed.Url == id {
+				filter.FeedID = uuid.NullUUID{
+					UUID:  feed.ID,
+					Valid: true,
+				}
+				filter.feeds = []uuid.UUID{feed.ID}
+
+				return filter, nil
+			}
+		}
+	}
+
+	return filter, fmt.Errorf("%w: %v", errStreamNotFound, streamID)
+}
+
+func greaderStreamFromPosts(streamID string, posts []database.ListStreamPostsForUserRow) greaderStream {
+	stream := greaderStream{
+		Direction: "ltr",
+		ID:        greaderNormalize(streamID),
+		Updated:   time.Now().Unix(),
+		Items:     make([]greaderItem, 0, len(posts)),
+	}
+
+	if stream.ID == "" {
+		stream.ID = greaderReadingList
+	}
+
+	for _, post := range posts {
+		stream.Items = append(stream.Items, greaderItemFromPost(post))
+	}
+
+	return stream
+}
+
+func greaderItemFromPost(post database.ListStreamPostsForUserRow) greaderItem {
+	content := post.Content.String
+
+	if content == "" {
+		content = post.Description.String
+	}
+
+	published := post.CreatedAt
+
+	if post.PublishedAt.Valid {
+		published = post.PublishedAt.Time
+	}
+
+	categories := []string{greaderReadingList}
+
+	if post.Folder.String != "" {
+		categories = append(categories, greaderLabelPrefix+post.Folder.String)
+	}
+
+	if post.ReadAt.Valid {
+		categories = append(categories, greaderRead)
+	}
+
+	if post.Starred {
+		categories = append(categories, greaderStarred)
+	}
+
+	return greaderItem{
+		ID:            fmt.Sprintf("%v%016x", greaderItemPrefix, post.Seq),
+		CrawlTimeMsec: strconv.FormatInt(post.CreatedAt.UnixMilli(), 10),
+		TimestampUsec: strconv.FormatInt(post.CreatedAt.UnixMicro(), 10),
+		Published:     published.Unix(),
+		Updated:       post.UpdatedAt.Unix(),
+		Title:         post.Title,
+		Author:        post.Author.String,
+		Canonical:     []greaderLink{{Href: post.Url}},
+		Alternate:     []greaderLink{{Href: post.Url, Type: "text/html"}},
+		Categories:    categories,
+		Origin: greaderOrigin{
+			StreamID: greaderFeedID(post.FeedSeq),
+			Title:    post.FeedName,
+			HtmlUrl:  post.FeedSiteUrl.String,
+		},
+		Summary: greaderContent{
+			Direction: "ltr",
+			Content:   content,
+		},
+	}
+}
+
+// greaderItemSeqs parses item ids in the long form
+// tag:google.com,2005:reader/item/<hex> or as decimal numbers.
+func greaderItemSeqs(ids []string) ([]int64, error) {
+	seqs := make([]int64, 0, len(ids))
+
+	for _, id := range ids {
+		var seq int64
+		var err error
+
+		if hexID, ok := strings.CutPrefix(id, greaderItemPrefix); ok {
+			var value uint64
+			value, err = strconv.ParseUint(hexID, 16, 64)
+			seq = int64(value)
+		} else {
+			seq, err = strconv.ParseInt(id, 10, 64)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid item id %v", id)
+		}
+
+		seqs = append(seqs, seq)
+	}
+
+	return seqs, nil
+}
+
+// greaderNormalize replaces the user id in user/<id>/... stream ids with
+// the dash gator uses.
+func greaderNormalize(streamID string) string {
+	parts := strings.SplitN(streamID, "/", 3)
+
+	if len(parts) == 3 && parts[0] == "user" {
+		return "user/-/" + parts[2]
+	}
+
+	return streamID
+}
+
+func greaderFeedID(seq int64) string {
+	return greaderFeedPrefix + strconv.FormatInt(seq, 10)
+}
+
+func writeOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "OK")
+}
@@ -0,0 +1,250 @@
+// Package canonical normalizes urls so the same article or feed is stored
+// once whatever tracking parameters or redirectors it arrived through.
+package canonical
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds following a redirector link when no client is
+// given, so a slow redirector cannot hold up the feed its link is in.
+const DefaultTimeout = 10 * time.Second
+
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// DefaultTrackingParams are removed from query strings when no list is
+// configured. A trailing * matches any parameter with that prefix.
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_hsenc",
+	"_hsmi",
+	"mkt_tok",
+	"ref",
+	"ref_src",
+	"ref_url",
+	"xtor",
+	"__twitter_impression",
+	"cmpid",
+	"ncid",
+	"sr_share",
+}
+
+// redirectors are hosts whose item links only redirect to the article.
+var redirectors = map[string]bool{
+	"feedproxy.google.com":  true,
+	"feeds.feedburner.com":  true,
+	"feedburner.google.com": true,
+	"feeds.feedblitz.com":   true,
+}
+
+type Canonicalizer struct {
+	// TrackingParams are the query parameters to strip.
+	TrackingParams []string
+	// Client follows redirector links; a client with DefaultTimeout when
+	// nil.
+	Client *http.Client
+}
+
+// New returns a Canonicalizer stripping params, or DefaultTrackingParams
+// when params is empty.
+func New(params []string) *Canonicalizer {
+	if len(params) == 0 {
+		params = DefaultTrackingParams
+	}
+
+	return &Canonicalizer{TrackingParams: params}
+}
+
+// Clean normalizes raw without touching the network: the scheme and host are
+// lowercased, default ports and tracking parameters are removed, the
+// remaining parameters are sorted and an empty path becomes /.
+func (c *Canonicalizer) Clean(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+
+	if err != nil {
+		return "", err
+	}
+
+	if !parsed.IsAbs() || parsed.Host == "" {
+		return parsed.String(), nil
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+
+	host, port := parsed.Hostname(), parsed.Port()
+
+	if (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		parsed.Host = host
+
+		if strings.Contains(host, ":") {
+			parsed.Host = "[" + host + "]"
+		}
+	}
+
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+
+	parsed.RawQuery = c.cleanQuery(parsed.RawQuery)
+
+	if c.tracking(fragmentKey(parsed.Fragment)) {
+		parsed.Fragment = ""
+	}
+
+	return parsed.String(), nil
+}
+
+// Canonicalize cleans raw and, when it points to a feed redirector such as
+// feedproxy.google.com, follows the redirect to the article first.
+func (c *Canonicalizer) Canonicalize(ctx context.Context, raw string) (string, error) {
+	cleaned, err := c.Clean(raw)
+
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := url.Parse(cleaned)
+
+	if err != nil || !isRedirector(parsed) {
+		return cleaned, err
+	}
+
+	resolved, err := c.follow(ctx, cleaned)
+
+	if err != nil {
+		return cleaned, nil
+	}
+
+	return c.Clean(resolved)
+}
+
+func (c *Canonicalizer) follow(ctx context.Context, link string) (string, error) {
+	client := c.Client
+
+	if client == nil {
+		client = defaultClient
+	}
+
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		request, err := http.NewRequestWithContext(ctx, method, link, nil)
+
+		if err != nil {
+			return "", err
+		}
+
+		request.Header.Set("User-Agent", "gator")
+
+		resp, err := client.Do(request)
+
+		if err != nil {
+			return "", err
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return resp.Request.URL.String(), nil
+		}
+	}
+
+	return link, nil
+}
+
+// Redirects reports whether a cleaned link goes through a feed redirector,
+// which Canonicalize follows over the network.
+func Redirects(cleaned string) bool {
+	parsed, err := url.Parse(cleaned)
+
+	return err == nil && isRedirector(parsed)
+}
+
+// isRedirector reports whether an item link goes through a feed redirector.
+// feeds.feedburner.com also serves feeds themselves, so only its /~r/ item
+// links count.
+func isRedirector(link *url.URL) bool {
+	host := link.Hostname()
+
+	if !redirectors[host] {
+		return false
+	}
+
+	if host == "feedproxy.google.com" {
+		return true
+	}
+
+	return strings.HasPrefix(link.Path, "/~r/") || strings.HasPrefix(link.Path, "/~/")
+}
+
+func (c *Canonicalizer) cleanQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	kept := []string{}
+
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+
+		if err != nil {
+			name = key
+		}
+
+		if !c.tracking(name) {
+			kept = append(kept, pair)
+		}
+	}
+
+	sort.Strings(kept)
+
+	return strings.Join(kept, "&")
+}
+
+func (c *Canonicalizer) tracking(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	name = strings.ToLower(name)
+
+	for _, param := range c.TrackingParams {
+		param = strings.ToLower(param)
+
+		if matched, _ := path.Match(param, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fragmentKey returns the parameter name of fragments like
+// #utm_source=x&utm_medium=y, which some sites use for tracking.
+func fragmentKey(fragment string) string {
+	if !strings.Contains(fragment, "=") {
+		return ""
+	}
+
+	key, _, _ := strings.Cut(fragment, "=")
+
+	return key
+}
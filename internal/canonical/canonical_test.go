@@ -0,0 +1,188 @@
+package canonical
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClean(t *testing.T) {
+	c := New(nil)
+
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "https://go.dev/blog/go1.23?utm_source=rss&utm_medium=feed", want: "https://go.dev/blog/go1.23"},
+		{raw: "https://go.dev/blog?fbclid=1&page=2&gclid=2", want: "https://go.dev/blog?page=2"},
+		{raw: "https://go.dev/blog?UTM_Campaign=x&b=2&a=1", want: "https://go.dev/blog?a=1&b=2"},
+		{raw: "https://go.dev/blog?utm%5Fsource=x", want: "https://go.dev/blog"},
+		{raw: "https://go.dev/blog#utm_source=x&utm_medium=y", want: "https://go.dev/blog"},
+		{raw: "https://go.dev/blog#section", want: "https://go.dev/blog#section"},
+		{raw: "HTTPS://Go.DEV/Blog", want: "https://go.dev/Blog"},
+		{raw: "https://go.dev:443/blog", want: "https://go.dev/blog"},
+		{raw: "http://go.dev:80/blog", want: "http://go.dev/blog"},
+		{raw: "http://go.dev:8080/blog", want: "http://go.dev:8080/blog"},
+		{raw: "https://[::1]:443/blog", want: "https://[::1]/blog"},
+		{raw: "https://go.dev", want: "https://go.dev/"},
+		{raw: "  https://go.dev/blog  ", want: "https://go.dev/blog"},
+		{raw: "/relative/path?utm_source=x", want: "/relative/path?utm_source=x"},
+	}
+
+	for _, test := range tests {
+		got, err := c.Clean(test.raw)
+
+		if err != nil {
+			t.Errorf("Clean(%q): %v", test.raw, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("Clean(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestCleanConfiguredParams(t *testing.T) {
+	c := New([]string{"source", "share_*"})
+
+	got, err := c.Clean("https://go.dev/blog?source=rss&share_id=1&utm_source=x")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "https://go.dev/blog?utm_source=x"; got != want {
+		t.Errorf("Clean = %q, want %q", got, want)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{link: "http://feedproxy.google.com/~r/GoBlog/~3/abc/", want: true},
+		{link: "https://feeds.feedburner.com/~r/GoBlog/~3/abc/", want: true},
+		{link: "https://feeds.feedblitz.com/~/123/0/blog", want: true},
+		{link: "https://feeds.feedburner.com/GoBlog", want: false},
+		{link: "https://go.dev/blog/go1.23", want: false},
+	}
+
+	for _, test := range tests {
+		if got := Redirects(test.link); got != test.want {
+			t.Errorf("Redirects(%q) = %v, want %v", test.link, got, test.want)
+		}
+	}
+}
+
+// redirectorClient returns a client that sends every request to handler,
+// whatever its host, so redirector links can be followed locally.
+func redirectorClient(t *testing.T, handler http.HandlerFunc, timeout time.Duration) *http.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			},
+		},
+	}
+}
+
+func TestCanonicalizeFollowsRedirectors(t *testing.T) {
+	const article = "http://blog.example.com/post?utm_source=feedburner&id=7"
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		timeout time.Duration
+		link    string
+		want    string
+	}{
+		{
+			name: "redirect",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Host == "feedproxy.google.com" {
+					http.Redirect(w, r, article, http.StatusMovedPermanently)
+				}
+			},
+			link: "http://feedproxy.google.com/~r/blog/~3/abc/?utm_medium=rss",
+			want: "http://blog.example.com/post?id=7",
+		},
+		{
+			name: "head not allowed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodHead:
+					w.WriteHeader(http.StatusMethodNotAllowed)
+				case r.Host == "feeds.feedburner.com":
+					http.Redirect(w, r, article, http.StatusFound)
+				}
+			},
+			link: "http://feeds.feedburner.com/~r/blog/~3/abc/",
+			want: "http://blog.example.com/post?id=7",
+		},
+		{
+			name: "redirector failing",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			link: "http://feedproxy.google.com/~r/blog/~3/abc/?utm_medium=rss",
+			want: "http://feedproxy.google.com/~r/blog/~3/abc/",
+		},
+		{
+			name: "redirector too slow",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			},
+			timeout: 50 * time.Millisecond,
+			link:    "http://feedproxy.google.com/~r/blog/~3/abc/",
+			want:    "http://feedproxy.google.com/~r/blog/~3/abc/",
+		},
+		{
+			name: "not a redirector",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("not a redirector: %v %v was requested", r.Method, r.URL)
+			},
+			link: "http://go.dev/blog?utm_source=x",
+			want: "http://go.dev/blog",
+		},
+	}
+
+	for _, test := range tests {
+		c := New(nil)
+		c.Client = redirectorClient(t, test.handler, test.timeout)
+
+		start := time.Now()
+
+		got, err := c.Canonicalize(context.Background(), test.link)
+
+		if err != nil {
+			t.Errorf("%v: Canonicalize: %v", test.name, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("%v: Canonicalize(%q) = %q, want %q", test.name, test.link, got, test.want)
+		}
+
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%v: Canonicalize took %v", test.name, elapsed)
+		}
+	}
+}
+
+func TestDefaultClientHasTimeout(t *testing.T) {
+	if defaultClient.Timeout != DefaultTimeout || DefaultTimeout <= 0 {
+		t.Errorf("default client timeout = %v, want %v", defaultClient.Timeout, DefaultTimeout)
+	}
+}
@@ -0,0 +1,135 @@
+// Package cluster groups posts from different feeds that cover the same
+// story. Posts match when their canonical urls are equal or when the SimHash
+// fingerprints of their title and text are within a few bits of each other.
+package cluster
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/htmltext"
+)
+
+// MaxDistance is the largest number of differing fingerprint bits for two
+// posts to count as the same story.
+const MaxDistance = 6
+
+// titleWeight makes title words count more than body words, since feeds
+// often share a headline but not their teaser.
+const titleWeight = 3
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "has": true, "have": true,
+	"in": true, "is": true, "it": true, "its": true, "of": true, "on": true,
+	"or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"were": true, "will": true, "with": true, "after": true, "over": true,
+	"says": true, "said": true, "new": true,
+}
+
+// Candidate is a recent post a new one may be a duplicate of.
+type Candidate struct {
+	ID          uuid.UUID
+	ClusterID   uuid.UUID
+	FeedID      uuid.UUID
+	Url         string
+	Fingerprint uint64
+}
+
+// Fingerprint computes the SimHash of a post from its title and its HTML
+// content, over single words and pairs of words.
+func Fingerprint(title, content string) uint64 {
+	var weights [64]int
+
+	add := func(feature string, weight int) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit] += weight
+			} else {
+				weights[bit] -= weight
+			}
+		}
+	}
+
+	addText := func(text string, weight int) {
+		words := tokens(text)
+
+		for i, word := range words {
+			add(word, weight)
+
+			if i > 0 {
+				add(words[i-1]+" "+word, weight)
+			}
+		}
+	}
+
+	addText(title, titleWeight)
+	addText(htmltext.Plain(content), 1)
+
+	var fingerprint uint64
+
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+
+	return fingerprint
+}
+
+// Distance is the number of bits that differ between two fingerprints.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Match returns the cluster a post with url and fingerprint belongs to among
+// the candidates of other feeds, preferring an exact url match over the
+// closest fingerprint.
+func Match(feedID uuid.UUID, url string, fingerprint uint64, candidates []Candidate) (uuid.UUID, bool) {
+	best := MaxDistance + 1
+	var cluster uuid.UUID
+
+	for _, candidate := range candidates {
+		if candidate.FeedID == feedID {
+			continue
+		}
+
+		if url != "" && candidate.Url == url {
+			return candidate.ClusterID, true
+		}
+
+		distance := Distance(fingerprint, candidate.Fingerprint)
+
+		if distance < best {
+			best = distance
+			cluster = candidate.ClusterID
+		}
+	}
+
+	return cluster, best <= MaxDistance
+}
+
+// tokens lowercases text and splits it into words, leaving out stop words
+// and single characters.
+func tokens(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	kept := words[:0]
+
+	for _, word := range words {
+		if len([]rune(word)) > 1 && !stopWords[word] {
+			kept = append(kept, word)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,143 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{a: 0, b: 0, want: 0},
+		{a: 0b1011, b: 0b0001, want: 2},
+		{a: 0, b: ^uint64(0), want: 64},
+	}
+
+	for _, test := range tests {
+		if got := Distance(test.a, test.b); got != test.want {
+			t.Errorf("Distance(%b, %b) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+// flip returns fingerprint with its lowest n bits inverted, n bits away.
+func flip(fingerprint uint64, n int) uint64 {
+	return fingerprint ^ (1<<n - 1)
+}
+
+func TestMatch(t *testing.T) {
+	const fingerprint = uint64(0xf0f0f0f0f0f0f0f0)
+	const url = "https://go.dev/blog/go1.23"
+
+	feed := uuid.New()
+	other := uuid.New()
+	near := uuid.New()
+	far := uuid.New()
+	sameUrl := uuid.New()
+
+	tests := []struct {
+		name        string
+		candidates  []Candidate
+		url         string
+		wantCluster uuid.UUID
+		wantFound   bool
+	}{
+		{
+			name:       "no candidates",
+			candidates: nil,
+		},
+		{
+			name:        "at the threshold",
+			candidates:  []Candidate{{ClusterID: near, FeedID: other, Fingerprint: flip(fingerprint, MaxDistance)}},
+			wantCluster: near,
+			wantFound:   true,
+		},
+		{
+			name:       "past the threshold",
+			candidates: []Candidate{{ClusterID: far, FeedID: other, Fingerprint: flip(fingerprint, MaxDistance+1)}},
+		},
+		{
+			name: "closest fingerprint",
+			candidates: []Candidate{
+				{ClusterID: far, FeedID: other, Fingerprint: flip(fingerprint, 5)},
+				{ClusterID: near, FeedID: other, Fingerprint: flip(fingerprint, 1)},
+			},
+			wantCluster: near,
+			wantFound:   true,
+		},
+		{
+			name:       "same feed is ignored",
+			candidates: []Candidate{{ClusterID: near, FeedID: feed, Fingerprint: fingerprint, Url: url}},
+			url:        url,
+		},
+		{
+			name: "canonical url beats fingerprint",
+			candidates: []Candidate{
+				{ClusterID: near, FeedID: other, Fingerprint: fingerprint},
+				{ClusterID: sameUrl, FeedID: other, Fingerprint: ^fingerprint, Url: url},
+			},
+			url:         url,
+			wantCluster: sameUrl,
+			wantFound:   true,
+		},
+		{
+			name:       "empty url matches nothing",
+			candidates: []Candidate{{ClusterID: sameUrl, FeedID: other, Fingerprint: ^fingerprint}},
+		},
+	}
+
+	for _, test := range tests {
+		cluster, found := Match(feed, test.url, fingerprint, test.candidates)
+
+		if found != test.wantFound || cluster != test.wantCluster {
+			t.Errorf("%v: Match = %v, %v, want %v, %v", test.name, cluster, found, test.wantCluster, test.wantFound)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	title := "Go 1.23 is released with iterators and telemetry"
+	content := "<p>The Go team is happy to announce Go 1.23, with range over function iterators, opt-in telemetry and toolchain improvements.</p>"
+
+	base := Fingerprint(title, content)
+
+	if got := Fingerprint(title, content); got != base {
+		t.Errorf("Fingerprint is not stable: %x then %x", base, got)
+	}
+
+	tests := []struct {
+		name    string
+		title   string
+		content string
+		near    bool
+	}{
+		{
+			name:    "markup and case",
+			title:   "GO 1.23 IS RELEASED WITH ITERATORS AND TELEMETRY",
+			content: "<div><b>The Go team</b> is happy to announce <a href=\"/\">Go 1.23</a>, with range over function iterators, opt-in telemetry and toolchain improvements.</div>",
+			near:    true,
+		},
+		{
+			name:    "stop words",
+			title:   "Go 1.23 released with iterators, telemetry",
+			content: content,
+			near:    true,
+		},
+		{
+			name:    "another story",
+			title:   "Rust 2024 edition stabilizes async closures",
+			content: "<p>The Rust project is announcing the 2024 edition, with async closures, new prelude items and changes to temporaries.</p>",
+		},
+	}
+
+	for _, test := range tests {
+		distance := Distance(base, Fingerprint(test.title, test.content))
+
+		if near := distance <= MaxDistance; near != test.near {
+			t.Errorf("%v: distance %d, want within %d: %v", test.name, distance, MaxDistance, test.near)
+		}
+	}
+}
@@ -13,6 +13,23 @@ const configFileName = ".gatorconfig.json"
 type Config struct {
 	DbUrl           string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	AutoPrune       bool   `json:"auto_prune,omitempty"`
+	// TrackingParams replaces the default list of query parameters removed
+	// from post and feed urls.
+	TrackingParams []string `json:"tracking_params,omitempty"`
+	// SMTP is the server email digests are sent through.
+	SMTP *SMTPConfig `json:"smtp,omitempty"`
+}
+
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// From is the sender address, like "gator <gator@example.com>".
+	From string `json:"from"`
+	// TLS is starttls (the default), implicit or none.
+	TLS string `json:"tls,omitempty"`
 }
 
 func (c *Config) SetUser(name string) {
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,10 +23,10 @@ WITH inserted_feed_follow AS (
         $4,
         $5
     ) 
-    RETURNING id, feed_id, user_id, created_at, updated_at
+    RETURNING id, feed_id, user_id, created_at, updated_at, folder
 )
 SELECT 
-    inserted_feed_follow.id, inserted_feed_follow.feed_id, inserted_feed_follow.user_id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, 
+    inserted_feed_follow.id, inserted_feed_follow.feed_id, inserted_feed_follow.user_id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.folder, 
     users.name AS user_name, 
     feeds.name AS feed_name
 FROM inserted_feed_follow
@@ -47,6 +48,7 @@ type CreateFeedFollowRow struct {
 	UserID    uuid.NullUUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Folder    sql.NullString
 	UserName  string
 	FeedName  string
 }
@@ -66,17 +68,38 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UserID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Folder,
 		&i.UserName,
 		&i.FeedName,
 	)
 	return i, err
 }
 
+const deleteFeedFollow = `-- name: DeleteFeedFollow :execrows
+DELETE FROM feed_follows
+WHERE user_id = $1
+AND feed_id = $2
+`
+
+type DeleteFeedFollowParams struct {
+	UserID uuid.NullUUID
+	FeedID uuid.NullUUID
+}
+
+func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeedFollow, arg.UserID, arg.FeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteFeedFollowForUser = `-- name: DeleteFeedFollowForUser :exec
 
 DELETE FROM feed_follows
 USING feeds
-WHERE feed_follows.user_id = $1 
+WHERE feed_follows.feed_id = feeds.id
+AND feed_follows.user_id = $1 
 AND feeds.url = $2
 `
 
@@ -90,6 +113,42 @@ func (q *Queries) DeleteFeedFollowForUser(ctx context.Context, arg DeleteFeedFol
 	return err
 }
 
+const getFaviconsForUser = `-- name: GetFaviconsForUser :many
+SELECT feeds.seq, feeds.favicon FROM feed_follows
+INNER JOIN feeds on feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+AND feeds.favicon <> ''
+ORDER BY feeds.seq
+`
+
+type GetFaviconsForUserRow struct {
+	Seq     int64
+	Favicon sql.NullString
+}
+
+func (q *Queries) GetFaviconsForUser(ctx context.Context, userID uuid.NullUUID) ([]GetFaviconsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFaviconsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFaviconsForUserRow
+	for rows.Next() {
+		var i GetFaviconsForUserRow
+		if err := rows.Scan(&i.Seq, &i.Favicon); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 
 SELECT 
@@ -127,3 +186,80 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.NullUUI
 	}
 	return items, nil
 }
+
+const getFollowedFeedsForUser = `-- name: GetFollowedFeedsForUser :many
+SELECT
+    feeds.id,
+    feeds.name,
+    feeds.url,
+    feeds.site_url,
+    feed_follows.folder,
+    feeds.seq,
+    feeds.last_fetched_at,
+    COALESCE(feeds.favicon, '') <> '' AS has_favicon
+FROM feed_follows
+INNER JOIN feeds on feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follows.folder ASC NULLS FIRST, feeds.name ASC
+`
+
+type GetFollowedFeedsForUserRow struct {
+	ID            uuid.UUID
+	Name          string
+	Url           string
+	SiteUrl       sql.NullString
+	Folder        sql.NullString
+	Seq           int64
+	LastFetchedAt sql.NullTime
+	HasFavicon    bool
+}
+
+func (q *Queries) GetFollowedFeedsForUser(ctx context.Context, userID uuid.NullUUID) ([]GetFollowedFeedsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedFeedsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFollowedFeedsForUserRow
+	for rows.Next() {
+		var i GetFollowedFeedsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.SiteUrl,
+			&i.Folder,
+			&i.Seq,
+			&i.LastFetchedAt,
+			&i.HasFavicon,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setFeedFollowFolder = `-- name: SetFeedFollowFolder :exec
+UPDATE feed_follows
+SET folder = $3, updated_at = NOW()
+WHERE user_id = $1
+AND feed_id = $2
+`
+
+type SetFeedFollowFolderParams struct {
+	UserID uuid.NullUUID
+	FeedID uuid.NullUUID
+	Folder sql.NullString
+}
+
+func (q *Queries) SetFeedFollowFolder(ctx context.Context, arg SetFeedFollowFolderParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFollowFolder, arg.UserID, arg.FeedID, arg.Folder)
+	return err
+}
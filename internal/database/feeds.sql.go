@@ -23,7 +23,7 @@ VALUES (
     $5,
     $6
 ) 
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, site_url, fetch_full_content, seq, favicon
 `
 
 type CreateFeedParams struct {
@@ -53,6 +53,10 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.SiteUrl,
+		&i.FetchFullContent,
+		&i.Seq,
+		&i.Favicon,
 	)
 	return i, err
 }
@@ -142,22 +146,74 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
+const getFeedUrls = `-- name: GetFeedUrls :many
+SELECT id, url FROM feeds
+ORDER BY seq
+`
+
+type GetFeedUrlsRow struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) GetFeedUrls(ctx context.Context) ([]GetFeedUrlsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedUrls)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedUrlsRow
+	for rows.Next() {
+		var i GetFeedUrlsRow
+		if err := rows.Scan(&i.ID, &i.Url); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, last_fetched_at , url  FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
+SELECT feeds.id, feeds.created_at, feeds.last_fetched_at, feeds.url, feeds.fetch_full_content, feeds.name, feeds.favicon IS NOT NULL AS has_favicon
+FROM feeds
+LEFT JOIN websub_subscriptions ON websub_subscriptions.feed_id = feeds.id
+AND websub_subscriptions.state = 'active'
+AND websub_subscriptions.lease_expires_at > NOW()
+WHERE websub_subscriptions.id IS NULL
+OR feeds.last_fetched_at IS NULL
+OR feeds.last_fetched_at < NOW() - INTERVAL '1 day'
+ORDER BY feeds.last_fetched_at ASC NULLS FIRST
 LIMIT 1
 `
 
 type GetNextFeedToFetchRow struct {
-	ID            uuid.UUID
-	LastFetchedAt sql.NullTime
-	Url           string
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	LastFetchedAt    sql.NullTime
+	Url              string
+	FetchFullContent bool
+	Name             string
+	HasFavicon       bool
 }
 
 func (q *Queries) GetNextFeedToFetch(ctx context.Context) (GetNextFeedToFetchRow, error) {
 	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
 	var i GetNextFeedToFetchRow
-	err := row.Scan(&i.ID, &i.LastFetchedAt, &i.Url)
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.LastFetchedAt,
+		&i.Url,
+		&i.FetchFullContent,
+		&i.Name,
+		&i.HasFavicon,
+	)
 	return i, err
 }
 
@@ -171,3 +227,67 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
 	return err
 }
+
+const setFeedFavicon = `-- name: SetFeedFavicon :exec
+UPDATE feeds
+SET favicon = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedFaviconParams struct {
+	ID      uuid.UUID
+	Favicon sql.NullString
+}
+
+func (q *Queries) SetFeedFavicon(ctx context.Context, arg SetFeedFaviconParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFavicon, arg.ID, arg.Favicon)
+	return err
+}
+
+const setFeedFetchFullContent = `-- name: SetFeedFetchFullContent :exec
+UPDATE feeds
+SET fetch_full_content = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedFetchFullContentParams struct {
+	ID               uuid.UUID
+	FetchFullContent bool
+}
+
+func (q *Queries) SetFeedFetchFullContent(ctx context.Context, arg SetFeedFetchFullContentParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFetchFullContent, arg.ID, arg.FetchFullContent)
+	return err
+}
+
+const setFeedSiteUrl = `-- name: SetFeedSiteUrl :exec
+UPDATE feeds
+SET site_url = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedSiteUrlParams struct {
+	ID      uuid.UUID
+	SiteUrl sql.NullString
+}
+
+func (q *Queries) SetFeedSiteUrl(ctx context.Context, arg SetFeedSiteUrlParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedSiteUrl, arg.ID, arg.SiteUrl)
+	return err
+}
+
+const setFeedUrl = `-- name: SetFeedUrl :exec
+UPDATE feeds
+SET url = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedUrlParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) SetFeedUrl(ctx context.Context, arg SetFeedUrlParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedUrl, arg.ID, arg.Url)
+	return err
+}
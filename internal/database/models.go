@@ -11,14 +11,43 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	LastUsedAt sql.NullTime
+}
+
+type Digest struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Email      string
+	Frequency  string
+	Weekday    int32
+	SendMinute int32
+	Timezone   string
+	MaxPosts   int32
+	LastSentAt sql.NullTime
+	NextSendAt time.Time
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.NullUUID
-	LastFetchedAt sql.NullTime
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Name             string
+	Url              string
+	UserID           uuid.NullUUID
+	LastFetchedAt    sql.NullTime
+	SiteUrl          sql.NullString
+	FetchFullContent bool
+	Seq              int64
+	Favicon          sql.NullString
 }
 
 type FeedFollow struct {
@@ -27,6 +56,7 @@ type FeedFollow struct {
 	UserID    uuid.NullUUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Folder    sql.NullString
 }
 
 type Post struct {
@@ -38,11 +68,119 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Seq         int64
+	Content     sql.NullString
+	Simhash     sql.NullInt64
+	ClusterID   uuid.NullUUID
+	Author      sql.NullString
+	Categories  []string
+	SourceUrl   sql.NullString
+}
+
+type PostState struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	ReadAt    sql.NullTime
+	Starred   bool
+	Hidden    bool
+	Tags      []string
+}
+
+type RetentionPolicy struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FeedID      uuid.NullUUID
+	KeepLast    sql.NullInt32
+	MaxAgeDays  sql.NullInt32
+	KeepStarred bool
+	KeepUnread  bool
+}
+
+type PublishedFeed struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	Token     string
+	Folder    sql.NullString
+	RuleID    uuid.NullUUID
+	MaxPosts  int32
+}
+
+type Rule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Seq       int64
+	UserID    uuid.UUID
+	Field     string
+	Pattern   string
+	Regex     bool
+	Exclude   bool
+	Action    string
+	Tag       sql.NullString
+}
+
+type Session struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	TokenHash string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
 }
 
 type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+	FeverApiKey  sql.NullString
+}
+
+type Webhook struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	UserID    uuid.UUID
 	Name      string
+	Url       string
+	Secret    string
+	Events    []string
+	FeedID    uuid.NullUUID
+	RuleID    uuid.NullUUID
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	WebhookID     uuid.UUID
+	Event         string
+	Payload       string
+	Attempts      int32
+	NextAttemptAt sql.NullTime
+	DeliveredAt   sql.NullTime
+	StatusCode    sql.NullInt32
+	LastError     sql.NullString
+}
+
+type WebsubSubscription struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FeedID         uuid.UUID
+	HubUrl         string
+	TopicUrl       string
+	CallbackToken  string
+	Secret         string
+	State          string
+	LeaseExpiresAt sql.NullTime
+	LastPushAt     sql.NullTime
+	LastError      sql.NullString
 }
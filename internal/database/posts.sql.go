@@ -11,10 +11,27 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countPostsForUser = `-- name: CountPostsForUser :one
+SELECT COUNT(*) FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE NOT COALESCE(post_states.hidden, FALSE)
+`
+
+func (q *Queries) CountPostsForUser(ctx context.Context, userID uuid.NullUUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsForUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts ( id,created_at,updated_at,title,url,description,published_at,feed_id)
+INSERT INTO posts ( id,created_at,updated_at,title,url,description,published_at,feed_id,author,categories,source_url)
 VALUES (
  $1,
     $2,
@@ -23,9 +40,12 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, seq, content, simhash, cluster_id, author, categories, source_url
 `
 
 type CreatePostParams struct {
@@ -37,6 +57,9 @@ type CreatePostParams struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Author      sql.NullString
+	Categories  []string
+	SourceUrl   sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -49,6 +72,9 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Author,
+		pq.Array(arg.Categories),
+		arg.SourceUrl,
 	)
 	var i Post
 	err := row.Scan(
@@ -60,12 +86,174 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Seq,
+		&i.Content,
+		&i.Simhash,
+		&i.ClusterID,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.SourceUrl,
+	)
+	return i, err
+}
+
+const deletePost = `-- name: DeletePost :exec
+DELETE FROM posts WHERE id = $1
+`
+
+func (q *Queries) DeletePost(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deletePost, id)
+	return err
+}
+
+const getPostBySeq = `-- name: GetPostBySeq :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.simhash, posts.cluster_id, posts.author, posts.categories, posts.source_url, feeds.name AS feed_name FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+WHERE posts.seq = $1
+`
+
+type GetPostBySeqRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Seq         int64
+	Content     sql.NullString
+	Simhash     sql.NullInt64
+	ClusterID   uuid.NullUUID
+	Author      sql.NullString
+	Categories  []string
+	SourceUrl   sql.NullString
+	FeedName    string
+}
+
+func (q *Queries) GetPostBySeq(ctx context.Context, seq int64) (GetPostBySeqRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostBySeq, seq)
+	var i GetPostBySeqRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Seq,
+		&i.Content,
+		&i.Simhash,
+		&i.ClusterID,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.SourceUrl,
+		&i.FeedName,
 	)
 	return i, err
 }
 
+const getPostBySeqForUser = `-- name: GetPostBySeqForUser :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.simhash, posts.cluster_id, posts.author, posts.categories, posts.source_url, feeds.name AS feed_name FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $2
+WHERE posts.seq = $1
+`
+
+type GetPostBySeqForUserParams struct {
+	Seq    int64
+	UserID uuid.NullUUID
+}
+
+type GetPostBySeqForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Seq         int64
+	Content     sql.NullString
+	Simhash     sql.NullInt64
+	ClusterID   uuid.NullUUID
+	Author      sql.NullString
+	Categories  []string
+	SourceUrl   sql.NullString
+	FeedName    string
+}
+
+func (q *Queries) GetPostBySeqForUser(ctx context.Context, arg GetPostBySeqForUserParams) (GetPostBySeqForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostBySeqForUser, arg.Seq, arg.UserID)
+	var i GetPostBySeqForUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Seq,
+		&i.Content,
+		&i.Simhash,
+		&i.ClusterID,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.SourceUrl,
+		&i.FeedName,
+	)
+	return i, err
+}
+
+const getPostUrlsAfter = `-- name: GetPostUrlsAfter :many
+SELECT seq, id, url FROM posts
+WHERE seq > $1
+ORDER BY seq
+LIMIT $2
+`
+
+type GetPostUrlsAfterParams struct {
+	Seq   int64
+	Limit int32
+}
+
+type GetPostUrlsAfterRow struct {
+	Seq int64
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) GetPostUrlsAfter(ctx context.Context, arg GetPostUrlsAfterParams) ([]GetPostUrlsAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostUrlsAfter, arg.Seq, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostUrlsAfterRow
+	for rows.Next() {
+		var i GetPostUrlsAfterRow
+		if err := rows.Scan(&i.Seq, &i.ID, &i.Url); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id FROM posts 
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, seq, content, simhash, cluster_id, author, categories, source_url FROM posts 
 ORDER BY published_at DESC
 LIMIT $1
 `
@@ -88,6 +276,112 @@ func (q *Queries) GetPostsForUser(ctx context.Context, limit int32) ([]Post, err
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Simhash,
+			&i.ClusterID,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.SourceUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPostsBySeqForUser = `-- name: ListPostsBySeqForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.simhash, posts.cluster_id, posts.author, posts.categories, posts.source_url,
+    feeds.seq AS feed_seq,
+    post_states.read_at,
+    COALESCE(post_states.starred, FALSE) AS starred
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE ($2::BIGINT IS NULL OR posts.seq > $2)
+AND ($3::BIGINT IS NULL OR posts.seq < $3)
+AND (cardinality($4::BIGINT[]) = 0 OR posts.seq = ANY($4::BIGINT[]))
+AND NOT COALESCE(post_states.hidden, FALSE)
+ORDER BY
+    CASE WHEN $3::BIGINT IS NULL THEN posts.seq END ASC,
+    posts.seq DESC
+LIMIT $5
+`
+
+type ListPostsBySeqForUserParams struct {
+	UserID   uuid.NullUUID
+	SinceSeq sql.NullInt64
+	MaxSeq   sql.NullInt64
+	Seqs     []int64
+	MaxPosts int32
+}
+
+type ListPostsBySeqForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Seq         int64
+	Content     sql.NullString
+	Simhash     sql.NullInt64
+	ClusterID   uuid.NullUUID
+	Author      sql.NullString
+	Categories  []string
+	SourceUrl   sql.NullString
+	FeedSeq     int64
+	ReadAt      sql.NullTime
+	Starred     bool
+}
+
+func (q *Queries) ListPostsBySeqForUser(ctx context.Context, arg ListPostsBySeqForUserParams) ([]ListPostsBySeqForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPostsBySeqForUser,
+		arg.UserID,
+		arg.SinceSeq,
+		arg.MaxSeq,
+		pq.Array(arg.Seqs),
+		arg.MaxPosts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPostsBySeqForUserRow
+	for rows.Next() {
+		var i ListPostsBySeqForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Simhash,
+			&i.ClusterID,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.SourceUrl,
+			&i.FeedSeq,
+			&i.ReadAt,
+			&i.Starred,
 		); err != nil {
 			return nil, err
 		}
@@ -101,3 +395,384 @@ func (q *Queries) GetPostsForUser(ctx context.Context, limit int32) ([]Post, err
 	}
 	return items, nil
 }
+
+const listPostsForUser = `-- name: ListPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.simhash, posts.cluster_id, posts.author, posts.categories, posts.source_url,
+    feeds.name AS feed_name,
+    post_states.read_at,
+    COALESCE(post_states.starred, FALSE) AS starred,
+    COALESCE(post_states.hidden, FALSE) AS hidden,
+    CAST(COALESCE(post_states.tags, '{}') AS TEXT[]) AS tags
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE ($2::uuid IS NULL OR posts.feed_id = $2)
+AND (NOT $3::BOOLEAN OR post_states.read_at IS NULL)
+AND (NOT $4::BOOLEAN OR COALESCE(post_states.starred, FALSE))
+AND ($5::TEXT IS NULL OR $5 = ANY(post_states.tags))
+AND ($6::BOOLEAN OR NOT COALESCE(post_states.hidden, FALSE))
+ORDER BY posts.published_at DESC NULLS LAST, posts.seq DESC
+LIMIT $7
+OFFSET $8
+`
+
+type ListPostsForUserParams struct {
+	UserID        uuid.NullUUID
+	FeedID        uuid.NullUUID
+	UnreadOnly    bool
+	StarredOnly   bool
+	Tag           sql.NullString
+	IncludeHidden bool
+	MaxPosts      int32
+	SkipPosts     int32
+}
+
+type ListPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Seq         int64
+	Content     sql.NullString
+	Simhash     sql.NullInt64
+	ClusterID   uuid.NullUUID
+	Author      sql.NullString
+	Categories  []string
+	SourceUrl   sql.NullString
+	FeedName    string
+	ReadAt      sql.NullTime
+	Starred     bool
+	Hidden      bool
+	Tags        []string
+}
+
+func (q *Queries) ListPostsForUser(ctx context.Context, arg ListPostsForUserParams) ([]ListPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPostsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.Tag,
+		arg.IncludeHidden,
+		arg.MaxPosts,
+		arg.SkipPosts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPostsForUserRow
+	for rows.Next() {
+		var i ListPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Simhash,
+			&i.ClusterID,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.SourceUrl,
+			&i.FeedName,
+			&i.ReadAt,
+			&i.Starred,
+			&i.Hidden,
+			pq.Array(&i.Tags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStreamPostSeqsForUser = `-- name: ListStreamPostSeqsForUser :many
+SELECT posts.seq
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE ($2::uuid IS NULL OR posts.feed_id = $2)
+AND ($3::TEXT IS NULL OR feed_follows.folder = $3)
+AND (NOT $4::BOOLEAN OR post_states.read_at IS NULL)
+AND (NOT $5::BOOLEAN OR COALESCE(post_states.starred, FALSE))
+AND ($6::TIMESTAMP IS NULL OR posts.created_at > $6)
+AND ($7::TIMESTAMP IS NULL OR posts.created_at < $7)
+AND NOT COALESCE(post_states.hidden, FALSE)
+ORDER BY
+    CASE WHEN $8::BOOLEAN THEN posts.published_at END ASC,
+    CASE WHEN $8::BOOLEAN THEN posts.seq END ASC,
+    posts.published_at DESC NULLS LAST,
+    posts.seq DESC
+LIMIT $9
+OFFSET $10
+`
+
+type ListStreamPostSeqsForUserParams struct {
+	UserID      uuid.NullUUID
+	FeedID      uuid.NullUUID
+	Folder      sql.NullString
+	UnreadOnly  bool
+	StarredOnly bool
+	NewerThan   sql.NullTime
+	OlderThan   sql.NullTime
+	OldestFirst bool
+	MaxPosts    int32
+	SkipPosts   int32
+}
+
+func (q *Queries) ListStreamPostSeqsForUser(ctx context.Context, arg ListStreamPostSeqsForUserParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listStreamPostSeqsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.Folder,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.NewerThan,
+		arg.OlderThan,
+		arg.OldestFirst,
+		arg.MaxPosts,
+		arg.SkipPosts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		items = append(items, seq)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStreamPostsForUser = `-- name: ListStreamPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.simhash, posts.cluster_id, posts.author, posts.categories, posts.source_url,
+    feeds.seq AS feed_seq,
+    feeds.name AS feed_name,
+    feeds.site_url AS feed_site_url,
+    feeds.url AS feed_url,
+    feed_follows.folder,
+    post_states.read_at,
+    COALESCE(post_states.starred, FALSE) AS starred
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE ($2::uuid IS NULL OR posts.feed_id = $2)
+AND ($3::TEXT IS NULL OR feed_follows.folder = $3)
+AND (NOT $4::BOOLEAN OR post_states.read_at IS NULL)
+AND (NOT $5::BOOLEAN OR COALESCE(post_states.starred, FALSE))
+AND ($6::TIMESTAMP IS NULL OR posts.created_at > $6)
+AND ($7::TIMESTAMP IS NULL OR posts.created_at < $7)
+AND (cardinality($8::BIGINT[]) = 0 OR posts.seq = ANY($8::BIGINT[]))
+AND NOT COALESCE(post_states.hidden, FALSE)
+ORDER BY
+    CASE WHEN $9::BOOLEAN THEN posts.published_at END ASC,
+    CASE WHEN $9::BOOLEAN THEN posts.seq END ASC,
+    posts.published_at DESC NULLS LAST,
+    posts.seq DESC
+LIMIT $10
+OFFSET $11
+`
+
+type ListStreamPostsForUserParams struct {
+	UserID      uuid.NullUUID
+	FeedID      uuid.NullUUID
+	Folder      sql.NullString
+	UnreadOnly  bool
+	StarredOnly bool
+	NewerThan   sql.NullTime
+	OlderThan   sql.NullTime
+	Seqs        []int64
+	OldestFirst bool
+	MaxPosts    int32
+	SkipPosts   int32
+}
+
+type ListStreamPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Seq         int64
+	Content     sql.NullString
+	Simhash     sql.NullInt64
+	ClusterID   uuid.NullUUID
+	Author      sql.NullString
+	Categories  []string
+	SourceUrl   sql.NullString
+	FeedSeq     int64
+	FeedName    string
+	FeedSiteUrl sql.NullString
+	FeedUrl     string
+	Folder      sql.NullString
+	ReadAt      sql.NullTime
+	Starred     bool
+}
+
+func (q *Queries) ListStreamPostsForUser(ctx context.Context, arg ListStreamPostsForUserParams) ([]ListStreamPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listStreamPostsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.Folder,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.NewerThan,
+		arg.OlderThan,
+		pq.Array(arg.Seqs),
+		arg.OldestFirst,
+		arg.MaxPosts,
+		arg.SkipPosts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListStreamPostsForUserRow
+	for rows.Next() {
+		var i ListStreamPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Simhash,
+			&i.ClusterID,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.SourceUrl,
+			&i.FeedSeq,
+			&i.FeedName,
+			&i.FeedSiteUrl,
+			&i.FeedUrl,
+			&i.Folder,
+			&i.ReadAt,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const postSourceExists = `-- name: PostSourceExists :one
+SELECT EXISTS (
+    SELECT 1 FROM posts
+    WHERE feed_id = $1
+    AND source_url = $2
+)
+`
+
+type PostSourceExistsParams struct {
+	FeedID    uuid.UUID
+	SourceUrl sql.NullString
+}
+
+func (q *Queries) PostSourceExists(ctx context.Context, arg PostSourceExistsParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, postSourceExists, arg.FeedID, arg.SourceUrl)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const setPostContent = `-- name: SetPostContent :exec
+UPDATE posts
+SET content = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetPostContentParams struct {
+	ID      uuid.UUID
+	Content sql.NullString
+}
+
+func (q *Queries) SetPostContent(ctx context.Context, arg SetPostContentParams) error {
+	_, err := q.db.ExecContext(ctx, setPostContent, arg.ID, arg.Content)
+	return err
+}
+
+const setPostSourceUrl = `-- name: SetPostSourceUrl :exec
+UPDATE posts
+SET source_url = $3
+WHERE feed_id = $1
+AND url = $2
+`
+
+type SetPostSourceUrlParams struct {
+	FeedID    uuid.UUID
+	Url       string
+	SourceUrl sql.NullString
+}
+
+func (q *Queries) SetPostSourceUrl(ctx context.Context, arg SetPostSourceUrlParams) error {
+	_, err := q.db.ExecContext(ctx, setPostSourceUrl, arg.FeedID, arg.Url, arg.SourceUrl)
+	return err
+}
+
+const setPostUrl = `-- name: SetPostUrl :exec
+UPDATE posts
+SET url = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetPostUrlParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) SetPostUrl(ctx context.Context, arg SetPostUrlParams) error {
+	_, err := q.db.ExecContext(ctx, setPostUrl, arg.ID, arg.Url)
+	return err
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,7 +21,7 @@ VALUES (
     $3,
     $4
 ) 
-RETURNING id, created_at, updated_at, name
+RETURNING id, created_at, updated_at, name, password_hash, fever_api_key
 `
 
 type CreateUserParams struct {
@@ -43,6 +44,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.FeverApiKey,
 	)
 	return i, err
 }
@@ -57,7 +60,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, name FROM users WHERE name=$1
+SELECT id, created_at, updated_at, name, password_hash, fever_api_key FROM users WHERE name=$1
 `
 
 func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
@@ -68,12 +71,32 @@ func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.FeverApiKey,
+	)
+	return i, err
+}
+
+const getUserByFeverApiKey = `-- name: GetUserByFeverApiKey :one
+SELECT id, created_at, updated_at, name, password_hash, fever_api_key FROM users WHERE fever_api_key = $1
+`
+
+func (q *Queries) GetUserByFeverApiKey(ctx context.Context, feverApiKey sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByFeverApiKey, feverApiKey)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.PasswordHash,
+		&i.FeverApiKey,
 	)
 	return i, err
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, created_at, updated_at, name FROM users
+SELECT id, created_at, updated_at, name, password_hash, fever_api_key FROM users
 `
 
 func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
@@ -90,6 +113,9 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Name,
+			&i.PasswordHash,
+			&i.FeverApiKey,
+			&i.FeverApiKey,
 		); err != nil {
 			return nil, err
 		}
@@ -103,3 +129,20 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	}
 	return items, nil
 }
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, fever_api_key = $3, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserPasswordParams struct {
+	ID           uuid.UUID
+	PasswordHash sql.NullString
+	FeverApiKey  sql.NullString
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.ID, arg.PasswordHash, arg.FeverApiKey)
+	return err
+}
@@ -0,0 +1,173 @@
+// Package digest schedules and renders email digests of unread posts.
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.ParseFS(templates, "templates/digest.txt"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/digest.html"))
+)
+
+// Frequency is how often a digest is sent.
+type Frequency string
+
+const (
+	Daily  Frequency = "daily"
+	Weekly Frequency = "weekly"
+)
+
+// Schedule says when a digest is sent: every day, or every week on Weekday,
+// Minute minutes after midnight in Location.
+type Schedule struct {
+	Frequency Frequency
+	Weekday   time.Weekday
+	Minute    int
+	Location  *time.Location
+}
+
+// Next returns the first time after after a digest is due.
+func (s Schedule) Next(after time.Time) time.Time {
+	local := after.In(s.Location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), s.Minute/60, s.Minute%60, 0, 0, s.Location)
+	step := 1
+
+	if s.Frequency == Weekly {
+		next = next.AddDate(0, 0, (int(s.Weekday)-int(next.Weekday())+7)%7)
+		step = 7
+	}
+
+	for !next.After(after) {
+		next = next.AddDate(0, 0, step)
+	}
+
+	return next
+}
+
+// Period is how far back the first digest of a schedule looks.
+func (s Schedule) Period() time.Duration {
+	if s.Frequency == Weekly {
+		return 7 * 24 * time.Hour
+	}
+
+	return 24 * time.Hour
+}
+
+func (s Schedule) String() string {
+	at := fmt.Sprintf("%02d:%02d %v", s.Minute/60, s.Minute%60, s.Location)
+
+	if s.Frequency == Weekly {
+		return "weekly on " + s.Weekday.String() + " at " + at
+	}
+
+	return "daily at " + at
+}
+
+// ParseFrequency checks a frequency name.
+func ParseFrequency(name string) (Frequency, error) {
+	switch Frequency(name) {
+	case Daily, Weekly:
+		return Frequency(name), nil
+	}
+
+	return "", fmt.Errorf("unknown frequency %q, expecting daily or weekly", name)
+}
+
+// ParseClock parses a time of day like 08:30 into minutes after midnight.
+func ParseClock(clock string) (int, error) {
+	hours, minutes, ok := strings.Cut(clock, ":")
+	h, err := strconv.Atoi(hours)
+
+	if !ok || err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("invalid time of day %q, expecting HH:MM", clock)
+	}
+
+	m, err := strconv.Atoi(minutes)
+
+	if err != nil || len(minutes) != 2 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time of day %q, expecting HH:MM", clock)
+	}
+
+	return h*60 + m, nil
+}
+
+// ParseWeekday parses an English day name, full or abbreviated to three
+// letters.
+func ParseWeekday(name string) (time.Weekday, error) {
+	name = strings.ToLower(name)
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+
+		if name == full || (len(name) == 3 && strings.HasPrefix(full, name)) {
+			return day, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
+
+// Digest is what a digest email shows: the unread posts since Since, grouped
+// by feed.
+type Digest struct {
+	User  string
+	Since time.Time
+	Total int
+	Feeds []Feed
+}
+
+type Feed struct {
+	Name  string
+	Url   string
+	Posts []Post
+}
+
+// Post is a post of a digest. Excerpt is plain text.
+type Post struct {
+	Title     string
+	Url       string
+	Author    string
+	Excerpt   string
+	Published time.Time
+}
+
+// Subject is the subject line of a digest email.
+func (d Digest) Subject() string {
+	posts := "posts"
+
+	if d.Total == 1 {
+		posts = "post"
+	}
+
+	return fmt.Sprintf("gator digest: %d unread %v since %v", d.Total, posts, d.Since.Format("Mon 2 Jan"))
+}
+
+// Render returns the plain text and HTML versions of a digest.
+func Render(d Digest) (string, string, error) {
+	var text, html bytes.Buffer
+
+	err := textTemplate.Execute(&text, d)
+
+	if err != nil {
+		return "", "", fmt.Errorf("could not render text digest: %v", err)
+	}
+
+	err = htmlTemplate.Execute(&html, d)
+
+	if err != nil {
+		return "", "", fmt.Errorf("could not render html digest: %v", err)
+	}
+
+	return text.String(), html.String(), nil
+}
@@ -0,0 +1,112 @@
+package digest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+
+	daily := Schedule{Frequency: Daily, Minute: 8 * 60, Location: time.UTC}
+	weekly := Schedule{Frequency: Weekly, Weekday: time.Monday, Minute: 8*60 + 30, Location: time.UTC}
+	berlinDaily := Schedule{Frequency: Daily, Minute: 7 * 60, Location: berlin}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		after    time.Time
+		want     time.Time
+	}{
+		{
+			name:     "daily, later today",
+			schedule: daily,
+			after:    time.Date(2026, 3, 4, 6, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "daily, at the time",
+			schedule: daily,
+			after:    time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 5, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "daily, across a month",
+			schedule: daily,
+			after:    time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekly, later this week",
+			schedule: weekly,
+			after:    time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC), // Wednesday
+			want:     time.Date(2026, 3, 9, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekly, later on the day",
+			schedule: weekly,
+			after:    time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 9, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekly, just sent",
+			schedule: weekly,
+			after:    time.Date(2026, 3, 9, 8, 30, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 16, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "in a timezone",
+			schedule: berlinDaily,
+			after:    time.Date(2026, 1, 10, 5, 0, 0, 0, time.UTC), // 06:00 in Berlin
+			want:     time.Date(2026, 1, 10, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "over a DST change",
+			schedule: berlinDaily,
+			after:    time.Date(2026, 3, 28, 7, 0, 0, 0, time.UTC), // 08:00 in Berlin
+			want:     time.Date(2026, 3, 29, 5, 0, 0, 0, time.UTC), // 07:00 CEST
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.schedule.Next(test.after); !got.Equal(test.want) {
+			t.Errorf("%v: Next(%v) = %v, want %v", test.name, test.after, got.UTC(), test.want)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	for clock, want := range map[string]int{"00:00": 0, "08:30": 510, "23:59": 1439, "7:05": 425} {
+		got, err := ParseClock(clock)
+
+		if err != nil || got != want {
+			t.Errorf("ParseClock(%q) = %d, %v, want %d", clock, got, err, want)
+		}
+	}
+
+	for _, clock := range []string{"", "8", "24:00", "08:60", "08:5", "ab:cd", "-1:00"} {
+		if _, err := ParseClock(clock); err == nil {
+			t.Errorf("ParseClock(%q) succeeded, want an error", clock)
+		}
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for name, want := range map[string]time.Weekday{"monday": time.Monday, "Sun": time.Sunday, "SATURDAY": time.Saturday} {
+		got, err := ParseWeekday(name)
+
+		if err != nil || got != want {
+			t.Errorf("ParseWeekday(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+
+	for _, name := range []string{"", "mo", "mond", "funday"} {
+		if _, err := ParseWeekday(name); err == nil {
+			t.Errorf("ParseWeekday(%q) succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+// Package htmltext renders the HTML found in feed items as wrapped terminal
+// text: paragraphs, lists, blockquotes and code blocks keep their shape and
+// links become numbered footnotes.
+package htmltext
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultWidth is used when Options.Width is not positive.
+const DefaultWidth = 80
+
+type Options struct {
+	// Width is the column at which text is wrapped.
+	Width int
+	// Color enables ANSI styling of headings, emphasis, code and quotes.
+	Color bool
+}
+
+const (
+	styleBold      = "\x1b[1m"
+	styleDim       = "\x1b[2m"
+	styleItalic    = "\x1b[3m"
+	styleUnderline = "\x1b[4m"
+	styleCode      = "\x1b[36m"
+	styleReset     = "\x1b[0m"
+)
+
+// Render converts an HTML fragment to text. Text without markup is wrapped
+// as it is.
+func Render(content string, options Options) string {
+	if options.Width <= 0 {
+		options.Width = DefaultWidth
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+
+	if err != nil {
+		return content
+	}
+
+	r := &renderer{options: options}
+	lines := r.blocks(nodes, options.Width, false)
+
+	if len(r.links) > 0 {
+		lines = append(lines, "")
+
+		for i, link := range r.links {
+			lines = append(lines, r.style(styleDim, fmt.Sprintf("[%d]: %v", i+1, link)))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Plain returns the text of an HTML fragment without any layout, for
+// matching and indexing rather than display.
+func Plain(content string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+
+	var text strings.Builder
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(text.String()), " ")
+		case html.TextToken:
+			text.WriteString(html.UnescapeString(string(tokenizer.Text())))
+			text.WriteByte(' ')
+		}
+	}
+}
+
+type renderer struct {
+	options Options
+	links   []string
+}
+
+// word is a run of text that is never broken across lines. width is its
+// length on screen, without the ANSI sequences it may contain.
+type word struct {
+	text  string
+	width int
+	// lineBreak marks a <br> rather than text.
+	lineBreak bool
+}
+
+// paragraph collects the inline content between two blocks.
+type paragraph struct {
+	words []word
+	// space records whether the next text starts a new word.
+	space bool
+}
+
+func (p *paragraph) add(text, styled string, r *renderer) {
+	if text == "" {
+		return
+	}
+
+	fields := strings.Fields(text)
+
+	if len(fields) == 0 {
+		p.space = true
+		return
+	}
+
+	if startsWithSpace(text) {
+		p.space = true
+	}
+
+	for i, field := range fields {
+		rendered := r.style(styled, field)
+		last := len(p.words) - 1
+
+		if i == 0 && !p.space && last >= 0 && !p.words[last].lineBreak {
+			p.words[last].text += rendered
+			p.words[last].width += utf8.RuneCountInString(field)
+			continue
+		}
+
+		p.words = append(p.words, word{
+			text:  rendered,
+			width: utf8.RuneCountInString(field),
+		})
+	}
+
+	p.space = endsWithSpace(text)
+}
+
+func (p *paragraph) lineBreak() {
+	p.words = append(p.words, word{lineBreak: true})
+	p.space = true
+}
+
+func (p *paragraph) empty() bool {
+	return len(p.words) == 0
+}
+
+// wrap lays the words out in lines of at most width columns. Words longer
+// than width get a line of their own.
+func (p *paragraph) wrap(width int) []string {
+	lines := []string{}
+	line := ""
+	lineWidth := 0
+
+	for _, w := range p.words {
+		if w.lineBreak {
+			lines = append(lines, line)
+			line, lineWidth = "", 0
+			continue
+		}
+
+		if lineWidth > 0 && lineWidth+1+w.width > width {
+			lines = append(lines, line)
+			line, lineWidth = "", 0
+		}
+
+		if lineWidth > 0 {
+			line += " "
+			lineWidth++
+		}
+
+		line += w.text
+		lineWidth += w.width
+	}
+
+	if lineWidth > 0 {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// blocks renders a list of sibling nodes to lines of at most width columns,
+// separating paragraphs and other blocks with a blank line unless compact is
+// set, as it is inside list items.
+func (r *renderer) blocks(nodes []*html.Node, width int, compact bool) []string {
+	width = max(width, 10)
+
+	lines := []string{}
+	current := &paragraph{}
+
+	appendBlock := func(block []string) {
+		if len(block) == 0 {
+			return
+		}
+
+		if len(lines) > 0 && !compact {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, block...)
+	}
+
+	flush := func() {
+		if !current.empty() {
+			appendBlock(current.wrap(width))
+		}
+
+		current = &paragraph{}
+	}
+
+	for _, node := range nodes {
+		if node.Type != html.ElementNode || !isBlock(node.DataAtom) {
+			r.inline(node, current, "")
+			continue
+		}
+
+		flush()
+		appendBlock(r.block(node, width))
+	}
+
+	flush()
+
+	return lines
+}
+
+func (r *renderer) block(node *html.Node, width int) []string {
+	switch node.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Noscript, atom.Template, atom.Iframe:
+		return nil
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		p := &paragraph{}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			r.inline(child, p, styleBold)
+		}
+
+		return p.wrap(width)
+	case atom.Ul, atom.Ol:
+		return r.list(node, width)
+	case atom.Blockquote:
+		quoted := r.blocks(children(node), width-2, false)
+
+		for i, line := range quoted {
+			quoted[i] = r.style(styleDim, "│") + " " + line
+		}
+
+		return quoted
+	case atom.Pre:
+		code := strings.ReplaceAll(strings.Trim(textContent(node), "\n"), "\t", "    ")
+		lines := strings.Split(code, "\n")
+
+		for i, line := range lines {
+			lines[i] = "    " + r.style(styleCode, line)
+		}
+
+		return lines
+	case atom.Hr:
+		return []string{r.style(styleDim, strings.Repeat("─", width))}
+	case atom.Tr:
+		p := &paragraph{}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && (child.DataAtom == atom.Td || child.DataAtom == atom.Th) && !p.empty() {
+				p.add(" | ", "", r)
+			}
+
+			r.inline(child, p, "")
+		}
+
+		return p.wrap(width)
+	case atom.Table, atom.Thead, atom.Tbody, atom.Tfoot:
+		rows := []string{}
+
+		for _, row := range children(node) {
+			if row.Type == html.ElementNode {
+				rows = append(rows, r.block(row, width)...)
+			}
+		}
+
+		return rows
+	}
+
+	return r.blocks(children(node), width, false)
+}
+
+// list renders the items of ul and ol with a bullet or number, indenting
+// the lines that follow and nested lists.
+func (r *renderer) list(node *html.Node, width int) []string {
+	lines := []string{}
+	number := 1
+
+	for _, item := range children(node) {
+		if item.Type != html.ElementNode || item.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "• "
+
+		if node.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+
+		indent := strings.Repeat(" ", utf8.RuneCountInString(marker))
+		itemLines := r.blocks(children(item), width-len(indent), true)
+
+		for i, line := range itemLines {
+			switch {
+			case i == 0:
+				itemLines[i] = marker + line
+			case line != "":
+				itemLines[i] = indent + line
+			}
+		}
+
+		if len(itemLines) == 0 {
+			itemLines = []string{strings.TrimSpace(marker)}
+		}
+
+		lines = append(lines, itemLines...)
+	}
+
+	return lines
+}
+
+// inline adds node and its descendants to p, styled with style plus whatever
+// the elements on the way add.
+func (r *renderer) inline(node *html.Node, p *paragraph, style string) {
+	switch node.Type {
+	case html.TextNode:
+		p.add(node.Data, style, r)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch node.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Iframe:
+		return
+	case atom.Br:
+		p.lineBreak()
+		return
+	case atom.Img:
+		alt := strings.TrimSpace(attribute(node, "alt"))
+
+		if alt != "" {
+			p.add(" [image: "+alt+"] ", style+styleDim, r)
+		}
+
+		return
+	case atom.B, atom.Strong:
+		style += styleBold
+	case atom.I, atom.Em, atom.Cite:
+		style += styleItalic
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		if !r.options.Color {
+			p.add("`"+textContent(node)+"`", "", r)
+			return
+		}
+
+		style += styleCode
+	case atom.A:
+		r.link(node, p, style)
+		return
+	}
+
+	if isBlock(node.DataAtom) {
+		p.space = true
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		r.inline(child, p, style)
+	}
+
+	if isBlock(node.DataAtom) {
+		p.space = true
+	}
+}
+
+// link renders the text of an anchor followed by a footnote number. A link
+// whose text is its own url is shown as is.
+func (r *renderer) link(node *html.Node, p *paragraph, style string) {
+	href := strings.TrimSpace(attribute(node, "href"))
+	text := strings.TrimSpace(textContent(node))
+
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			r.inline(child, p, style)
+		}
+
+		return
+	}
+
+	if text == "" || text == href || strings.TrimPrefix(strings.TrimPrefix(href, "https://"), "http://") == text {
+		p.add(href, style+styleUnderline, r)
+		return
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		r.inline(child, p, style+styleUnderline)
+	}
+
+	number := 0
+
+	for i, link := range r.links {
+		if link == href {
+			number = i + 1
+		}
+	}
+
+	if number == 0 {
+		r.links = append(r.links, href)
+		number = len(r.links)
+	}
+
+	p.space = false
+	p.add(fmt.Sprintf("[%d]", number), styleDim, r)
+}
+
+func (r *renderer) style(style, text string) string {
+	if !r.options.Color || style == "" || text == "" {
+		return text
+	}
+
+	return style + text + styleReset
+}
+
+func isBlock(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer,
+		atom.Main, atom.Aside, atom.Nav, atom.Figure, atom.Figcaption, atom.Address,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Ul, atom.Ol, atom.Li, atom.Dl, atom.Dt, atom.Dd,
+		atom.Blockquote, atom.Pre, atom.Hr,
+		atom.Table, atom.Thead, atom.Tbody, atom.Tfoot, atom.Tr,
+		atom.Script, atom.Style, atom.Head, atom.Noscript, atom.Template, atom.Iframe,
+		atom.Details, atom.Summary:
+		return true
+	}
+
+	return false
+}
+
+func children(node *html.Node) []*html.Node {
+	nodes := []*html.Node{}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		nodes = append(nodes, child)
+	}
+
+	return nodes
+}
+
+func attribute(node *html.Node, name string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
+
+func textContent(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+
+	var text strings.Builder
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(textContent(child))
+	}
+
+	return text.String()
+}
+
+func startsWithSpace(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+
+	return r == ' ' || r == '\n' || r == '\t' || r == '\r' || r == '\f'
+}
+
+func endsWithSpace(text string) bool {
+	r, _ := utf8.DecodeLastRuneInString(text)
+
+	return r == ' ' || r == '\n' || r == '\t' || r == '\r' || r == '\f'
+}
@@ -0,0 +1,278 @@
+// Package mail builds multipart emails with a plain text and an HTML
+// version and sends them over SMTP.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLS modes of a Server.
+const (
+	// TLSStartTLS upgrades the connection with STARTTLS and fails when the
+	// server does not offer it.
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects with TLS from the start, usually on port 465.
+	TLSImplicit = "implicit"
+	// TLSNone sends in the clear, for local relays only.
+	TLSNone = "none"
+)
+
+const timeout = 30 * time.Second
+
+// Server is an SMTP server to send through. Username may be empty for
+// servers that do not need authentication.
+type Server struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLS      string
+}
+
+// Message is an email with a plain text and an HTML alternative.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Date    time.Time
+}
+
+// Bytes renders the message as multipart/alternative, with both parts
+// quoted-printable encoded.
+func (m Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %v", m.From, err)
+	}
+
+	to, err := mail.ParseAddress(m.To)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid to address %q: %v", m.To, err)
+	}
+
+	var body bytes.Buffer
+
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+
+		_, err = encoder.Write([]byte(part.content))
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = encoder.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = parts.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	messageID, err := newMessageID(from.Address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+
+	for _, header := range [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", m.Date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + strconv.Quote(parts.Boundary())},
+	} {
+		fmt.Fprintf(&message, "%v: %v\r\n", header[0], header[1])
+	}
+
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+// Send delivers a message through server.
+func Send(server Server, m Message) error {
+	data, err := m.Bytes()
+
+	if err != nil {
+		return err
+	}
+
+	from, _ := mail.ParseAddress(m.From)
+	to, _ := mail.ParseAddress(m.To)
+
+	client, err := dial(server)
+
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	if server.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", server.Username, server.Password, server.Host))
+
+		if err != nil {
+			return fmt.Errorf("smtp authentication failed: %v", err)
+		}
+	}
+
+	err = client.Mail(from.Address)
+
+	if err != nil {
+		return fmt.Errorf("smtp server refused sender %v: %v", from.Address, err)
+	}
+
+	err = client.Rcpt(to.Address)
+
+	if err != nil {
+		return fmt.Errorf("smtp server refused recipient %v: %v", to.Address, err)
+	}
+
+	writer, err := client.Data()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(data)
+
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		return fmt.Errorf("smtp server refused message: %v", err)
+	}
+
+	return client.Quit()
+}
+
+// dial connects to server and secures the connection as its TLS mode asks.
+func dial(server Server) (*smtp.Client, error) {
+	if server.Host == "" {
+		return nil, errors.New("no smtp host configured")
+	}
+
+	port := server.Port
+
+	if port == 0 {
+		port = 587
+
+		if server.TLS == TLSImplicit {
+			port = 465
+		}
+	}
+
+	address := net.JoinHostPort(server.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: server.Host}
+
+	var conn net.Conn
+	var err error
+
+	if server.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", address, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", address, timeout)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to smtp server: %v", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(conn, server.Host)
+
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not connect to smtp server: %v", err)
+	}
+
+	switch server.TLS {
+	case TLSImplicit, TLSNone:
+	case "", TLSStartTLS:
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp server does not offer STARTTLS, set tls to \"none\" to send in the clear")
+		}
+
+		err = client.StartTLS(tlsConfig)
+
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp STARTTLS failed: %v", err)
+		}
+	default:
+		client.Close()
+		return nil, fmt.Errorf("unknown smtp tls mode %q, expecting starttls, implicit or none", server.TLS)
+	}
+
+	return client, nil
+}
+
+func newMessageID(from string) (string, error) {
+	random := make([]byte, 16)
+
+	_, err := rand.Read(random)
+
+	if err != nil {
+		return "", err
+	}
+
+	domain := "gator"
+
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">", nil
+}
@@ -0,0 +1,175 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mambo-dev/gator/internal/mail/mailtest"
+)
+
+func testMessage() Message {
+	return Message{
+		From:    "gator <gator@example.com>",
+		To:      "alice@example.com",
+		Subject: "Your digest: 3 new posts ✓",
+		Text:    "Go 1.23 is released\nhttps://go.dev/blog/go1.23",
+		HTML:    `<p><a href="https://go.dev/blog/go1.23">Go 1.23 is released</a></p>`,
+		Date:    time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSend(t *testing.T) {
+	server := mailtest.NewServer()
+	defer server.Close()
+
+	m := testMessage()
+
+	err := Send(Server{Host: server.Host, Port: server.Port, TLS: TLSNone}, m)
+
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	messages := server.Messages()
+
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+
+	if messages[0].From != "gator@example.com" || strings.Join(messages[0].To, ",") != "alice@example.com" {
+		t.Errorf("envelope = %v to %v, want gator@example.com to alice@example.com", messages[0].From, messages[0].To)
+	}
+
+	received, err := mail.ReadMessage(bytes.NewReader(messages[0].Data))
+
+	if err != nil {
+		t.Fatalf("could not parse message: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(received.Header.Get("Subject"))
+
+	if err != nil || subject != m.Subject {
+		t.Errorf("Subject = %q, want %q", subject, m.Subject)
+	}
+
+	for header, want := range map[string]string{
+		"From":         `"gator" <gator@example.com>`,
+		"To":           "<alice@example.com>",
+		"Date":         "Mon, 02 Mar 2026 08:00:00 +0000",
+		"MIME-Version": "1.0",
+	} {
+		if got := received.Header.Get(header); got != want {
+			t.Errorf("%v = %q, want %q", header, got, want)
+		}
+	}
+
+	if id := received.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID = %q, want <...@example.com>", id)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(received.Header.Get("Content-Type"))
+
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %v, want multipart/alternative", received.Header.Get("Content-Type"))
+	}
+
+	parts := multipart.NewReader(received.Body, params["boundary"])
+	want := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	}
+
+	for _, wantPart := range want {
+		part, err := parts.NextPart()
+
+		if err != nil {
+			t.Fatalf("could not read %v part: %v", wantPart.contentType, err)
+		}
+
+		// The multipart reader decodes quoted-printable parts.
+		content, _ := io.ReadAll(part)
+
+		if got := part.Header.Get("Content-Type"); got != wantPart.contentType {
+			t.Errorf("part Content-Type = %v, want %v", got, wantPart.contentType)
+		}
+
+		if string(content) != wantPart.content {
+			t.Errorf("%v part = %q, want %q", wantPart.contentType, content, wantPart.content)
+		}
+	}
+
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("message has more than two parts")
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	server := mailtest.NewServer()
+	defer server.Close()
+
+	server.Reject("bob@example.com")
+
+	rejected := testMessage()
+	rejected.To = "bob@example.com"
+
+	invalid := testMessage()
+	invalid.From = "not an address"
+
+	tests := []struct {
+		name      string
+		server    Server
+		message   Message
+		wantError string
+	}{
+		{
+			name:      "refused recipient",
+			server:    Server{Host: server.Host, Port: server.Port, TLS: TLSNone},
+			message:   rejected,
+			wantError: "smtp server refused recipient bob@example.com",
+		},
+		{
+			name:      "no starttls",
+			server:    Server{Host: server.Host, Port: server.Port},
+			message:   testMessage(),
+			wantError: "smtp server does not offer STARTTLS",
+		},
+		{
+			name:      "unknown tls mode",
+			server:    Server{Host: server.Host, Port: server.Port, TLS: "ssl"},
+			message:   testMessage(),
+			wantError: `unknown smtp tls mode "ssl"`,
+		},
+		{
+			name:      "invalid sender",
+			server:    Server{Host: server.Host, Port: server.Port, TLS: TLSNone},
+			message:   invalid,
+			wantError: `invalid from address "not an address"`,
+		},
+		{
+			name:      "no host",
+			message:   testMessage(),
+			wantError: "no smtp host configured",
+		},
+	}
+
+	for _, test := range tests {
+		err := Send(test.server, test.message)
+
+		if err == nil || !strings.Contains(err.Error(), test.wantError) {
+			t.Errorf("%v: error = %v, want %q", test.name, err, test.wantError)
+		}
+	}
+
+	if messages := server.Messages(); len(messages) != 0 {
+		t.Errorf("server received %d messages, want none", len(messages))
+	}
+}
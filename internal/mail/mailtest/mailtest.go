@@ -0,0 +1,197 @@
+// Package mailtest provides an SMTP server on the loopback interface for
+// tests, which keeps the messages it receives instead of relaying them. It
+// offers neither STARTTLS nor authentication, so clients send with TLS
+// mode none.
+package mailtest
+
+import (
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message is a message the server accepted.
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// Server is a running test SMTP server.
+type Server struct {
+	Host string
+	Port int
+
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	messages []Message
+	reject   map[string]bool
+}
+
+// NewServer starts a server on a free port of 127.0.0.1. Close it when
+// done.
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic("mailtest: could not listen " + err.Error())
+	}
+
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &Server{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		listener: listener,
+		reject:   map[string]bool{},
+	}
+
+	s.wg.Add(1)
+
+	go s.serve()
+
+	return s
+}
+
+// Reject makes the server refuse address as a recipient.
+func (s *Server) Reject(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reject[strings.ToLower(address)] = true
+}
+
+// Messages returns the messages accepted so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops the server and waits for open sessions to end.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+
+			s.session(textproto.NewConn(conn))
+		}()
+	}
+}
+
+// session speaks just enough SMTP for net/smtp: EHLO, MAIL, RCPT, DATA,
+// RSET, NOOP and QUIT.
+func (s *Server) session(conn *textproto.Conn) {
+	var message Message
+
+	reply := func(code int, text string) bool {
+		return conn.PrintfLine("%d %v", code, text) == nil
+	}
+
+	if !reply(220, "mailtest ESMTP") {
+		return
+	}
+
+	for {
+		line, err := conn.ReadLine()
+
+		if err != nil {
+			return
+		}
+
+		verb, argument, _ := strings.Cut(line, " ")
+		ok := true
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			ok = reply(250, "mailtest greets "+argument)
+		case "MAIL":
+			message = Message{From: address(argument, "FROM:")}
+			ok = reply(250, "sender ok")
+		case "RCPT":
+			to := address(argument, "TO:")
+
+			s.mu.Lock()
+			rejected := s.reject[strings.ToLower(to)]
+			s.mu.Unlock()
+
+			if rejected {
+				ok = reply(550, "no such user "+to)
+				break
+			}
+
+			message.To = append(message.To, to)
+			ok = reply(250, "recipient ok")
+		case "DATA":
+			if len(message.To) == 0 {
+				ok = reply(503, "need RCPT first")
+				break
+			}
+
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+
+			data, err := io.ReadAll(conn.DotReader())
+
+			if err != nil {
+				return
+			}
+
+			message.Data = data
+
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+
+			message = Message{}
+			ok = reply(250, "queued as "+strconv.Itoa(len(s.Messages())))
+		case "RSET":
+			message = Message{}
+			ok = reply(250, "reset")
+		case "NOOP":
+			ok = reply(250, "ok")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			ok = reply(502, "command not implemented")
+		}
+
+		if !ok {
+			return
+		}
+	}
+}
+
+// address returns the address of a MAIL FROM:<a> or RCPT TO:<a> argument,
+// without any parameters after it.
+func address(argument, prefix string) string {
+	if len(argument) >= len(prefix) && strings.EqualFold(argument[:len(prefix)], prefix) {
+		argument = argument[len(prefix):]
+	}
+
+	argument, _, _ = strings.Cut(strings.TrimSpace(argument), " ")
+
+	return strings.Trim(argument, "<>")
+}
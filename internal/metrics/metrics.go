@@ -0,0 +1,308 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text exposition format, so a Prometheus server can scrape
+// them without gator depending on the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of histograms of
+// network and database latency.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Registry holds metrics in the order they were created.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// ServeHTTP writes every metric of the registry.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", ContentType)
+
+	buffered := bufio.NewWriter(w)
+
+	for _, m := range metrics {
+		m.write(buffered)
+	}
+
+	buffered.Flush()
+}
+
+// desc is what every kind of metric has: a name, help text and the names
+// of its labels. Series are keyed by their label values joined with a
+// separator that cannot appear in valid UTF-8.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+const labelSeparator = "\xff"
+
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %v wants %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, labelSeparator)
+}
+
+func (d desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %v %v\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %v %v\n", d.name, d.kind)
+}
+
+// series formats name{labels} for the label values in key, with extra
+// appended as the last label when given.
+func (d desc) series(name, key string, extra ...string) string {
+	var pairs []string
+
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, d.labels[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return name
+	}
+
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a value that only goes up, like a number of requests.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates a counter with the given label names and adds it to
+// the registry.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name: name, help: help, kind: "counter", labels: labels},
+		values: map[string]float64{},
+	}
+
+	r.add(c)
+
+	return c
+}
+
+// Inc adds one to the series of the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series of the label
+// values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w)
+
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%v 0\n", c.name)
+	}
+
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%v %v\n", c.series(c.name, key), formatValue(c.values[key]))
+	}
+}
+
+// Gauge is a value that goes up and down, like the age of a queue.
+type Gauge struct {
+	desc
+	mu    sync.Mutex
+	value float64
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{
+		desc: desc{name: name, help: help, kind: "gauge"},
+	}
+
+	r.add(g)
+
+	return g
+}
+
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.value = v
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.header(w)
+	fmt.Fprintf(w, "%v %v\n", g.name, formatValue(g.value))
+}
+
+// Histogram counts observations, like durations, in buckets of upper
+// bounds, and keeps their sum.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a histogram with the given upper bounds, sorted
+// ascending, and label names and adds it to the registry.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		values:  map[string]*histogramSeries{},
+	}
+
+	r.add(h)
+
+	return h
+}
+
+// Observe records v in the series of the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.values[key]
+
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = series
+	}
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			series.counts[i]++
+		}
+	}
+
+	series.count++
+	series.sum += v
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w)
+
+	if len(h.labels) == 0 && len(h.values) == 0 {
+		h.values[""] = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}
+
+	for _, key := range sortedKeys(h.values) {
+		series := h.values[key]
+
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%v %d\n", h.series(h.name+"_bucket", key, "le", formatValue(bound)), series.counts[i])
+		}
+
+		fmt.Fprintf(w, "%v %d\n", h.series(h.name+"_bucket", key, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%v %v\n", h.series(h.name+"_sum", key), formatValue(series.sum))
+		fmt.Fprintf(w, "%v %d\n", h.series(h.name+"_count", key), series.count)
+	}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,193 @@
+package opml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// FolderSeparator joins the titles of nested folder outlines.
+const FolderSeparator = "/"
+
+type Document struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	URL      string    `xml:"url,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Subscription is a feed outline together with the folder it was found in.
+type Subscription struct {
+	Title   string
+	XMLURL  string
+	HTMLURL string
+	Folder  string
+}
+
+func Parse(r io.Reader) (*Document, error) {
+	document := &Document{}
+
+	err := xml.NewDecoder(r).Decode(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if document.XMLName.Local != "opml" {
+		return nil, errors.New("not an opml document")
+	}
+
+	return document, nil
+}
+
+// Subscriptions flattens the outline tree into feed subscriptions. Outlines
+// without a feed url but with children are treated as folders; anything else
+// without a feed url is still returned so callers can report it as invalid.
+func (d *Document) Subscriptions() []Subscription {
+	subscriptions := []Subscription{}
+
+	var walk func(outlines []Outline, folder string)
+
+	walk = func(outlines []Outline, folder string) {
+		for _, outline := range outlines {
+			feedUrl := strings.TrimSpace(outline.XMLURL)
+
+			if feedUrl == "" && outline.Type == "rss" {
+				feedUrl = strings.TrimSpace(outline.URL)
+			}
+
+			if feedUrl == "" && len(outline.Outlines) > 0 {
+				walk(outline.Outlines, joinFolder(folder, outline.name()))
+				continue
+			}
+
+			subscriptions = append(subscriptions, Subscription{
+				Title:   outline.name(),
+				XMLURL:  feedUrl,
+				HTMLURL: strings.TrimSpace(outline.HTMLURL),
+				Folder:  folder,
+			})
+
+			if len(outline.Outlines) > 0 {
+				walk(outline.Outlines, folder)
+			}
+		}
+	}
+
+	walk(d.Body.Outlines, "")
+
+	return subscriptions
+}
+
+func (o Outline) name() string {
+	if strings.TrimSpace(o.Title) != "" {
+		return strings.TrimSpace(o.Title)
+	}
+
+	return strings.TrimSpace(o.Text)
+}
+
+func joinFolder(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	if name == "" {
+		return parent
+	}
+
+	return parent + FolderSeparator + name
+}
+
+// NewDocument builds an OPML 2.0 document from subscriptions, nesting them
+// under folder outlines split on FolderSeparator.
+func NewDocument(title string, subscriptions []Subscription, created time.Time) *Document {
+	document := &Document{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: created.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, subscription := range subscriptions {
+		outlines := &document.Body.Outlines
+
+		if subscription.Folder != "" {
+			for _, name := range strings.Split(subscription.Folder, FolderSeparator) {
+				outlines = folderOutlines(outlines, name)
+			}
+		}
+
+		*outlines = append(*outlines, Outline{
+			Text:    subscription.Title,
+			Title:   subscription.Title,
+			Type:    "rss",
+			XMLURL:  subscription.XMLURL,
+			HTMLURL: subscription.HTMLURL,
+		})
+	}
+
+	return document
+}
+
+// folderOutlines returns the children of the folder called name, adding the
+// folder when it does not exist yet.
+func folderOutlines(outlines *[]Outline, name string) *[]Outline {
+	for i := range *outlines {
+		folder := &(*outlines)[i]
+
+		if folder.XMLURL == "" && folder.Text == name {
+			return &folder.Outlines
+		}
+	}
+
+	*outlines = append(*outlines, Outline{
+		Text:  name,
+		Title: name,
+	})
+
+	return &(*outlines)[len(*outlines)-1].Outlines
+}
+
+func Write(w io.Writer, document *Document) error {
+	_, err := io.WriteString(w, xml.Header)
+
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	err = encoder.Encode(document)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+
+	return err
+}
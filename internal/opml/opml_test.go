@@ -0,0 +1,105 @@
+package opml
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const nestedOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Exported from another reader</title></head>
+  <body>
+    <outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom" htmlUrl="https://go.dev/blog"/>
+    <outline text="Tech">
+      <outline title="Hacker News" text="HN" type="rss" xmlUrl="https://news.ycombinator.com/rss"/>
+      <outline text="Languages">
+        <outline text="Rust Blog" type="rss" xmlUrl="https://blog.rust-lang.org/feed.xml" htmlUrl="https://blog.rust-lang.org/"/>
+        <outline text="Zig &amp; friends" type="rss" url="https://ziglang.org/news/index.xml"/>
+      </outline>
+      <outline text="LWN" type="rss" xmlUrl="https://lwn.net/headlines/rss"/>
+    </outline>
+    <outline text="News">
+      <outline text="Languages">
+        <outline text="Lang News" type="rss" xmlUrl="https://example.com/lang.xml"/>
+      </outline>
+    </outline>
+  </body>
+</opml>`
+
+func sortedSubscriptions(subscriptions []Subscription) []Subscription {
+	sorted := append([]Subscription{}, subscriptions...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].XMLURL < sorted[j].XMLURL
+	})
+
+	return sorted
+}
+
+func TestRoundTrip(t *testing.T) {
+	document, err := Parse(strings.NewReader(nestedOPML))
+
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	subscriptions := document.Subscriptions()
+
+	want := []Subscription{
+		{Title: "Go Blog", XMLURL: "https://go.dev/blog/feed.atom", HTMLURL: "https://go.dev/blog"},
+		{Title: "Hacker News", XMLURL: "https://news.ycombinator.com/rss", Folder: "Tech"},
+		{Title: "Rust Blog", XMLURL: "https://blog.rust-lang.org/feed.xml", HTMLURL: "https://blog.rust-lang.org/", Folder: "Tech/Languages"},
+		{Title: "Zig & friends", XMLURL: "https://ziglang.org/news/index.xml", Folder: "Tech/Languages"},
+		{Title: "LWN", XMLURL: "https://lwn.net/headlines/rss", Folder: "Tech"},
+		{Title: "Lang News", XMLURL: "https://example.com/lang.xml", Folder: "News/Languages"},
+	}
+
+	if !reflect.DeepEqual(subscriptions, want) {
+		t.Fatalf("Subscriptions =\n%+v\nwant\n%+v", subscriptions, want)
+	}
+
+	created := time.Date(2024, 8, 13, 7, 30, 0, 0, time.UTC)
+
+	var written bytes.Buffer
+
+	err = Write(&written, NewDocument("alice's gator subscriptions", subscriptions, created))
+
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	reparsed, err := Parse(&written)
+
+	if err != nil {
+		t.Fatalf("Parse of written document: %v\n%s", err, written.String())
+	}
+
+	if reparsed.Version != "2.0" || reparsed.Head.Title != "alice's gator subscriptions" || reparsed.Head.DateCreated != "Tue, 13 Aug 2024 07:30:00 +0000" {
+		t.Errorf("head = %v %+v, want version 2.0 with the title and creation date", reparsed.Version, reparsed.Head)
+	}
+
+	// Folders are grouped on export, so only the order of subscriptions
+	// may change.
+	if got := reparsed.Subscriptions(); !reflect.DeepEqual(sortedSubscriptions(got), sortedSubscriptions(subscriptions)) {
+		t.Errorf("round trip lost subscriptions:\ngot  %+v\nwant %+v", got, subscriptions)
+	}
+
+	// Folders of the same name under different parents stay apart.
+	tech := reparsed.Body.Outlines[1]
+
+	if tech.Text != "Tech" || len(tech.Outlines) != 3 || tech.Outlines[1].Text != "Languages" || len(tech.Outlines[1].Outlines) != 2 {
+		t.Errorf("Tech folder = %+v, want Hacker News, Languages with 2 feeds and LWN", tech)
+	}
+}
+
+func TestParseRejectsOtherDocuments(t *testing.T) {
+	for _, input := range []string{`<rss version="2.0"><channel/></rss>`, `not xml`, ``} {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type Format string
+
+const (
+	Text   Format = "text"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+	Table  Format = "table"
+)
+
+var Formats = []Format{Text, JSON, NDJSON, CSV, Table}
+
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown output format %v, expecting one of text, json, ndjson, csv or table", name)
+}
+
+// Texter is implemented by results with a human readable rendering. Results
+// without one are printed as a table in text mode.
+type Texter interface {
+	Text() string
+}
+
+// Lister is implemented by results that wrap a list of records. The json
+// format encodes the whole result while ndjson, csv and table only render the
+// records.
+type Lister interface {
+	Records() any
+}
+
+func Render(w io.Writer, format Format, result any) error {
+	if result == nil {
+		return nil
+	}
+
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case NDJSON:
+		return renderNDJSON(w, result)
+	case CSV:
+		return renderCSV(w, result)
+	case Table:
+		return renderTable(w, result)
+	}
+
+	texter, ok := result.(Texter)
+
+	if !ok {
+		return renderTable(w, result)
+	}
+
+	text := texter.Text()
+
+	if text == "" {
+		return nil
+	}
+
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	_, err := io.WriteString(w, text)
+
+	return err
+}
+
+func renderNDJSON(w io.Writer, result any) error {
+	encoder := json.NewEncoder(w)
+
+	for _, record := range records(result) {
+		err := encoder.Encode(record.Interface())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func renderCSV(w io.Writer, result any) error {
+	columns, rows := tabulate(result)
+
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(columns)
+
+	if err != nil {
+		return err
+	}
+
+	err = writer.WriteAll(rows)
+
+	if err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+func renderTable(w io.Writer, result any) error {
+	columns, rows := tabulate(result)
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := make([]string, len(columns))
+
+	for i, column := range columns {
+		header[i] = strings.ToUpper(column)
+	}
+
+	fmt.Fprintln(writer, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		cells := make([]string, len(row))
+
+		for i, cell := range row {
+			cells[i] = strings.ReplaceAll(cell, "\n", " ")
+		}
+
+		fmt.Fprintln(writer, strings.Join(cells, "\t"))
+	}
+
+	return writer.Flush()
+}
+
+// records returns the individual records of a result: the elements of a
+// slice, or the result itself.
+func records(result any) []reflect.Value {
+	lister, ok := result.(Lister)
+
+	if ok {
+		result = lister.Records()
+	}
+
+	value := reflect.ValueOf(result)
+
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return []reflect.Value{value}
+	}
+
+	values := make([]reflect.Value, value.Len())
+
+	for i := range values {
+		values[i] = value.Index(i)
+	}
+
+	return values
+}
+
+func recordType(result any) reflect.Type {
+	lister, ok := result.(Lister)
+
+	if ok {
+		result = lister.Records()
+	}
+
+	recordType := reflect.TypeOf(result)
+
+	if recordType.Kind() == reflect.Slice || recordType.Kind() == reflect.Array {
+		recordType = recordType.Elem()
+	}
+
+	for recordType.Kind() == reflect.Pointer {
+		recordType = recordType.Elem()
+	}
+
+	return recordType
+}
+
+type column struct {
+	name  string
+	index int
+}
+
+// columns lists the exported struct fields with their json names. Non struct
+// records are rendered in a single value column.
+func columns(recordType reflect.Type) []column {
+	if recordType.Kind() != reflect.Struct {
+		return []column{{name: "value", index: -1}}
+	}
+
+	fields := []column{}
+
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		tag := field.Tag.Get("json")
+
+		if tag == "-" {
+			continue
+		}
+
+		if tag != "" {
+			tagName, _, _ := strings.Cut(tag, ",")
+
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		fields = append(fields, column{name: name, index: i})
+	}
+
+	return fields
+}
+
+func tabulate(result any) ([]string, [][]string) {
+	fields := columns(recordType(result))
+
+	names := make([]string, len(fields))
+
+	for i, field := range fields {
+		names[i] = field.name
+	}
+
+	rows := [][]string{}
+
+	for _, record := range records(result) {
+		record = indirect(record)
+
+		row := make([]string, len(fields))
+
+		for i, field := range fields {
+			if field.index < 0 || !record.IsValid() {
+				row[i] = formatValue(record)
+				continue
+			}
+
+			row[i] = formatValue(record.Field(field.index))
+		}
+
+		rows = append(rows, row)
+	}
+
+	return names, rows
+}
+
+// indirect follows pointers and interfaces, returning the zero Value for nil.
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+
+		value = value.Elem()
+	}
+
+	return value
+}
+
+func formatValue(value reflect.Value) string {
+	value = indirect(value)
+
+	if !value.IsValid() {
+		return ""
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String {
+		parts := make([]string, value.Len())
+
+		for i := range parts {
+			parts[i] = value.Index(i).String()
+		}
+
+		return strings.Join(parts, ";")
+	}
+
+	return fmt.Sprint(value.Interface())
+}
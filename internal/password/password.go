@@ -0,0 +1,86 @@
+// Package password hashes user passwords with PBKDF2-HMAC-SHA256 and a
+// random salt. Hashes are stored as pbkdf2-sha256$iterations$salt$key with
+// the salt and key in unpadded base64, so the cost can be raised later
+// without breaking existing passwords.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	scheme     = "pbkdf2-sha256"
+	iterations = 600000
+	saltLength = 16
+	keyLength  = 32
+)
+
+// Hash returns the encoded hash of password.
+func Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+
+	_, err := rand.Read(salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	key := pbkdf2.Key([]byte(password), salt, iterations, keyLength, sha256.New)
+
+	return fmt.Sprintf("%v$%d$%v$%v", scheme, iterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// dummyHash is verified against when there is no hash to check, so a
+// missing user takes as long to reject as a wrong password.
+var dummyHash = fmt.Sprintf("%v$%d$%v$%v", scheme, iterations,
+	base64.RawStdEncoding.EncodeToString(make([]byte, saltLength)),
+	base64.RawStdEncoding.EncodeToString(make([]byte, keyLength)),
+)
+
+// VerifyNothing spends the time of a Verify that fails, for logins of
+// users that do not exist or have no password.
+func VerifyNothing(password string) {
+	Verify(password, dummyHash)
+}
+
+// Verify reports whether password matches an encoded hash made by Hash.
+func Verify(password, encoded string) bool {
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != 4 || parts[0] != scheme {
+		return false
+	}
+
+	rounds, err := strconv.Atoi(parts[1])
+
+	if err != nil || rounds < 1 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return false
+	}
+
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+
+	if err != nil || len(want) == 0 {
+		return false
+	}
+
+	got := pbkdf2.Key([]byte(password), salt, rounds, len(want), sha256.New)
+
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
@@ -0,0 +1,79 @@
+package password
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hash, err := Hash("correct horse")
+
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$600000$") {
+		t.Errorf("hash = %v, want pbkdf2-sha256$600000$...", hash)
+	}
+
+	if !Verify("correct horse", hash) {
+		t.Errorf("Verify rejects the hashed password")
+	}
+
+	if Verify("correct horse ", hash) {
+		t.Errorf("Verify accepts another password")
+	}
+
+	other, _ := Hash("correct horse")
+
+	if other == hash {
+		t.Errorf("two hashes of a password are equal, want a random salt")
+	}
+}
+
+// Hashes stored before gator used x/crypto must still verify: this one is
+// the first 32 bytes of the RFC 7914 PBKDF2-HMAC-SHA256 test vector for
+// "passwd" and "salt".
+func TestVerifyKnownHash(t *testing.T) {
+	hash := "pbkdf2-sha256$1$c2FsdA$VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLw"
+
+	if !Verify("passwd", hash) {
+		t.Errorf("Verify rejects the known hash")
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"bcrypt$1$c2FsdA$VTvd3bWUOKWVp3oP5b7u4ay3uXvWmLmVOPrDjjNz/z4",
+		"pbkdf2-sha256$0$c2FsdA$VTvd3bWUOKWVp3oP5b7u4ay3uXvWmLmVOPrDjjNz/z4",
+		"pbkdf2-sha256$1$!!$VTvd3bWUOKWVp3oP5b7u4ay3uXvWmLmVOPrDjjNz/z4",
+		"pbkdf2-sha256$1$c2FsdA$",
+		"pbkdf2-sha256$1$c2FsdA",
+	} {
+		if Verify("passwd", hash) {
+			t.Errorf("Verify accepts malformed hash %q", hash)
+		}
+	}
+}
+
+func TestVerifyNothingTakesAsLong(t *testing.T) {
+	if testing.Short() {
+		t.Skip("hashes at full cost")
+	}
+
+	hash, _ := Hash("correct horse")
+
+	start := time.Now()
+	Verify("wrong", hash)
+	verify := time.Since(start)
+
+	start = time.Now()
+	VerifyNothing("wrong")
+	nothing := time.Since(start)
+
+	if nothing < verify/2 {
+		t.Errorf("VerifyNothing took %v, a failed Verify %v", nothing, verify)
+	}
+}
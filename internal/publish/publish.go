@@ -0,0 +1,410 @@
+// Package publish renders a list of posts as a feed other readers can
+// subscribe to: RSS 2.0, Atom 1.0 or JSON Feed 1.1. Every item keeps a link
+// to the feed it was aggregated from.
+package publish
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Format is a feed document format.
+type Format string
+
+const (
+	RSS  Format = "rss"
+	Atom Format = "atom"
+	JSON Format = "json"
+)
+
+// Formats lists the supported formats, RSS first.
+var Formats = []Format{RSS, Atom, JSON}
+
+// ParseFormat returns the format named by name.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown feed format %q, expecting rss, atom or json", name)
+}
+
+// FormatForFile returns the format whose Filename is file.
+func FormatForFile(file string) (Format, bool) {
+	for _, format := range Formats {
+		if format.Filename() == file {
+			return format, true
+		}
+	}
+
+	return "", false
+}
+
+// ContentType is the media type a format is served with.
+func (f Format) ContentType() string {
+	switch f {
+	case Atom:
+		return "application/atom+xml; charset=utf-8"
+	case JSON:
+		return "application/feed+json; charset=utf-8"
+	default:
+		return "application/rss+xml; charset=utf-8"
+	}
+}
+
+// Filename is the last path segment a format is published under.
+func (f Format) Filename() string {
+	switch f {
+	case Atom:
+		return "atom.xml"
+	case JSON:
+		return "feed.json"
+	default:
+		return "rss.xml"
+	}
+}
+
+// Feed is an aggregated feed. ID is a stable URI identifying it, Link its
+// home page and Self the url of the document being written.
+type Feed struct {
+	ID          string
+	Title       string
+	Description string
+	Link        string
+	Self        string
+	Author      string
+	Updated     time.Time
+	Items       []Item
+}
+
+// Item is a post of an aggregated feed. Content is HTML.
+type Item struct {
+	ID         string
+	Title      string
+	Link       string
+	Content    string
+	Author     string
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+	Source     Source
+}
+
+// Source is the feed an item was originally published in.
+type Source struct {
+	Title   string
+	Link    string
+	FeedUrl string
+}
+
+// Write renders feed in format to w.
+func Write(w io.Writer, format Format, feed Feed) error {
+	switch format {
+	case RSS:
+		return writeXML(w, newRSS(feed))
+	case Atom:
+		return writeXML(w, newAtom(feed))
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+
+		return encoder.Encode(newJSONFeed(feed))
+	}
+
+	return fmt.Errorf("unknown feed format %q", format)
+}
+
+func writeXML(w io.Writer, document any) error {
+	_, err := io.WriteString(w, xml.Header)
+
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	err = encoder.Encode(document)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+
+	return err
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	Self          rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	GUID        rssGUID   `xml:"guid"`
+	PubDate     string    `xml:"pubDate"`
+	Creator     string    `xml:"dc:creator,omitempty"`
+	Categories  []string  `xml:"category"`
+	Description string    `xml:"description"`
+	Source      rssSource `xml:"source"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssSource struct {
+	URL   string `xml:"url,attr"`
+	Title string `xml:",chardata"`
+}
+
+func newRSS(feed Feed) rssDocument {
+	channel := rssChannel{
+		Title:         feed.Title,
+		Link:          feed.Link,
+		Description:   feed.Description,
+		LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+		Generator:     "gator",
+		Self: rssLink{
+			Href: feed.Self,
+			Rel:  "self",
+			Type: "application/rss+xml",
+		},
+	}
+
+	if channel.Description == "" {
+		channel.Description = feed.Title
+	}
+
+	for _, item := range feed.Items {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{Value: item.ID},
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+			Creator:     item.Author,
+			Categories:  item.Categories,
+			Description: item.Content,
+			Source: rssSource{
+				URL:   item.Source.FeedUrl,
+				Title: item.Source.Title,
+			},
+		})
+	}
+
+	return rssDocument{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	}
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    atomPerson  `xml:"author"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomText       `xml:"content"`
+	Source     atomSource     `xml:"source"`
+}
+
+type atomSource struct {
+	ID    string     `xml:"id"`
+	Title string     `xml:"title"`
+	Links []atomLink `xml:"link"`
+}
+
+func newAtom(feed Feed) atomFeed {
+	document := atomFeed{
+		ID:        feed.ID,
+		Title:     feed.Title,
+		Subtitle:  feed.Description,
+		Updated:   feed.Updated.UTC().Format(time.RFC3339),
+		Links:     links(feed.Link, feed.Self, "application/atom+xml"),
+		Author:    atomPerson{Name: feed.Author},
+		Generator: "gator",
+	}
+
+	for _, item := range feed.Items {
+		entry := atomEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Links:     links(item.Link, "", ""),
+			Published: item.Published.UTC().Format(time.RFC3339),
+			Updated:   item.Updated.UTC().Format(time.RFC3339),
+			Content: atomText{
+				Type:  "html",
+				Value: item.Content,
+			},
+			Source: atomSource{
+				ID:    item.Source.FeedUrl,
+				Title: item.Source.Title,
+				Links: links(item.Source.Link, item.Source.FeedUrl, ""),
+			},
+		}
+
+		if item.Author != "" {
+			entry.Author = &atomPerson{Name: item.Author}
+		}
+
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+
+		document.Entries = append(document.Entries, entry)
+	}
+
+	return document
+}
+
+// links returns the alternate and self links that are set.
+func links(alternate, self, selfType string) []atomLink {
+	result := []atomLink{}
+
+	if alternate != "" {
+		result = append(result, atomLink{Href: alternate, Rel: "alternate", Type: "text/html"})
+	}
+
+	if self != "" {
+		result = append(result, atomLink{Href: self, Rel: "self", Type: selfType})
+	}
+
+	return result
+}
+
+type jsonFeed struct {
+	Version     string       `json:"version"`
+	Title       string       `json:"title"`
+	HomePageURL string       `json:"home_page_url,omitempty"`
+	FeedURL     string       `json:"feed_url,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Authors     []jsonAuthor `json:"authors,omitempty"`
+	Items       []jsonItem   `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url,omitempty"`
+	Title         string       `json:"title,omitempty"`
+	ContentHTML   string       `json:"content_html"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+	Source        jsonSource   `json:"_source"`
+}
+
+// jsonSource is an extension object, JSON Feed has no field for the feed an
+// item was aggregated from.
+type jsonSource struct {
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url,omitempty"`
+	FeedURL     string `json:"feed_url"`
+}
+
+func newJSONFeed(feed Feed) jsonFeed {
+	document := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: feed.Link,
+		FeedURL:     feed.Self,
+		Description: feed.Description,
+		Items:       make([]jsonItem, 0, len(feed.Items)),
+	}
+
+	if feed.Author != "" {
+		document.Authors = []jsonAuthor{{Name: feed.Author}}
+	}
+
+	for _, item := range feed.Items {
+		entry := jsonItem{
+			ID:            item.ID,
+			URL:           item.Link,
+			Title:         item.Title,
+			ContentHTML:   item.Content,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+			DateModified:  item.Updated.UTC().Format(time.RFC3339),
+			Tags:          item.Categories,
+			Source: jsonSource{
+				Title:       item.Source.Title,
+				HomePageURL: item.Source.Link,
+				FeedURL:     item.Source.FeedUrl,
+			},
+		}
+
+		if item.Author != "" {
+			entry.Authors = []jsonAuthor{{Name: item.Author}}
+		}
+
+		document.Items = append(document.Items, entry)
+	}
+
+	return document
+}
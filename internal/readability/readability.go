@@ -0,0 +1,424 @@
+// Package readability extracts the main content of an article page, in the
+// spirit of Arc90's readability: paragraphs are scored by their length and
+// punctuation, the scores bubble up to their ancestors and the best scoring
+// container, with its related siblings, is kept. The result is rebuilt from
+// the sanitizer's allowlist with absolute links.
+package readability
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mambo-dev/gator/internal/sanitize"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoContent is returned when nothing in the page looks like an article.
+var ErrNoContent = errors.New("no article content found")
+
+// Article is the extracted main content of a page.
+type Article struct {
+	Title string
+	// Content is the article as sanitized HTML.
+	Content string
+}
+
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote|share|subscribe|newsletter|cookie`)
+	maybeCandidates    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveNames      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeNames      = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	whitespace         = regexp.MustCompile(`\s+`)
+)
+
+// Extract finds the main content of the HTML page read from r. pageURL is
+// where the page was fetched from and is used to make links absolute.
+func Extract(r io.Reader, pageURL *url.URL) (*Article, error) {
+	doc, err := html.Parse(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	e := &extractor{
+		base:   baseURL(doc, pageURL),
+		scores: make(map[*html.Node]float64),
+	}
+
+	title := strings.TrimSpace(textOf(find(doc, atom.Title)))
+	body := find(doc, atom.Body)
+
+	if body == nil {
+		return nil, ErrNoContent
+	}
+
+	e.prune(body)
+
+	content := e.topCandidate(body)
+
+	if content == nil {
+		return nil, ErrNoContent
+	}
+
+	nodes := e.siblings(content)
+
+	for _, node := range nodes {
+		e.clean(node)
+	}
+
+	article := &Article{
+		Title:   title,
+		Content: sanitize.Nodes(nodes, e.base),
+	}
+
+	if textLength(content) == 0 || article.Content == "" {
+		return nil, ErrNoContent
+	}
+
+	return article, nil
+}
+
+type extractor struct {
+	base   *url.URL
+	scores map[*html.Node]float64
+}
+
+// prune removes the elements that never hold article text and those whose
+// class or id make them unlikely to.
+func (e *extractor) prune(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		if child.Type == html.CommentNode {
+			node.RemoveChild(child)
+		} else if child.Type == html.ElementNode {
+			if dropped[child.DataAtom] || child.DataAtom == atom.Nav || child.DataAtom == atom.Aside || child.DataAtom == atom.Footer || child.DataAtom == atom.Form || unlikely(child) {
+				node.RemoveChild(child)
+			} else {
+				e.prune(child)
+			}
+		}
+
+		child = next
+	}
+}
+
+func unlikely(node *html.Node) bool {
+	if node.DataAtom == atom.Body || node.DataAtom == atom.Article || node.DataAtom == atom.Main || node.DataAtom == atom.A {
+		return false
+	}
+
+	names := attr(node, "class") + " " + attr(node, "id")
+
+	if attr(node, "role") == "complementary" || attr(node, "aria-hidden") == "true" {
+		return true
+	}
+
+	return unlikelyCandidates.MatchString(names) && !maybeCandidates.MatchString(names)
+}
+
+// topCandidate scores every paragraph into its ancestors and returns the
+// best scoring one, adjusted for its link density.
+func (e *extractor) topCandidate(body *html.Node) *html.Node {
+	candidates := []*html.Node{}
+
+	walk(body, func(node *html.Node) {
+		if node.DataAtom != atom.P && node.DataAtom != atom.Pre && node.DataAtom != atom.Td && !isTextDiv(node) {
+			return
+		}
+
+		text := innerText(node)
+		length := utf8.RuneCountInString(text)
+
+		if length < 25 {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(length/100), 3)
+		ancestor := node.Parent
+
+		for level := 0; ancestor != nil && ancestor.Type == html.ElementNode && level < 5; level++ {
+			if _, ok := e.scores[ancestor]; !ok {
+				e.scores[ancestor] = initialScore(ancestor)
+				candidates = append(candidates, ancestor)
+			}
+
+			switch level {
+			case 0:
+				e.scores[ancestor] += score
+			case 1:
+				e.scores[ancestor] += score / 2
+			default:
+				e.scores[ancestor] += score / float64(level*3)
+			}
+
+			ancestor = ancestor.Parent
+		}
+	})
+
+	var top *html.Node
+	best := 0.0
+
+	for _, candidate := range candidates {
+		e.scores[candidate] *= 1 - linkDensity(candidate)
+
+		if top == nil || e.scores[candidate] > best {
+			top = candidate
+			best = e.scores[candidate]
+		}
+	}
+
+	if top == nil {
+		return body
+	}
+
+	return top
+}
+
+// siblings returns the top candidate together with the siblings that look
+// like part of the same article: well scored or plain paragraphs of text.
+func (e *extractor) siblings(top *html.Node) []*html.Node {
+	if top.Parent == nil || top.DataAtom == atom.Body {
+		return []*html.Node{top}
+	}
+
+	threshold := math.Max(10, e.scores[top]*0.2)
+	nodes := []*html.Node{}
+
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling == top {
+			nodes = append(nodes, sibling)
+			continue
+		}
+
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+
+		score, scored := e.scores[sibling]
+
+		if scored && score >= threshold {
+			nodes = append(nodes, sibling)
+			continue
+		}
+
+		if sibling.DataAtom != atom.P {
+			continue
+		}
+
+		text := innerText(sibling)
+		length := utf8.RuneCountInString(text)
+		density := linkDensity(sibling)
+
+		if (length > 80 && density < 0.25) || (length > 0 && density == 0 && strings.Contains(text, ". ")) {
+			nodes = append(nodes, sibling)
+		}
+	}
+
+	return nodes
+}
+
+// clean drops the containers inside the article that are mostly links or
+// that are marked as something else than content.
+func (e *extractor) clean(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		if child.Type == html.ElementNode {
+			if e.clutter(child) {
+				node.RemoveChild(child)
+			} else {
+				e.clean(child)
+			}
+		}
+
+		child = next
+	}
+}
+
+func (e *extractor) clutter(node *html.Node) bool {
+	switch node.DataAtom {
+	case atom.Div, atom.Section, atom.Ul, atom.Ol, atom.Table, atom.Header:
+	default:
+		return false
+	}
+
+	if classWeight(node) < 0 {
+		return true
+	}
+
+	length := textLength(node)
+
+	if length == 0 && find(node, atom.Img) == nil && find(node, atom.Pre) == nil {
+		return true
+	}
+
+	return linkDensity(node) > 0.5 && length < 500
+}
+
+func initialScore(node *html.Node) float64 {
+	score := classWeight(node)
+
+	switch node.DataAtom {
+	case atom.Div, atom.Article, atom.Main:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+
+	return score
+}
+
+func classWeight(node *html.Node) float64 {
+	weight := 0.0
+
+	for _, name := range []string{attr(node, "class"), attr(node, "id")} {
+		if name == "" {
+			continue
+		}
+
+		if negativeNames.MatchString(name) {
+			weight -= 25
+		}
+
+		if positiveNames.MatchString(name) {
+			weight += 25
+		}
+	}
+
+	return weight
+}
+
+// isTextDiv reports whether node is a div used as a paragraph, holding text
+// and inline elements only.
+func isTextDiv(node *html.Node) bool {
+	if node.DataAtom != atom.Div {
+		return false
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && blocks[child.DataAtom] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func linkDensity(node *html.Node) float64 {
+	length := textLength(node)
+
+	if length == 0 {
+		return 0
+	}
+
+	links := 0
+
+	walk(node, func(n *html.Node) {
+		if n.DataAtom == atom.A {
+			links += textLength(n)
+		}
+	})
+
+	return float64(links) / float64(length)
+}
+
+var blocks = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Div: true, atom.Dl: true, atom.Figure: true, atom.Footer: true, atom.Form: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Main: true, atom.Nav: true, atom.Ol: true,
+	atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true, atom.Ul: true,
+}
+
+// dropped elements are removed together with their content.
+var dropped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Object: true, atom.Embed: true, atom.Svg: true, atom.Canvas: true,
+	atom.Button: true, atom.Input: true, atom.Select: true, atom.Textarea: true,
+	atom.Link: true, atom.Meta: true, atom.Template: true, atom.Dialog: true,
+}
+
+// baseURL honours a <base href> in the page.
+func baseURL(doc *html.Node, pageURL *url.URL) *url.URL {
+	base := find(doc, atom.Base)
+
+	if base == nil || pageURL == nil {
+		return pageURL
+	}
+
+	href, err := url.Parse(attr(base, "href"))
+
+	if err != nil {
+		return pageURL
+	}
+
+	return pageURL.ResolveReference(href)
+}
+
+func walk(node *html.Node, visit func(*html.Node)) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode {
+			visit(child)
+			walk(child, visit)
+		}
+	}
+}
+
+func find(node *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+
+	walk(node, func(n *html.Node) {
+		if found == nil && n.DataAtom == a {
+			found = n
+		}
+	})
+
+	return found
+}
+
+func attr(node *html.Node, name string) string {
+	for _, a := range node.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func textOf(node *html.Node) string {
+	if node == nil {
+		return ""
+	}
+
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+
+	var text strings.Builder
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(textOf(child))
+	}
+
+	return text.String()
+}
+
+func innerText(node *html.Node) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(textOf(node), " "))
+}
+
+func textLength(node *html.Node) int {
+	return utf8.RuneCountInString(innerText(node))
+}
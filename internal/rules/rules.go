@@ -0,0 +1,229 @@
+// Package rules matches posts against a user's filter rules and decides what
+// to do with them: hide them, mark them read, star them or tag them.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mambo-dev/gator/internal/htmltext"
+)
+
+// Field is the part of a post a rule looks at.
+type Field string
+
+const (
+	FieldAny      Field = "any"
+	FieldTitle    Field = "title"
+	FieldContent  Field = "content"
+	FieldAuthor   Field = "author"
+	FieldCategory Field = "category"
+	FieldFeed     Field = "feed"
+)
+
+// Fields lists the valid fields, FieldAny first.
+var Fields = []Field{FieldAny, FieldTitle, FieldContent, FieldAuthor, FieldCategory, FieldFeed}
+
+// Action is what a rule does to the posts it applies to.
+type Action string
+
+const (
+	ActionHide Action = "hide"
+	ActionRead Action = "read"
+	ActionStar Action = "star"
+	ActionTag  Action = "tag"
+)
+
+// Actions lists the valid actions.
+var Actions = []Action{ActionHide, ActionRead, ActionStar, ActionTag}
+
+// Post is the information rules match against. Content is HTML.
+type Post struct {
+	Title      string
+	Content    string
+	Author     string
+	Categories []string
+	Feed       string
+}
+
+// Rule applies Action to the posts whose Field contains Pattern, or to those
+// that do not when Exclude is set. Keywords match case-insensitively; regular
+// expressions match as written, (?i) makes them case-insensitive.
+type Rule struct {
+	Field   Field
+	Pattern string
+	Regex   bool
+	Exclude bool
+	Action  Action
+	// Tag is the tag added by ActionTag.
+	Tag string
+
+	regex   *regexp.Regexp
+	keyword string
+}
+
+// New validates a rule and compiles its pattern.
+func New(field, pattern string, regex, exclude bool, action, tag string) (*Rule, error) {
+	r := &Rule{
+		Field:   Field(field),
+		Pattern: pattern,
+		Regex:   regex,
+		Exclude: exclude,
+		Action:  Action(action),
+		Tag:     strings.TrimSpace(tag),
+	}
+
+	if !slices.Contains(Fields, r.Field) {
+		return nil, fmt.Errorf("invalid field %q, expecting one of %v", field, join(Fields))
+	}
+
+	if !slices.Contains(Actions, r.Action) {
+		return nil, fmt.Errorf("invalid action %q, expecting one of %v", action, join(Actions))
+	}
+
+	if r.Action == ActionTag && r.Tag == "" {
+		return nil, errors.New("the tag action needs a tag")
+	}
+
+	if r.Action != ActionTag {
+		r.Tag = ""
+	}
+
+	if strings.TrimSpace(pattern) == "" {
+		return nil, errors.New("the pattern is empty")
+	}
+
+	if regex {
+		compiled, err := regexp.Compile(pattern)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %v", err)
+		}
+
+		r.regex = compiled
+	} else {
+		r.keyword = strings.ToLower(strings.TrimSpace(pattern))
+	}
+
+	return r, nil
+}
+
+// Applies reports whether the rule's action applies to post.
+func (r *Rule) Applies(post Post) bool {
+	return r.Matches(post) != r.Exclude
+}
+
+// Matches reports whether the pattern is found in the rule's field of post.
+func (r *Rule) Matches(post Post) bool {
+	for _, value := range r.values(post) {
+		if r.match(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String describes the rule the way it is shown in listings.
+func (r *Rule) String() string {
+	condition := "contains"
+
+	switch {
+	case r.Regex && r.Exclude:
+		condition = "does not match"
+	case r.Regex:
+		condition = "matches"
+	case r.Exclude:
+		condition = "does not contain"
+	}
+
+	action := string(r.Action)
+
+	if r.Action == ActionTag {
+		action += " " + r.Tag
+	}
+
+	return fmt.Sprintf("%v if %v %v %q", action, r.Field, condition, r.Pattern)
+}
+
+func (r *Rule) match(value string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(value)
+	}
+
+	return strings.Contains(strings.ToLower(value), r.keyword)
+}
+
+func (r *Rule) values(post Post) []string {
+	switch r.Field {
+	case FieldTitle:
+		return []string{post.Title}
+	case FieldContent:
+		return []string{htmltext.Plain(post.Content)}
+	case FieldAuthor:
+		return []string{post.Author}
+	case FieldCategory:
+		return post.Categories
+	case FieldFeed:
+		return []string{post.Feed}
+	}
+
+	values := []string{post.Title, htmltext.Plain(post.Content), post.Author, post.Feed}
+
+	return append(values, post.Categories...)
+}
+
+// Outcome is the combined effect of the rules that apply to a post.
+type Outcome struct {
+	Hide bool
+	Read bool
+	Star bool
+	Tags []string
+}
+
+// Empty reports whether no rule applied.
+func (o Outcome) Empty() bool {
+	return !o.Hide && !o.Read && !o.Star && len(o.Tags) == 0
+}
+
+// Set is the list of rules of one user.
+type Set []*Rule
+
+// Apply runs every rule of the set against post.
+func (s Set) Apply(post Post) Outcome {
+	outcome := Outcome{}
+
+	for _, rule := range s {
+		if !rule.Applies(post) {
+			continue
+		}
+
+		switch rule.Action {
+		case ActionHide:
+			outcome.Hide = true
+		case ActionRead:
+			outcome.Read = true
+		case ActionStar:
+			outcome.Star = true
+		case ActionTag:
+			if !slices.Contains(outcome.Tags, rule.Tag) {
+				outcome.Tags = append(outcome.Tags, rule.Tag)
+			}
+		}
+	}
+
+	return outcome
+}
+
+func join[T ~string](values []T) string {
+	names := make([]string, 0, len(values))
+
+	for _, value := range values {
+		names = append(names, string(value))
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,205 @@
+// Package sanitize cleans the HTML that feeds publish before it is stored.
+// Only an allowlist of elements and attributes survives: scripts, frames,
+// forms, styles and event handlers are removed, tracking pixels are dropped
+// and relative links are made absolute.
+package sanitize
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowed lists the elements that are kept and their allowed attributes.
+// Other elements are replaced by their content.
+var allowed = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Ul: nil, atom.Ol: nil, atom.Li: nil, atom.Dl: nil, atom.Dt: nil, atom.Dd: nil,
+	atom.Blockquote: {"cite"}, atom.Pre: nil, atom.Code: nil, atom.Kbd: nil, atom.Samp: nil,
+	atom.Em: nil, atom.Strong: nil, atom.B: nil, atom.I: nil, atom.U: nil, atom.S: nil,
+	atom.Del: nil, atom.Ins: nil, atom.Sub: nil, atom.Sup: nil, atom.Small: nil,
+	atom.Mark: nil, atom.Cite: nil, atom.Q: {"cite"}, atom.Abbr: {"title"}, atom.Time: {"datetime"},
+	atom.Figure: nil, atom.Figcaption: nil,
+	atom.Table: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil, atom.Tr: nil,
+	atom.Th: {"colspan", "rowspan"}, atom.Td: {"colspan", "rowspan"}, atom.Caption: nil,
+	atom.A:   {"href", "title"},
+	atom.Img: {"src", "alt", "title", "width", "height"},
+}
+
+// dropped elements are removed together with their content.
+var dropped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Frame: true, atom.Frameset: true, atom.Object: true, atom.Embed: true,
+	atom.Applet: true, atom.Svg: true, atom.Math: true, atom.Canvas: true,
+	atom.Form: true, atom.Button: true, atom.Input: true, atom.Select: true,
+	atom.Textarea: true, atom.Link: true, atom.Meta: true, atom.Base: true,
+	atom.Template: true, atom.Dialog: true, atom.Head: true, atom.Title: true,
+}
+
+// urlAttributes hold links that are resolved and checked.
+var urlAttributes = map[string]bool{
+	"href": true,
+	"src":  true,
+	"cite": true,
+}
+
+// trackers are hosts and paths that only serve tracking images.
+var trackers = []string{
+	"feeds.feedburner.com/~r/",
+	"feeds.feedblitz.com/~/i/",
+	"pixel.wp.com/",
+	"stats.wordpress.com/",
+	"pixel.quantserve.com/",
+	"www.google-analytics.com/",
+	"/~ff/",
+}
+
+// HTML sanitizes an HTML fragment. Relative links are resolved against base
+// when it is not nil.
+func HTML(content string, base *url.URL) string {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+
+	if err != nil {
+		return html.EscapeString(content)
+	}
+
+	return Nodes(nodes, base)
+}
+
+// Nodes sanitizes already parsed nodes and returns them as HTML.
+func Nodes(nodes []*html.Node, base *url.URL) string {
+	var out strings.Builder
+
+	for _, node := range nodes {
+		write(&out, node, base)
+	}
+
+	return strings.TrimSpace(out.String())
+}
+
+func write(w *strings.Builder, node *html.Node, base *url.URL) {
+	switch node.Type {
+	case html.TextNode:
+		w.WriteString(html.EscapeString(node.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if dropped[node.DataAtom] {
+		return
+	}
+
+	attrs, ok := allowed[node.DataAtom]
+
+	if !ok {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			write(w, child, base)
+		}
+
+		return
+	}
+
+	if node.DataAtom == atom.Img && (Resolve(attr(node, "src"), base) == "" || trackingPixel(node)) {
+		return
+	}
+
+	w.WriteString("<" + node.Data)
+
+	for _, name := range attrs {
+		value := strings.TrimSpace(attr(node, name))
+
+		if urlAttributes[name] {
+			value = Resolve(value, base)
+		}
+
+		if value != "" {
+			w.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+		}
+	}
+
+	w.WriteString(">")
+
+	if node.DataAtom == atom.Br || node.DataAtom == atom.Hr || node.DataAtom == atom.Img {
+		return
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		write(w, child, base)
+	}
+
+	w.WriteString("</" + node.Data + ">")
+}
+
+// Resolve makes link absolute against base and returns it, or an empty
+// string when it is not an http, https or mailto link.
+func Resolve(link string, base *url.URL) string {
+	link = strings.TrimSpace(link)
+
+	if link == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(link)
+
+	if err != nil {
+		return ""
+	}
+
+	if base != nil {
+		parsed = base.ResolveReference(parsed)
+	}
+
+	switch parsed.Scheme {
+	case "http", "https", "mailto":
+		return parsed.String()
+	}
+
+	return ""
+}
+
+// trackingPixel reports whether an image is at most one pixel wide and high,
+// or served by a known tracker.
+func trackingPixel(node *html.Node) bool {
+	width, widthErr := strconv.Atoi(strings.TrimSuffix(attr(node, "width"), "px"))
+	height, heightErr := strconv.Atoi(strings.TrimSuffix(attr(node, "height"), "px"))
+
+	if widthErr == nil && heightErr == nil && width <= 1 && height <= 1 {
+		return true
+	}
+
+	style := strings.ReplaceAll(strings.ToLower(attr(node, "style")), " ", "")
+
+	if strings.Contains(style, "width:1px") && strings.Contains(style, "height:1px") {
+		return true
+	}
+
+	src := attr(node, "src")
+
+	for _, tracker := range trackers {
+		if strings.Contains(src, tracker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func attr(node *html.Node, name string) string {
+	for _, a := range node.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,186 @@
+package sanitize
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	base, err := url.Parse("https://example.com/blog/post/")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "script",
+			content: `<p>before</p><script>alert(1)</script><p>after</p>`,
+			want:    `<p>before</p><p>after</p>`,
+		},
+		{
+			name:    "iframe",
+			content: `<p>video</p><iframe src="https://evil.example/"><p>fallback</p></iframe>`,
+			want:    `<p>video</p>`,
+		},
+		{
+			name:    "style and form",
+			content: `<style>p{}</style><form action="/x"><input name="q"></form><p>kept</p>`,
+			want:    `<p>kept</p>`,
+		},
+		{
+			name:    "event handlers",
+			content: `<p onclick="alert(1)">text</p><img src="/a.png" onerror="alert(1)" alt="a">`,
+			want:    `<p>text</p><img src="https://example.com/a.png" alt="a">`,
+		},
+		{
+			name:    "style and class attributes",
+			content: `<span style="color:red" class="x">text</span>`,
+			want:    `<span>text</span>`,
+		},
+		{
+			name:    "javascript href",
+			content: `<a href="javascript:alert(1)">link</a>`,
+			want:    `<a>link</a>`,
+		},
+		{
+			name:    "javascript href with whitespace and case",
+			content: `<a href="  JavaScript:alert(1)">link</a>`,
+			want:    `<a>link</a>`,
+		},
+		{
+			name:    "data href",
+			content: `<a href="data:text/html,<script>alert(1)</script>">link</a>`,
+			want:    `<a>link</a>`,
+		},
+		{
+			name:    "data src",
+			content: `<img src="data:image/png;base64,AAAA" alt="x">`,
+			want:    ``,
+		},
+		{
+			name:    "javascript src",
+			content: `<img src="javascript:alert(1)">`,
+			want:    ``,
+		},
+		{
+			name:    "mailto href",
+			content: `<a href="mailto:me@example.com">mail</a>`,
+			want:    `<a href="mailto:me@example.com">mail</a>`,
+		},
+		{
+			name:    "tracking pixel by size",
+			content: `<p>text</p><img src="https://example.com/p.gif" width="1" height="1">`,
+			want:    `<p>text</p>`,
+		},
+		{
+			name:    "tracking pixel by style",
+			content: `<img src="https://example.com/p.gif" style="width: 1px; height: 1px">`,
+			want:    ``,
+		},
+		{
+			name:    "tracking pixel by host",
+			content: `<img src="https://pixel.wp.com/g.gif?x=1">`,
+			want:    ``,
+		},
+		{
+			name:    "image wider than a pixel",
+			content: `<img src="https://example.com/a.png" width="1" height="20">`,
+			want:    `<img src="https://example.com/a.png" width="1" height="20">`,
+		},
+		{
+			name:    "relative href",
+			content: `<a href="../other/">other</a>`,
+			want:    `<a href="https://example.com/blog/other/">other</a>`,
+		},
+		{
+			name:    "root relative src",
+			content: `<img src="/images/a.png" alt="a">`,
+			want:    `<img src="https://example.com/images/a.png" alt="a">`,
+		},
+		{
+			name:    "protocol relative href",
+			content: `<a href="//cdn.example.net/a">a</a>`,
+			want:    `<a href="https://cdn.example.net/a">a</a>`,
+		},
+		{
+			name:    "relative cite",
+			content: `<blockquote cite="quote.html">q</blockquote>`,
+			want:    `<blockquote cite="https://example.com/blog/post/quote.html">q</blockquote>`,
+		},
+		{
+			name:    "unknown elements keep their content",
+			content: `<article><section><p>text</p></section></article>`,
+			want:    `<p>text</p>`,
+		},
+		{
+			name:    "script nested in allowed elements",
+			content: `<div><p>a<span><script>alert(1)</script>b</span></p></div>`,
+			want:    `<div><p>a<span>b</span></p></div>`,
+		},
+		{
+			name:    "unclosed elements",
+			content: `<p><b>bold<i>both`,
+			want:    `<p><b>bold<i>both</i></b></p>`,
+		},
+		{
+			name:    "stray closing tags",
+			content: `text</div></p>more`,
+			want:    `text<p></p>more`,
+		},
+		{
+			name:    "unterminated script",
+			content: `<p>a</p><script>alert(1)`,
+			want:    `<p>a</p>`,
+		},
+		{
+			name:    "text is escaped",
+			content: `a &lt;script&gt; &amp; b`,
+			want:    `a &lt;script&gt; &amp; b`,
+		},
+		{
+			name:    "attribute values are escaped",
+			content: `<a href="https://example.com/?a=1&b=2" title='"quoted"'>a</a>`,
+			want:    `<a href="https://example.com/?a=1&amp;b=2" title="&#34;quoted&#34;">a</a>`,
+		},
+	}
+
+	for _, test := range tests {
+		if got := HTML(test.content, base); got != test.want {
+			t.Errorf("%v: HTML(%q) = %q, want %q", test.name, test.content, got, test.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	base, err := url.Parse("https://example.com/blog/post")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		link string
+		base *url.URL
+		want string
+	}{
+		{link: "https://go.dev/", base: base, want: "https://go.dev/"},
+		{link: "next", base: base, want: "https://example.com/blog/next"},
+		{link: "#top", base: base, want: "https://example.com/blog/post#top"},
+		{link: "next", want: ""},
+		{link: "", base: base, want: ""},
+		{link: "javascript:alert(1)", base: base, want: ""},
+		{link: "file:///etc/passwd", base: base, want: ""},
+		{link: "ftp://example.com/a", base: base, want: ""},
+	}
+
+	for _, test := range tests {
+		if got := Resolve(test.link, test.base); got != test.want {
+			t.Errorf("Resolve(%q, %v) = %q, want %q", test.link, test.base, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package systemd implements the parts of the sd_notify protocol a
+// Type=notify service needs: telling systemd the service is ready or
+// stopping, and keeping its watchdog from restarting the service.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state, one or more newline separated assignments like
+// READY=1, to the socket systemd gives in $NOTIFY_SOCKET. It reports false
+// when the process does not run under systemd.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+
+	if socket == "" {
+		return false, nil
+	}
+
+	// A leading @ names a socket in the abstract namespace.
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+
+	if err != nil {
+		return false, fmt.Errorf("could not connect to notify socket: %v", err)
+	}
+
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+
+	if err != nil {
+		return false, fmt.Errorf("could not notify systemd: %v", err)
+	}
+
+	return true, nil
+}
+
+// Status formats a STATUS= assignment, the line systemctl status shows.
+func Status(status string) string {
+	return "STATUS=" + status
+}
+
+// WatchdogInterval returns the WatchdogSec of the service, or zero when the
+// watchdog is off or meant for another process. Services should send
+// Watchdog at least twice per interval.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	pid := os.Getenv("WATCHDOG_PID")
+
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
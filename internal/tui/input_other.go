@@ -0,0 +1,11 @@
+//go:build !unix
+
+package tui
+
+import "os"
+
+func openInput(in *os.File) (*os.File, error) {
+	return in, nil
+}
+
+func closeInput(in *os.File, input *os.File) {}
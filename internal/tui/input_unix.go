@@ -0,0 +1,35 @@
+//go:build unix
+
+package tui
+
+import (
+	"os"
+	"syscall"
+)
+
+// openInput duplicates in as a non-blocking file, so the key reader can be
+// stopped with a read deadline when Run returns instead of staying blocked on
+// the terminal and swallowing the next key press.
+func openInput(in *os.File) (*os.File, error) {
+	fd, err := syscall.Dup(int(in.Fd()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.SetNonblock(fd, true)
+
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), in.Name()), nil
+}
+
+// closeInput closes the file from openInput and puts in back in blocking
+// mode, which the duplicate shares with it.
+func closeInput(in *os.File, input *os.File) {
+	input.Close()
+	syscall.SetNonblock(int(in.Fd()), false)
+}
@@ -0,0 +1,825 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/htmltext"
+	"golang.org/x/term"
+)
+
+type Feed struct {
+	ID     uuid.UUID
+	Name   string
+	Url    string
+	Folder string
+	Unread int
+}
+
+type Post struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	Read        bool
+	Starred     bool
+}
+
+// Source is where the reader gets its feeds and posts from and records the
+// read and starred state of posts.
+type Source interface {
+	Feeds() ([]Feed, error)
+	Posts(feedID uuid.UUID) ([]Post, error)
+	SetRead(postID uuid.UUID, read bool) error
+	SetStarred(postID uuid.UUID, starred bool) error
+}
+
+type Options struct {
+	// Title is shown in the header line.
+	Title string
+	// Refresh is how often feeds and posts are reloaded from the source.
+	Refresh time.Duration
+	// Open opens a post url, usually in a browser.
+	Open func(url string) error
+}
+
+type pane int
+
+const (
+	feedsPane pane = iota
+	postsPane
+	articlePane
+)
+
+const (
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleReverse = "\x1b[7m"
+)
+
+const helpLine = "j/k move  tab/h/l pane  enter read  r un/read  s star  o open  R refresh  q quit"
+
+// entry is a line of the feeds pane, either a folder heading or a feed.
+type entry struct {
+	folder string
+	feed   *Feed
+}
+
+type reader struct {
+	source  Source
+	options Options
+	out     io.Writer
+
+	width  int
+	height int
+
+	entries []entry
+	posts   []Post
+
+	focus         pane
+	entryIndex    int
+	entryOffset   int
+	postIndex     int
+	postOffset    int
+	articleScroll int
+	status        string
+}
+
+// Run shows the reader full screen until the user quits. in must be a
+// terminal.
+func Run(in *os.File, out *os.File, source Source, options Options) error {
+	fd := int(in.Fd())
+
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("the reader needs an interactive terminal")
+	}
+
+	previous, err := term.MakeRaw(fd)
+
+	if err != nil {
+		return err
+	}
+
+	defer term.Restore(fd, previous)
+
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	r := &reader{
+		source:  source,
+		options: options,
+		out:     out,
+	}
+
+	r.width, r.height, err = term.GetSize(int(out.Fd()))
+
+	if err != nil {
+		return err
+	}
+
+	err = r.reload()
+
+	if err != nil {
+		return err
+	}
+
+	input, err := openInput(in)
+
+	if err != nil {
+		return err
+	}
+
+	keys := make(chan string)
+	stop := make(chan struct{})
+	go readKeys(input, keys, stop)
+
+	defer func() {
+		close(stop)
+
+		if input.SetReadDeadline(time.Now()) == nil {
+			for range keys {
+			}
+		}
+
+		closeInput(in, input)
+	}()
+
+	refresh := options.Refresh
+
+	if refresh <= 0 {
+		refresh = 30 * time.Second
+	}
+
+	reloadTicker := time.NewTicker(refresh)
+	defer reloadTicker.Stop()
+
+	resizeTicker := time.NewTicker(250 * time.Millisecond)
+	defer resizeTicker.Stop()
+
+	r.draw()
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || key == "q" || key == "ctrl-c" {
+				return nil
+			}
+
+			r.status = ""
+			r.handleKey(key)
+		case <-reloadTicker.C:
+			r.fail(r.reload())
+		case <-resizeTicker.C:
+			width, height, err := term.GetSize(int(out.Fd()))
+
+			if err != nil || (width == r.width && height == r.height) {
+				continue
+			}
+
+			r.width, r.height = width, height
+		}
+
+		r.draw()
+	}
+}
+
+func (r *reader) fail(err error) {
+	if err != nil {
+		r.status = "error: " + err.Error()
+	}
+}
+
+// reload fetches feeds and posts again, keeping the current selection.
+func (r *reader) reload() error {
+	feeds, err := r.source.Feeds()
+
+	if err != nil {
+		return err
+	}
+
+	var selectedFeed uuid.UUID
+
+	if feed := r.selectedFeed(); feed != nil {
+		selectedFeed = feed.ID
+	}
+
+	r.entries = []entry{}
+	folder := ""
+
+	for i := range feeds {
+		if feeds[i].Folder != "" && feeds[i].Folder != folder {
+			r.entries = append(r.entries, entry{folder: feeds[i].Folder})
+		}
+
+		folder = feeds[i].Folder
+		r.entries = append(r.entries, entry{feed: &feeds[i]})
+	}
+
+	r.entryIndex = -1
+
+	for i, e := range r.entries {
+		if e.feed != nil && (r.entryIndex < 0 || e.feed.ID == selectedFeed) {
+			r.entryIndex = i
+		}
+	}
+
+	return r.loadPosts()
+}
+
+func (r *reader) loadPosts() error {
+	var selectedPost uuid.UUID
+
+	if post := r.selectedPost(); post != nil {
+		selectedPost = post.ID
+	}
+
+	r.posts = nil
+	r.postIndex = 0
+
+	feed := r.selectedFeed()
+
+	if feed == nil {
+		return nil
+	}
+
+	posts, err := r.source.Posts(feed.ID)
+
+	if err != nil {
+		return err
+	}
+
+	r.posts = posts
+
+	for i, post := range posts {
+		if post.ID == selectedPost {
+			r.postIndex = i
+		}
+	}
+
+	return nil
+}
+
+func (r *reader) selectedFeed() *Feed {
+	if r.entryIndex < 0 || r.entryIndex >= len(r.entries) {
+		return nil
+	}
+
+	return r.entries[r.entryIndex].feed
+}
+
+func (r *reader) selectedPost() *Post {
+	if r.postIndex < 0 || r.postIndex >= len(r.posts) {
+		return nil
+	}
+
+	return &r.posts[r.postIndex]
+}
+
+func (r *reader) handleKey(key string) {
+	switch key {
+	case "tab", "l", "right":
+		r.focus = min(r.focus+1, articlePane)
+		return
+	case "shift-tab", "h", "left":
+		r.focus = max(r.focus-1, feedsPane)
+		return
+	case "R":
+		r.fail(r.reload())
+		r.status = "refreshed"
+		return
+	case "o":
+		r.openPost()
+		return
+	case "s":
+		r.toggleStarred()
+		return
+	case "r":
+		r.toggleRead()
+		return
+	}
+
+	step := 0
+
+	switch key {
+	case "j", "down":
+		step = 1
+	case "k", "up":
+		step = -1
+	case "pgdn", " ":
+		step = r.paneHeight() - 1
+	case "pgup":
+		step = -(r.paneHeight() - 1)
+	case "enter":
+		r.enter()
+		return
+	default:
+		return
+	}
+
+	switch r.focus {
+	case feedsPane:
+		r.moveFeed(step)
+	case postsPane:
+		r.postIndex = clamp(r.postIndex+step, 0, len(r.posts)-1)
+		r.articleScroll = 0
+	case articlePane:
+		r.articleScroll = max(0, r.articleScroll+step)
+	}
+}
+
+// moveFeed moves the feed selection, skipping folder headings.
+func (r *reader) moveFeed(step int) {
+	direction := 1
+
+	if step < 0 {
+		direction = -1
+		step = -step
+	}
+
+	index := r.entryIndex
+
+	for i := 0; i < step; i++ {
+		next := index + direction
+
+		for next >= 0 && next < len(r.entries) && r.entries[next].feed == nil {
+			next += direction
+		}
+
+		if next < 0 || next >= len(r.entries) {
+			break
+		}
+
+		index = next
+	}
+
+	if index == r.entryIndex {
+		return
+	}
+
+	r.entryIndex = index
+	r.postIndex = 0
+	r.articleScroll = 0
+	r.fail(r.loadPosts())
+}
+
+func (r *reader) enter() {
+	switch r.focus {
+	case feedsPane:
+		r.focus = postsPane
+	case postsPane, articlePane:
+		post := r.selectedPost()
+
+		if post == nil {
+			return
+		}
+
+		r.focus = articlePane
+		r.articleScroll = 0
+
+		if !post.Read {
+			r.setRead(post, true)
+		}
+	}
+}
+
+func (r *reader) setRead(post *Post, read bool) {
+	err := r.source.SetRead(post.ID, read)
+
+	if err != nil {
+		r.fail(err)
+		return
+	}
+
+	post.Read = read
+
+	feed := r.selectedFeed()
+
+	if feed == nil {
+		return
+	}
+
+	if read {
+		feed.Unread = max(0, feed.Unread-1)
+	} else {
+		feed.Unread++
+	}
+}
+
+func (r *reader) toggleRead() {
+	post := r.selectedPost()
+
+	if post != nil {
+		r.setRead(post, !post.Read)
+	}
+}
+
+func (r *reader) toggleStarred() {
+	post := r.selectedPost()
+
+	if post == nil {
+		return
+	}
+
+	err := r.source.SetStarred(post.ID, !post.Starred)
+
+	if err != nil {
+		r.fail(err)
+		return
+	}
+
+	post.Starred = !post.Starred
+}
+
+func (r *reader) openPost() {
+	post := r.selectedPost()
+
+	if post == nil || r.options.Open == nil {
+		return
+	}
+
+	err := r.options.Open(post.Url)
+
+	if err != nil {
+		r.fail(err)
+		return
+	}
+
+	r.status = "opened " + post.Url
+
+	if !post.Read {
+		r.setRead(post, true)
+	}
+}
+
+func (r *reader) paneHeight() int {
+	return max(1, r.height-2)
+}
+
+func (r *reader) draw() {
+	height := r.paneHeight()
+
+	feedsWidth, postsWidth, articleWidth := r.layout()
+
+	feedLines := r.feedLines(feedsWidth, height)
+	postLines := r.postLines(postsWidth, height)
+	articleLines := r.articleLines(articleWidth, height)
+
+	var screen strings.Builder
+
+	screen.WriteString("\x1b[H")
+
+	header := fmt.Sprintf(" %v", r.options.Title)
+	screen.WriteString(cell(header, r.width, styleReverse))
+	screen.WriteString("\r\n")
+
+	for row := 0; row < height; row++ {
+		columns := []string{}
+
+		if feedsWidth > 0 {
+			columns = append(columns, feedLines[row])
+		}
+
+		if postsWidth > 0 {
+			columns = append(columns, postLines[row])
+		}
+
+		if articleWidth > 0 {
+			columns = append(columns, articleLines[row])
+		}
+
+		screen.WriteString(strings.Join(columns, styleDim+"│"+styleReset))
+		screen.WriteString("\r\n")
+	}
+
+	status := r.status
+
+	if status == "" {
+		status = helpLine
+	}
+
+	screen.WriteString(cell(" "+status, r.width, styleDim))
+	screen.WriteString("\x1b[J")
+
+	io.WriteString(r.out, screen.String())
+}
+
+// layout splits the width between the panes. Narrow terminals only show the
+// focused pane.
+func (r *reader) layout() (int, int, int) {
+	if r.width < 80 {
+		widths := [3]int{}
+		widths[r.focus] = r.width
+
+		return widths[0], widths[1], widths[2]
+	}
+
+	feedsWidth := r.width / 5
+	postsWidth := r.width * 3 / 10
+	articleWidth := r.width - feedsWidth - postsWidth - 2
+
+	return feedsWidth, postsWidth, articleWidth
+}
+
+func (r *reader) feedLines(width, height int) []string {
+	r.entryOffset = scrollOffset(r.entryOffset, r.entryIndex, height)
+
+	lines := make([]string, height)
+
+	for row := range lines {
+		index := r.entryOffset + row
+
+		if index >= len(r.entries) {
+			lines[row] = cell("", width, "")
+			continue
+		}
+
+		e := r.entries[index]
+
+		if e.feed == nil {
+			lines[row] = cell("▾ "+e.folder, width, styleBold)
+			continue
+		}
+
+		indent := " "
+
+		if e.feed.Folder != "" {
+			indent = "   "
+		}
+
+		text := indent + e.feed.Name
+
+		if e.feed.Unread > 0 {
+			text = fmt.Sprintf("%v (%d)", text, e.feed.Unread)
+		}
+
+		lines[row] = cell(text, width, r.selectionStyle(feedsPane, index == r.entryIndex))
+	}
+
+	return lines
+}
+
+func (r *reader) postLines(width, height int) []string {
+	r.postOffset = scrollOffset(r.postOffset, r.postIndex, height)
+
+	lines := make([]string, height)
+
+	for row := range lines {
+		index := r.postOffset + row
+
+		if index >= len(r.posts) {
+			lines[row] = cell("", width, "")
+			continue
+		}
+
+		post := r.posts[index]
+
+		marker := "  "
+
+		if !post.Read {
+			marker = "● "
+		}
+
+		if post.Starred {
+			marker = "★ "
+		}
+
+		date := ""
+
+		if !post.PublishedAt.IsZero() {
+			date = post.PublishedAt.Local().Format("Jan 02") + " "
+		}
+
+		style := r.selectionStyle(postsPane, index == r.postIndex)
+
+		if !post.Read && style == "" {
+			style = styleBold
+		}
+
+		lines[row] = cell(marker+date+post.Title, width, style)
+	}
+
+	return lines
+}
+
+func (r *reader) articleLines(width, height int) []string {
+	lines := make([]string, height)
+	content := []string{}
+
+	post := r.selectedPost()
+
+	if post != nil && width > 2 {
+		textWidth := width - 2
+
+		for _, line := range wrap(post.Title, textWidth) {
+			content = append(content, styleBold+line)
+		}
+
+		if !post.PublishedAt.IsZero() {
+			content = append(content, styleDim+post.PublishedAt.Local().Format("Mon, 02 Jan 2006 15:04"))
+		}
+
+		content = append(content, styleDim+post.Url, "")
+		article := htmltext.Render(post.Description, htmltext.Options{Width: textWidth})
+		content = append(content, strings.Split(article, "\n")...)
+	}
+
+	r.articleScroll = min(r.articleScroll, max(0, len(content)-height))
+
+	for row := range lines {
+		index := r.articleScroll + row
+
+		if index >= len(content) {
+			lines[row] = cell("", width, "")
+			continue
+		}
+
+		style := ""
+		text := content[index]
+
+		for _, prefix := range []string{styleBold, styleDim} {
+			if strings.HasPrefix(text, prefix) {
+				style = prefix
+				text = strings.TrimPrefix(text, prefix)
+			}
+		}
+
+		lines[row] = cell(" "+text, width, style)
+	}
+
+	return lines
+}
+
+func (r *reader) selectionStyle(p pane, selected bool) string {
+	if !selected {
+		return ""
+	}
+
+	if r.focus == p {
+		return styleReverse
+	}
+
+	return styleBold
+}
+
+// cell truncates or pads text to exactly width columns and applies style.
+func cell(text string, width int, style string) string {
+	if width <= 0 {
+		return ""
+	}
+
+	runes := []rune(strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return ' '
+		}
+
+		return r
+	}, text))
+
+	if len(runes) > width {
+		runes = append(runes[:width-1], '…')
+	}
+
+	padded := string(runes) + strings.Repeat(" ", width-len(runes))
+
+	if style == "" {
+		return padded
+	}
+
+	return style + padded + styleReset
+}
+
+func scrollOffset(offset, index, height int) int {
+	if index < offset {
+		return max(0, index)
+	}
+
+	if index >= offset+height {
+		return index - height + 1
+	}
+
+	return offset
+}
+
+func clamp(value, low, high int) int {
+	if high < low {
+		return low
+	}
+
+	return max(low, min(value, high))
+}
+
+// wrap breaks text into lines of at most width runes, keeping paragraph
+// breaks.
+func wrap(text string, width int) []string {
+	lines := []string{}
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+
+		line := ""
+
+		for _, word := range words {
+			if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+
+			if line != "" {
+				line += " "
+			}
+
+			line += word
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// readKeys decodes key presses from in until it fails or stop is closed,
+// then closes keys.
+func readKeys(in io.Reader, keys chan<- string, stop <-chan struct{}) {
+	defer close(keys)
+
+	buffer := make([]byte, 64)
+
+	for {
+		n, err := in.Read(buffer)
+
+		if err != nil {
+			return
+		}
+
+		for _, key := range decodeKeys(buffer[:n]) {
+			select {
+			case keys <- key:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+var escapeSequences = map[string]string{
+	"\x1b[A":  "up",
+	"\x1b[B":  "down",
+	"\x1b[C":  "right",
+	"\x1b[D":  "left",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdn",
+	"\x1b[Z":  "shift-tab",
+	"\x1bOA":  "up",
+	"\x1bOB":  "down",
+	"\x1bOC":  "right",
+	"\x1bOD":  "left",
+}
+
+func decodeKeys(input []byte) []string {
+	keys := []string{}
+
+	for len(input) > 0 {
+		matched := false
+
+		for sequence, key := range escapeSequences {
+			if strings.HasPrefix(string(input), sequence) {
+				keys = append(keys, key)
+				input = input[len(sequence):]
+				matched = true
+				break
+			}
+		}
+
+		if matched {
+			continue
+		}
+
+		switch input[0] {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case '\t':
+			keys = append(keys, "tab")
+		case 3:
+			keys = append(keys, "ctrl-c")
+		case 0x1b:
+			keys = append(keys, "esc")
+		default:
+			keys = append(keys, string(input[0]))
+		}
+
+		input = input[1:]
+	}
+
+	return keys
+}
@@ -0,0 +1,104 @@
+// Package webhook delivers signed JSON payloads to webhook receivers. The
+// body is signed with HMAC-SHA256 under the webhook's secret and the
+// signature sent as X-Gator-Signature: sha256=<hex>, so receivers can check
+// a request came from gator and was not changed on the way.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Gator-Signature"
+	EventHeader     = "X-Gator-Event"
+	DeliveryHeader  = "X-Gator-Delivery"
+
+	signaturePrefix = "sha256="
+	// maxResponseBody is how much of a receiver's response is kept for the
+	// delivery log.
+	maxResponseBody = 512
+)
+
+// Delivery is one request to a receiver.
+type Delivery struct {
+	ID      string
+	Event   string
+	Url     string
+	Secret  string
+	Payload []byte
+}
+
+// Sign returns the signature header value of body under secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body under secret.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Send posts a delivery and returns the receiver's status code. Any status
+// other than 2xx is an error, described with the start of the response body.
+func Send(ctx context.Context, client *http.Client, delivery Delivery, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "POST", delivery.Url, bytes.NewReader(delivery.Payload))
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook request: %v", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "gator-webhook")
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, delivery.ID)
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, delivery.Payload))
+
+	resp, err := client.Do(request)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := strings.TrimSpace(string(body))
+
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+
+		return resp.StatusCode, fmt.Errorf("receiver answered %d: %v", resp.StatusCode, message)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Backoff is how long to wait before retrying a delivery that failed for the
+// attempt-th time: a minute, doubling up to six hours.
+func Backoff(attempt int) time.Duration {
+	wait := time.Minute
+
+	for i := 1; i < attempt && wait < 6*time.Hour; i++ {
+		wait *= 2
+	}
+
+	return min(wait, 6*time.Hour)
+}
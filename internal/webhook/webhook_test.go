@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendSignsPayload(t *testing.T) {
+	delivery := Delivery{
+		ID:      "1a2b",
+		Event:   "post.created",
+		Secret:  "s3cret",
+		Payload: []byte(`{"event":"post.created"}`),
+	}
+
+	var received *http.Request
+	var body []byte
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+
+	delivery.Url = receiver.URL
+
+	status, err := Send(context.Background(), receiver.Client(), delivery, time.Second)
+
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if status != http.StatusOK {
+		t.Errorf("status = %d, want 200", status)
+	}
+
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+
+	signature := received.Header.Get(SignatureHeader)
+
+	if !Verify(delivery.Secret, body, signature) {
+		t.Errorf("signature %v does not verify", signature)
+	}
+
+	if Verify("other", body, signature) {
+		t.Errorf("signature %v verifies under another secret", signature)
+	}
+
+	if Verify(delivery.Secret, []byte(`{"event":"ping"}`), signature) {
+		t.Errorf("signature %v verifies another body", signature)
+	}
+
+	if got := received.Header.Get(EventHeader); got != delivery.Event {
+		t.Errorf("%v = %v, want %v", EventHeader, got, delivery.Event)
+	}
+
+	if got := received.Header.Get(DeliveryHeader); got != delivery.ID {
+		t.Errorf("%v = %v, want %v", DeliveryHeader, got, delivery.ID)
+	}
+
+	if got := received.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %v, want application/json", got)
+	}
+}
+
+func TestSignKnownValue(t *testing.T) {
+	// echo -n 'hello' | openssl dgst -sha256 -hmac key
+	want := "sha256=9307b3b915efb5171ff14d8cb55fbcc798c6c0ef1456d66ded1a6aa723a58b7b"
+
+	if got := Sign("key", []byte("hello")); got != want {
+		t.Errorf("Sign = %v, want %v", got, want)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/broken":
+			http.Error(w, "database is down", http.StatusInternalServerError)
+		case "/empty":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer receiver.Close()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantError  string
+	}{
+		{path: "/broken", wantStatus: 500, wantError: "receiver answered 500: database is down"},
+		{path: "/empty", wantStatus: 502, wantError: "receiver answered 502: Bad Gateway"},
+		{path: "/slow", wantStatus: 0, wantError: "deadline exceeded"},
+	}
+
+	for _, test := range tests {
+		status, err := Send(context.Background(), receiver.Client(), Delivery{
+			Url:     receiver.URL + test.path,
+			Payload: []byte("{}"),
+		}, 50*time.Millisecond)
+
+		if status != test.wantStatus {
+			t.Errorf("%v: status = %d, want %d", test.path, status, test.wantStatus)
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.wantError) {
+			t.Errorf("%v: error = %v, want %q", test.path, err, test.wantError)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{8, 128 * time.Minute},
+		{10, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+
+	for _, test := range tests {
+		if got := Backoff(test.attempt); got != test.want {
+			t.Errorf("Backoff(%d) = %v, want %v", test.attempt, got, test.want)
+		}
+	}
+}
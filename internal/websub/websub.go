@@ -0,0 +1,156 @@
+// Package websub implements the subscriber side of WebSub (formerly
+// PubSubHubbub): discovering a feed's hub, asking the hub to push updates to
+// a callback url and checking the signatures of the pushed content.
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the HMAC of pushed content under the secret given
+// when subscribing.
+const SignatureHeader = "X-Hub-Signature"
+
+// Subscription is a request to a hub.
+type Subscription struct {
+	Hub      string
+	Topic    string
+	Callback string
+	Secret   string
+	Lease    time.Duration
+}
+
+// Links returns the hub and self urls of the Link headers of a response,
+// which take precedence over the links in the feed itself.
+func Links(header http.Header) (hub, self string) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(link, ";")
+
+			if !ok {
+				continue
+			}
+
+			target = strings.TrimSpace(target)
+
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			target = target[1 : len(target)-1]
+
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					switch {
+					case strings.EqualFold(rel, "hub") && hub == "":
+						hub = target
+					case strings.EqualFold(rel, "self") && self == "":
+						self = target
+					}
+				}
+			}
+		}
+	}
+
+	return hub, self
+}
+
+// Verify reports whether signature, the value of an X-Hub-Signature header
+// like sha256=<hex>, is the HMAC of body under secret. sha1, sha256, sha384
+// and sha512 are accepted.
+func Verify(secret string, body []byte, signature string) bool {
+	method, sum, ok := strings.Cut(signature, "=")
+
+	if !ok {
+		return false
+	}
+
+	var newHash func() hash.Hash
+
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(sum)
+
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Subscribe asks a hub to start pushing updates of a topic to the callback.
+// The hub answers 202 Accepted and verifies the request later by calling
+// the callback with a challenge.
+func Subscribe(ctx context.Context, client *http.Client, subscription Subscription) error {
+	form := url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {subscription.Topic},
+		"hub.callback": {subscription.Callback},
+	}
+
+	if subscription.Secret != "" {
+		form.Set("hub.secret", subscription.Secret)
+	}
+
+	if subscription.Lease > 0 {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(subscription.Lease.Seconds())))
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", subscription.Hub, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return fmt.Errorf("invalid hub url: %v", err)
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("User-Agent", "gator")
+
+	resp, err := client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return fmt.Errorf("hub answered %d: %v", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -5,29 +5,57 @@ import (
 	"database/sql"
 	"encoding/xml"
 	"errors"
+	"flag"
 	"fmt"
 	"html"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/mambo-dev/gator/internal"
 	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/htmltext"
+	"github.com/mambo-dev/gator/internal/output"
+	"github.com/mambo-dev/gator/internal/rules"
+	"github.com/mambo-dev/gator/internal/sanitize"
+	"github.com/mambo-dev/gator/internal/websub"
+	"golang.org/x/term"
 )
 
 type state struct {
 	config *internal.Config
 	db     *database.Queries
+	sqlDB  *sql.DB
 }
 
-type command struct {
-	name      string
-	arguments []string
+// inTx runs fn with queries in one transaction, committed when fn succeeds
+// and rolled back otherwise.
+func (s *state) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := s.sqlDB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("could not begin transaction %v", err)
+	}
+
+	defer tx.Rollback()
+
+	err = fn(database.New(instrumentedDB{db: tx}))
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func main() {
@@ -42,43 +70,147 @@ func main() {
 		log.Fatal(err.Error())
 	}
 
-	dbQueries := database.New(db)
+	dbQueries := database.New(instrumentedDB{db: db})
 
 	newState := state{
 		config: config,
 		db:     dbQueries,
+		sqlDB:  db,
 	}
 
-	commands := commands{
-		commands: make(map[string]func(*state, command) error),
-	}
+	commands := newCommands()
 
-	commands.register("login", handlerLogin)
-	commands.register("register", handlerRegister)
-	commands.register("reset", handlerReset)
-	commands.register("users", handlerUsers)
-	commands.register("agg", middlewareLoggedIn(handlerAgg))
-	commands.register("addfeed", middlewareLoggedIn(handlerFeed))
-	commands.register("feeds", handlerFeeds)
-	commands.register("follow", middlewareLoggedIn(handlerFollow))
-	commands.register("following", middlewareLoggedIn(handlerFollowing))
-	commands.register("unfollow", middlewareLoggedIn(handlerUnfollow))
-	commands.register("browse", handlerBrowse)
+	commands.register(&commandSpec{
+		name:        "login",
+		description: "Log in with a username.",
+		args:        []string{"<username>"},
+		handler:     handlerLogin,
+		complete:    completeUsernames,
+	})
+	commands.register(&commandSpec{
+		name:        "register",
+		description: "Create a new user account with a username and log in as it.",
+		args:        []string{"<username>"},
+		handler:     handlerRegister,
+	})
+	commands.register(&commandSpec{
+		name:        "reset",
+		description: "Reset the user database.",
+		handler:     handlerReset,
+	})
+	commands.register(&commandSpec{
+		name:        "users",
+		description: "List all users and show the currently logged-in user.",
+		handler:     handlerUsers,
+	})
+	commands.register(&commandSpec{
+		name:        "agg",
+		description: "Fetch the least recently fetched feed every <time-between-requests>, e.g. 1m.",
+		args:        []string{"<time-between-requests>"},
+		flags: func(flags *flag.FlagSet) {
+			flags.String("metrics-addr", "", "`address` to serve Prometheus metrics at /metrics and health checks at /healthz and /readyz on, like :9090")
+		},
+		handler: middlewareLoggedIn(handlerAgg),
+	})
+	commands.register(&commandSpec{
+		name:        "addfeed",
+		description: "Add a new feed and follow it.",
+		args:        []string{"<name>", "<url>"},
+		flags: func(flags *flag.FlagSet) {
+			flags.Bool("full-content", false, "fetch the full article of new posts, for feeds that only publish teasers")
+		},
+		handler: middlewareLoggedIn(handlerFeed),
+	})
+	commands.register(&commandSpec{
+		name:        "feeds",
+		description: "Display all available feeds.",
+		handler:     handlerFeeds,
+	})
+	commands.register(&commandSpec{
+		name:        "follow",
+		description: "Follow a feed that is already in the database.",
+		args:        []string{"<feed-url>"},
+		handler:     middlewareLoggedIn(handlerFollow),
+		complete:    completeFeedUrls,
+	})
+	commands.register(&commandSpec{
+		name:        "following",
+		description: "List the feeds you follow.",
+		handler:     middlewareLoggedIn(handlerFollowing),
+	})
+	commands.register(&commandSpec{
+		name:        "unfollow",
+		description: "Unfollow a feed.",
+		args:        []string{"<feed-url>"},
+		handler:     middlewareLoggedIn(handlerUnfollow),
+		complete:    completeFollowedUrls,
+	})
+	commands.register(&commandSpec{
+		name:        "browse",
+		description: "Browse the newest posts of followed feeds, 2 unless a limit is given. A story carried by several feeds is shown once and posts hidden by your rules are left out.",
+		args:        []string{"[limit]"},
+		flags: func(flags *flag.FlagSet) {
+			renderFlags(flags)
+			flags.Bool("hidden", false, "include the posts hidden by rules")
+		},
+		handler: middlewareLoggedIn(handlerBrowse),
+	})
+	commands.register(openCommand)
+	commands.register(showCommand)
+	commands.register(fulltextCommand)
+	commands.register(refetchCommand)
+	commands.register(tuiCommand)
+	commands.register(ruleCommand)
+	commands.register(serveCommand)
+	commands.register(tokenCommand)
+	commands.register(passwdCommand)
+	commands.register(publishCommand)
+	commands.register(webhookCommand)
+	commands.register(digestCommand)
+	commands.register(retentionCommand)
+	commands.register(pruneCommand)
+	commands.register(canonicalizeCommand)
+	commands.register(importCommand)
+	commands.register(exportCommand)
+	commands.register(completionCommand)
+	commands.register(shellCommand(commands))
+	commands.register(&commandSpec{
+		name:        "help",
+		description: "Show help for gator or one of its commands.",
+		args:        []string{"[command]..."},
+		handler:     handlerHelp(commands),
+		complete: func(ctx context.Context, s *state, position int) []string {
+			return commandNames(commands.visible())
+		},
+	})
+	commands.register(&commandSpec{
+		name:    "__complete",
+		args:    []string{"[word]..."},
+		handler: handlerComplete(commands),
+		hidden:  true,
+		rawArgs: true,
+	})
 
-	args := os.Args
+	args := os.Args[1:]
 
-	if len(args) < 2 {
-		log.Fatal("expecting more arguments")
+	if len(args) < 1 {
+		commands.printHelp(os.Stderr)
+		os.Exit(1)
 	}
 
-	commandName := args[1]
-	commandArguments := args[2:]
-	command := command{
-		name:      commandName,
-		arguments: commandArguments,
+	if args[0] == "-h" || args[0] == "--help" {
+		args[0] = "help"
 	}
 
-	err = commands.run(&newState, command)
+	// Interrupts and the SIGTERM of a service manager cancel the command
+	// instead of killing gator, so agg and serve can stop cleanly. A second
+	// signal kills gator as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	context.AfterFunc(ctx, stop)
+
+	err = commands.run(ctx, &newState, args, os.Stdout)
 
 	if err != nil {
 		log.Fatal(err.Error())
@@ -86,33 +218,49 @@ func main() {
 
 }
 
-func handlerLogin(s *state, cmd command) error {
-	if len(cmd.arguments) < 1 {
-		return errors.New("login handler expects a single argument, the username.")
+// messageResult is the result of commands that only report what they did.
+type messageResult struct {
+	Message string `json:"message"`
+}
+
+func (r messageResult) Text() string {
+	return r.Message
+}
+
+type userResult struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+	Created bool   `json:"created,omitempty"`
+}
+
+func (r userResult) Text() string {
+	if r.Created {
+		return fmt.Sprintf("User was created\nusername: %v successfully set", r.Name)
 	}
 
+	return fmt.Sprintf("username: %v successfully set", r.Name)
+}
+
+func handlerLogin(s *state, cmd command) (any, error) {
 	config := s.config
 
 	dbQuery := s.db
 
-	user, err := dbQuery.GetUser(context.Background(), cmd.arguments[0])
+	user, err := dbQuery.GetUser(cmd.ctx, cmd.arguments[0])
 
 	if err != nil {
-		log.Fatalf("User does not exist\n - error:%v\n", err.Error())
+		return nil, fmt.Errorf("user does not exist %v", err)
 	}
 
 	config.SetUser(user.Name)
 
-	fmt.Printf("username: %v successfully set\n", config.CurrentUserName)
-
-	return nil
+	return userResult{
+		Name:    config.CurrentUserName,
+		Current: true,
+	}, nil
 }
 
-func handlerRegister(s *state, cmd command) error {
-	if len(cmd.arguments) < 1 {
-		return errors.New("register handler expects a single argument, the username.")
-	}
-
+func handlerRegister(s *state, cmd command) (any, error) {
 	config := s.config
 	dbQuery := s.db
 
@@ -123,88 +271,223 @@ func handlerRegister(s *state, cmd command) error {
 		UpdatedAt: time.Now(),
 	}
 
-	user, err := dbQuery.CreateUser(context.Background(), newUser)
+	user, err := dbQuery.CreateUser(cmd.ctx, newUser)
 
 	if err != nil {
-		log.Fatal("User already exists")
+		return nil, errors.New("user already exists")
 	}
 
 	config.SetUser(user.Name)
-	fmt.Println("User was created")
-	fmt.Printf("username: %v successfully set\n", config.CurrentUserName)
 
-	return nil
+	return userResult{
+		Name:    config.CurrentUserName,
+		Current: true,
+		Created: true,
+	}, nil
 }
 
-func handlerReset(s *state, cmd command) error {
+func handlerReset(s *state, cmd command) (any, error) {
 	dbQuery := s.db
 
-	err := dbQuery.DeleteUsers(context.Background())
+	err := dbQuery.DeleteUsers(cmd.ctx)
 
 	if err != nil {
-		log.Fatalf("Failed to delete:\n - error: %v", err.Error())
+		return nil, fmt.Errorf("failed to delete users %v", err)
 	}
 
-	log.Println("User table succesfully reset !")
-	return nil
+	return messageResult{Message: "User table succesfully reset !"}, nil
+}
+
+type usersResult []userResult
+
+func (r usersResult) Text() string {
+	var text strings.Builder
+
+	for _, user := range r {
+
+		if user.Current {
+			fmt.Fprintf(&text, "* %v (current) \n", user.Name)
+
+		} else {
+			fmt.Fprintf(&text, "* %v \n", user.Name)
+		}
+
+	}
+
+	return text.String()
 }
 
-func handlerUsers(s *state, cmd command) error {
+func handlerUsers(s *state, cmd command) (any, error) {
 	dbQuery := s.db
 
-	users, err := dbQuery.GetUsers(context.Background())
+	users, err := dbQuery.GetUsers(cmd.ctx)
 
 	if err != nil {
-		log.Fatalf("Failed to get users:\n - error: %v", err.Error())
+		return nil, fmt.Errorf("failed to get users %v", err)
 	}
 
 	currentlyLoggedInUser := s.config.CurrentUserName
 
-	for _, user := range users {
-
-		if user.Name == currentlyLoggedInUser {
-			fmt.Printf("* %v (current) \n", user.Name)
-
-		} else {
-			fmt.Printf("* %v \n", user.Name)
-		}
+	result := make(usersResult, 0, len(users))
 
+	for _, user := range users {
+		result = append(result, userResult{
+			Name:    user.Name,
+			Current: user.Name == currentlyLoggedInUser,
+		})
 	}
 
-	return nil
+	return result, nil
 
 }
 
-func handlerAgg(s *state, cmd command, user database.User) error {
+// handlerAgg runs until its context is cancelled, so it reports its progress
+// on stderr instead of through a result.
+func handlerAgg(s *state, cmd command, user database.User) (any, error) {
+	timeBetweenRequests, err := time.ParseDuration(cmd.arguments[0])
 
-	if len(cmd.arguments) < 1 {
-		return errors.New("expecting one arguments")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse time between requests %v", err)
 	}
 
-	timeBetweenRequests, err := time.ParseDuration(cmd.arguments[0])
+	d := newDaemon(timeBetweenRequests + stallTimeout)
 
-	if err != nil {
-		log.Fatal("could not parse command")
+	// Health checks and the watchdog outlive cmd.ctx until aggregate has
+	// drained, so /readyz reports the shutdown.
+	statusCtx, stopStatus := context.WithCancel(context.WithoutCancel(cmd.ctx))
+	defer stopStatus()
+
+	if addr := cmd.stringFlag("metrics-addr"); addr != "" {
+		go serveStatus(statusCtx, addr, d)
 	}
 
+	go d.watchdog(statusCtx)
+
+	aggregate(cmd.ctx, s, timeBetweenRequests, d)
+
+	return nil, nil
+}
+
+// aggregateSteps run in order every round of agg.
+var aggregateSteps = []struct {
+	name string
+	run  func(ctx context.Context, s *state) error
+}{
+	{name: "scrape feeds", run: scrapeFeeds},
+	{name: "deliver webhooks", run: deliverWebhooks},
+	{name: "send digests", run: sendDueDigests},
+}
+
+// aggregate scrapes the next feed every timeBetweenRequests until ctx is
+// cancelled, pruning old posts along the way when auto_prune is set. Once
+// ctx is cancelled the step in flight gets drainTimeout to finish, so a
+// feed is not left half stored. d, when given, is told about progress.
+func aggregate(ctx context.Context, s *state, timeBetweenRequests time.Duration, d *daemon) {
 	ticker := time.NewTicker(timeBetweenRequests)
-	fmt.Printf("Collecting feeds every: %v \n", timeBetweenRequests)
-	for ; ; <-ticker.C {
-		scrapeFeeds(s)
-		fmt.Println("Collecting feeds...")
+	defer ticker.Stop()
+
+	work, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	stopDrain := context.AfterFunc(ctx, func() {
+		log.Printf("Stopping, waiting up to %v for work in flight\n", drainTimeout)
+
+		if d != nil {
+			d.stopping()
+		}
+
+		time.AfterFunc(drainTimeout, cancelWork)
+	})
+	defer stopDrain()
+
+	log.Printf("Collecting feeds every: %v \n", timeBetweenRequests)
+
+	var lastPrune time.Time
+
+	for {
+		if d != nil {
+			d.heartbeat(fmt.Sprintf("Collecting feeds every %v", timeBetweenRequests))
+		}
+
+		for _, step := range aggregateSteps {
+			if ctx.Err() != nil {
+				break
+			}
+
+			err := step.run(work, s)
+
+			if err != nil {
+				log.Printf("Failed to %v %v\n", step.name, err.Error())
+			}
+		}
+
+		log.Println("Collecting feeds...")
+
+		if s.config.AutoPrune && ctx.Err() == nil && time.Since(lastPrune) >= pruneInterval {
+			pruned, err := prunePosts(work, s, false)
+
+			if err != nil {
+				log.Printf("Failed to prune posts %v\n", err.Error())
+			} else {
+				lastPrune = time.Now()
+				log.Printf("Pruned %d posts\n", len(pruned))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Stopped collecting feeds")
+			return
+		case <-ticker.C:
+		}
 	}
+}
+
+type feedResult struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	CreatedBy string    `json:"created_by"`
+}
 
+func (r feedResult) Text() string {
+	return fmt.Sprintf("- name: %v\n - url: %v\n  - created by: %v\n", r.Name, r.Url, r.CreatedBy)
 }
 
-func handlerFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.arguments) < 2 {
-		return errors.New("expecting two arguments")
+func handlerFeed(s *state, cmd command, user database.User) (any, error) {
+	createdFeed, err := addFeed(cmd.ctx, s, user, cmd.arguments[0], cmd.arguments[1], cmd.boolFlag("full-content"))
+
+	if err != nil {
+		return nil, err
 	}
 
+	return feedResult{
+		ID:        createdFeed.ID,
+		Name:      createdFeed.Name,
+		Url:       createdFeed.Url,
+		CreatedBy: user.Name,
+	}, nil
+}
+
+var (
+	errFeedExists       = errors.New("a feed with this url already exists")
+	errFeedNotFound     = errors.New("could not get specified feed.")
+	errAlreadyFollowing = errors.New("already following this feed")
+)
+
+// isUniqueViolation reports whether err comes from inserting a duplicate
+// into a unique column.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// addFeed creates a feed owned by user and follows it.
+func addFeed(ctx context.Context, s *state, user database.User, name, rawUrl string, fullContent bool) (database.Feed, error) {
 	dbQuery := s.db
 
-	name := cmd.arguments[0]
-	url := cmd.arguments[1]
+	url := canonicalUrl(ctx, s, rawUrl)
 
 	newFeed := database.CreateFeedParams{
 		ID:        uuid.New(),
@@ -218,13 +501,30 @@ func handlerFeed(s *state, cmd command, user database.User) error {
 		},
 	}
 
-	createdFeed, err := dbQuery.CreateFeed(context.Background(), newFeed)
+	createdFeed, err := dbQuery.CreateFeed(ctx, newFeed)
+
+	if isUniqueViolation(err) {
+		return createdFeed, errFeedExists
+	}
 
 	if err != nil {
-		return errors.New("could not create feed")
+		return createdFeed, errors.New("could not create feed")
+	}
+
+	if fullContent {
+		err = dbQuery.SetFeedFetchFullContent(ctx, database.SetFeedFetchFullContentParams{
+			ID:               createdFeed.ID,
+			FetchFullContent: true,
+		})
+
+		if err != nil {
+			return createdFeed, fmt.Errorf("could not enable full content %v", err)
+		}
+
+		createdFeed.FetchFullContent = true
 	}
 
-	_, err = dbQuery.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+	_, err = dbQuery.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
 		ID: uuid.New(),
 		FeedID: uuid.NullUUID{
 			UUID:  createdFeed.ID,
@@ -236,67 +536,139 @@ func handlerFeed(s *state, cmd command, user database.User) error {
 	})
 
 	if err != nil {
-		return errors.New("failed to create user's feed follow")
+		return createdFeed, errors.New("failed to create user's feed follow")
 	}
 
-	fmt.Println(createdFeed)
-	return nil
+	return createdFeed, nil
 }
 
-func handlerFeeds(s *state, cmd command) error {
+type feedsResult []feedResult
+
+func (r feedsResult) Text() string {
+	var text strings.Builder
+
+	text.WriteString("Your feeds are:\n")
+
+	for _, feed := range r {
+		text.WriteString(feed.Text())
+	}
+
+	return text.String()
+}
+
+func handlerFeeds(s *state, cmd command) (any, error) {
 	dbQuery := s.db
 
-	feeds, err := dbQuery.GetFeeds(context.Background())
+	feeds, err := dbQuery.GetFeeds(cmd.ctx)
 
 	if err != nil {
-		return errors.New("could not get feeds from db")
+		return nil, errors.New("could not get feeds from db")
 	}
 
-	fmt.Println("Your feeds are:")
+	result := make(feedsResult, 0, len(feeds))
 
 	for _, feed := range feeds {
-		fmt.Printf("- name: %v\n - url: %v\n  - created by: %v\n", feed.FeedName, feed.Url, feed.UserName)
+		result = append(result, feedResult{
+			ID:        feed.ID,
+			Name:      feed.FeedName,
+			Url:       feed.Url,
+			CreatedBy: feed.UserName,
+		})
 	}
 
-	return nil
+	return result, nil
+}
+
+type followedFeed struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Folder string `json:"folder,omitempty"`
+}
+
+type followingResult struct {
+	User  string         `json:"user"`
+	Feeds []followedFeed `json:"feeds"`
+}
+
+func (r followingResult) Text() string {
+	var text strings.Builder
+
+	fmt.Fprintf(&text, "%v's feeds: \n", r.User)
+
+	for _, feed := range r.Feeds {
+		fmt.Fprintf(&text, "Name: %v \n", feed.Name)
+	}
+
+	return text.String()
+}
+
+func (r followingResult) Records() any {
+	return r.Feeds
 }
 
-func handlerFollowing(s *state, cmd command, user database.User) error {
+func handlerFollowing(s *state, cmd command, user database.User) (any, error) {
 	dbQuery := s.db
 
-	feedFollows, err := dbQuery.GetFeedFollowsForUser(context.Background(), uuid.NullUUID{
+	feedFollows, err := dbQuery.GetFollowedFeedsForUser(cmd.ctx, uuid.NullUUID{
 		UUID:  user.ID,
 		Valid: true,
 	})
 
 	if err != nil {
-		return errors.New("could not get feed follows")
+		return nil, errors.New("could not get feed follows")
 	}
 
-	fmt.Printf("%v's feeds: \n", user.Name)
+	result := followingResult{
+		User:  user.Name,
+		Feeds: make([]followedFeed, 0, len(feedFollows)),
+	}
 
 	for _, feed := range feedFollows {
-		fmt.Printf("Name: %v \n", feed.FeedName)
+		result.Feeds = append(result.Feeds, followedFeed{
+			Name:   feed.Name,
+			Url:    feed.Url,
+			Folder: feed.Folder.String,
+		})
 	}
 
-	return nil
+	return result, nil
 }
 
-func handlerFollow(s *state, cmd command, user database.User) error {
+type followResult struct {
+	User string `json:"user"`
+	Feed string `json:"feed"`
+	Url  string `json:"url"`
+}
 
-	if len(cmd.arguments) < 1 {
-		return errors.New("expecting an argument.")
+func (r followResult) Text() string {
+	return fmt.Sprintf("%v feed follows has feed %v", r.User, r.Feed)
+}
+
+func handlerFollow(s *state, cmd command, user database.User) (any, error) {
+	feed, follow, err := followFeed(cmd.ctx, s, user, cmd.arguments[0])
+
+	if err != nil {
+		return nil, err
 	}
 
+	return followResult{
+		User: follow.UserName,
+		Feed: follow.FeedName,
+		Url:  feed.Url,
+	}, nil
+}
+
+// followFeed makes user follow the feed with url.
+func followFeed(ctx context.Context, s *state, user database.User, url string) (database.GetFeedRow, database.CreateFeedFollowRow, error) {
 	dbQuery := s.db
 
-	feed, err := dbQuery.GetFeed(context.Background(), cmd.arguments[0])
+	feed, err := dbQuery.GetFeed(ctx, canonicalUrl(ctx, s, url))
 
 	if err != nil {
-		return errors.New("could not get specified feed.")
+		return feed, database.CreateFeedFollowRow{}, errFeedNotFound
 	}
 
-	feeds, err := dbQuery.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+	follow, err := dbQuery.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
 		ID: uuid.New(),
 		UserID: uuid.NullUUID{
 			UUID:  user.ID,
@@ -310,222 +682,583 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 		UpdatedAt: time.Now(),
 	})
 
+	if isUniqueViolation(err) {
+		return feed, follow, errAlreadyFollowing
+	}
+
 	if err != nil {
-		return errors.New("could not get feeds from db")
+		return feed, follow, errors.New("could not get feeds from db")
 	}
 
-	fmt.Printf("%v feed follows has feed %v\n", feeds.UserName, feeds.FeedName)
-	return nil
+	return feed, follow, nil
 }
 
-func handlerUnfollow(s *state, cmd command, user database.User) error {
+type unfollowResult struct {
+	User string `json:"user"`
+	Url  string `json:"url"`
+}
 
-	if len(cmd.arguments) < 1 {
-		return errors.New("expecting an argument.")
-	}
+func (r unfollowResult) Text() string {
+	return fmt.Sprintf("%v unfollowed %v", r.User, r.Url)
+}
 
+func handlerUnfollow(s *state, cmd command, user database.User) (any, error) {
 	dbQuery := s.db
 
-	err := dbQuery.DeleteFeedFollowForUser(context.Background(), database.DeleteFeedFollowForUserParams{
+	err := dbQuery.DeleteFeedFollowForUser(cmd.ctx, database.DeleteFeedFollowForUserParams{
 		UserID: uuid.NullUUID{
 			UUID:  user.ID,
 			Valid: true,
 		},
-		Url: cmd.arguments[0],
+		Url: canonicalUrl(cmd.ctx, s, cmd.arguments[0]),
 	})
 
 	if err != nil {
-		return fmt.Errorf("could not delete feed %v\n", err)
+		return nil, fmt.Errorf("could not delete feed %v\n", err)
 	}
 
-	return nil
+	return unfollowResult{
+		User: user.Name,
+		Url:  cmd.arguments[0],
+	}, nil
 }
 
-func scrapeFeeds(s *state) bool {
-	nextFeed, err := s.db.GetNextFeedToFetch(context.Background())
+// scrapeFeeds fetches the feed that was fetched longest ago. Feeds a WebSub
+// hub pushes to are only polled once a day while their lease lasts.
+func scrapeFeeds(ctx context.Context, s *state) error {
+	nextFeed, err := s.db.GetNextFeedToFetch(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		metricQueueLag.Set(0)
+		return nil
+	}
 
 	if err != nil {
-		log.Fatalf("could not get next feed to fetch %v\n", err.Error())
+		return fmt.Errorf("could not get next feed to fetch %v", err)
 	}
 
-	err = s.db.MarkFeedFetched(context.Background(), nextFeed.ID)
+	queuedSince := nextFeed.CreatedAt
+
+	if nextFeed.LastFetchedAt.Valid {
+		queuedSince = nextFeed.LastFetchedAt.Time
+	}
+
+	metricQueueLag.Set(time.Since(queuedSince).Seconds())
+
+	err = s.db.MarkFeedFetched(ctx, nextFeed.ID)
 
 	if err != nil {
-		log.Fatalf("Failed to mark feed as fetched %v\n", err.Error())
+		return fmt.Errorf("failed to mark feed as fetched %v", err)
 	}
 
-	feeds, err := fetchFeed(context.Background(), nextFeed.Url)
+	feeds, err := fetchFeed(ctx, nextFeed.Url)
 
 	if err != nil {
-		log.Fatalf("could not fetch feed from url %v\n", err.Error())
+		return fmt.Errorf("could not fetch feed from url %v", err)
 	}
 
-	for _, feed := range feeds.Channel.Item {
+	storeWebSubHub(ctx, s, nextFeed.ID, nextFeed.Url, feeds)
 
-		fmt.Println(feed.Title)
+	ingestFeed(ctx, s, nextFeed, feeds)
 
-		pubDate, err := time.Parse("2006-01-02", feed.PubDate)
+	return nil
+}
+
+// ingestFeed stores the new items of a fetched or pushed feed and runs them
+// through full content extraction, rules and webhooks.
+func ingestFeed(ctx context.Context, s *state, nextFeed database.GetNextFeedToFetchRow, feeds *RSSFeed) {
+	if feeds.Channel.Link != "" {
+		err := s.db.SetFeedSiteUrl(ctx, database.SetFeedSiteUrlParams{
+			ID: nextFeed.ID,
+			SiteUrl: sql.NullString{
+				String: feeds.Channel.Link,
+				Valid:  true,
+			},
+		})
 
 		if err != nil {
-			pubDate = time.Now()
+			log.Printf("Failed to set feed site url %v\n", err.Error())
+		}
+
+		if !nextFeed.HasFavicon {
+			storeFavicon(ctx, s, nextFeed.ID, feeds.Channel.Link)
+		}
+	}
+
+	followerRules, err := loadFeedRules(ctx, s, nextFeed.ID)
+
+	if err != nil {
+		log.Printf("Failed to load rules %v\n", err.Error())
+	}
+
+	followerWebhooks, err := loadFeedWebhooks(ctx, s, nextFeed.ID)
+
+	if err != nil {
+		log.Printf("Failed to load webhooks %v\n", err.Error())
+	}
+
+	for _, feed := range feeds.Channel.Item {
+		if ctx.Err() != nil {
+			log.Printf("Stopped storing %v %v\n", nextFeed.Url, ctx.Err())
+			return
 		}
 
-		_, err = s.db.CreatePost(context.Background(), database.CreatePostParams{
+		log.Println(feed.Title)
+
+		pubDate := feed.published()
+
+		sourceUrl, known := redirectedItemUrl(ctx, s, nextFeed.ID, feed.Link)
+
+		if known {
+			metricPostsDuplicate.Inc()
+			continue
+		}
+
+		description := sanitize.HTML(feed.Description, itemBaseURL(feed.Link, feeds.Channel.Link, nextFeed.Url))
+		author := feed.author()
+		postUrl := canonicalUrl(ctx, s, feed.Link)
+
+		post, err := s.db.CreatePost(ctx, database.CreatePostParams{
 			ID:        uuid.New(),
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 			Title:     feed.Title,
-			Url:       feed.Link,
+			Url:       postUrl,
 			Description: sql.NullString{
-				String: feed.Description,
+				String: description,
+				Valid:  description != "",
 			},
 			PublishedAt: sql.NullTime{
 				Time:  pubDate,
 				Valid: true,
 			},
 			FeedID: nextFeed.ID,
+			Author: sql.NullString{
+				String: author,
+				Valid:  author != "",
+			},
+			Categories: feed.Categories,
+			SourceUrl:  sourceUrl,
 		})
 
+		if isUniqueViolation(err) {
+			// Posts stored before their redirector link was kept learn it
+			// now, so the link is not followed again.
+			if sourceUrl.Valid {
+				err = s.db.SetPostSourceUrl(ctx, database.SetPostSourceUrlParams{
+					FeedID:    nextFeed.ID,
+					Url:       postUrl,
+					SourceUrl: sourceUrl,
+				})
+
+				if err != nil {
+					log.Printf("Failed to set post source url %v\n", err.Error())
+				}
+			}
+
+			metricPostsDuplicate.Inc()
+			continue
+		}
+
 		if err != nil {
-			fmt.Printf("Failed to create post %v\n", err.Error())
+			log.Printf("Failed to create post %v\n", err.Error())
 			continue
 		}
 
+		metricPostsInserted.Inc()
+
+		err = clusterPost(ctx, s, post)
+
+		if err != nil {
+			log.Printf("Failed to cluster post %v\n", err.Error())
+		}
+
+		content := description
+
+		if nextFeed.FetchFullContent && post.Url != "" {
+			fullContent, err := storeFullContent(ctx, s, post.ID, post.Url)
+
+			if err != nil {
+				log.Printf("Failed to fetch full content %v\n", err.Error())
+			} else {
+				content = fullContent
+			}
+		}
+
+		rulePost := rules.Post{
+			Title:      post.Title,
+			Content:    content,
+			Author:     author,
+			Categories: feed.Categories,
+			Feed:       nextFeed.Name,
+		}
+
+		err = applyRules(ctx, s, followerRules, post.ID, rulePost)
+
+		if err != nil {
+			log.Printf("Failed to apply rules %v\n", err.Error())
+		}
+
+		if len(followerWebhooks) > 0 {
+			queuePostWebhooks(ctx, s, followerWebhooks, newWebhookPost(post, nextFeed), rulePost)
+		}
+
 	}
+}
 
-	return true
+type postResult struct {
+	ID          uuid.UUID `json:"id"`
+	ShortID     int64     `json:"short_id"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	AlsoIn      []string  `json:"also_in,omitempty"`
+	Starred     bool      `json:"starred,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
 }
 
-func handlerBrowse(s *state, cmd command) error {
+type postsResult []postResult
+
+func (r postsResult) Text() string {
+	var text strings.Builder
+
+	for _, post := range r {
+		title := post.Title
+
+		if post.Starred {
+			title += " ★"
+		}
+
+		fmt.Fprintf(&text, "ID: %v\nTitle: %v\n", post.ShortID, title)
+
+		if len(post.AlsoIn) > 0 {
+			fmt.Fprintf(&text, "Also in: %v\n", strings.Join(post.AlsoIn, ", "))
+		}
+
+		if len(post.Tags) > 0 {
+			fmt.Fprintf(&text, "Tags: %v\n", strings.Join(post.Tags, ", "))
+		}
+
+		fmt.Fprintf(&text, "Description:\n%v\nPublished at:%v\n\n", post.Description, post.PublishedAt.UTC().Format("2006-01-02"))
+	}
+
+	return text.String()
+}
+
+// handlerBrowse shows one entry per story, naming the other followed feeds
+// that carried it. The user's rules run again on every post, so rules added
+// after a post was fetched apply to it too; hidden posts are skipped and
+// more are fetched until limit posts are shown.
+func handlerBrowse(s *state, cmd command, user database.User) (any, error) {
 	limit := 2
 	if len(cmd.arguments) >= 1 {
 		arg, err := strconv.Atoi(cmd.arguments[0])
 
 		if err != nil {
-			log.Fatalf("Invalid limit argument passed %v\n", err.Error())
+			return nil, fmt.Errorf("invalid limit argument passed %v", err)
 		}
 
 		limit = arg
 	}
 
-	fmt.Println(limit)
-	posts, err := s.db.GetPostsForUser(context.Background(), int32(limit))
+	if limit < 1 {
+		return nil, errors.New("expecting a positive limit.")
+	}
+
+	options, err := renderOptions(cmd)
 
 	if err != nil {
+		return nil, err
+	}
+
+	set, err := loadRules(cmd.ctx, s, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(postsResult, 0, limit)
+
+	for offset := 0; len(result) < limit; offset += limit {
+		posts, err := s.db.GetStoriesForUser(cmd.ctx, database.GetStoriesForUserParams{
+			UserID: uuid.NullUUID{
+				UUID:  user.ID,
+				Valid: true,
+			},
+			Limit:  int32(limit),
+			Offset: int32(offset),
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get posts %v", err)
+		}
+
+		for _, post := range posts {
+			if len(result) == limit {
+				break
+			}
 
-		return errors.New(fmt.Sprintf("could not get posts %v\n", err.Error()))
+			outcome := set.Apply(storyRulePost(post))
+
+			if (post.Hidden || outcome.Hide) && !cmd.boolFlag("hidden") {
+				continue
+			}
+
+			result = append(result, browsePost(post, outcome, cmd, options))
+		}
+
+		if len(posts) < limit {
+			break
+		}
 	}
 
-	for _, post := range posts {
-		fmt.Printf("Title: %v\nDescription: %v\nPublished at:%v\n", post.Title, post.Description.String, post.PublishedAt.Time.UTC().Format("2006-01-02"))
+	return result, nil
+
+}
+
+// browsePost renders a story for browse with the effect of the rules that
+// apply to it.
+func browsePost(post database.GetStoriesForUserRow, outcome rules.Outcome, cmd command, options htmltext.Options) postResult {
+	description := post.Description.String
+
+	if !cmd.boolFlag("raw") {
+		description = htmltext.Render(description, options)
 	}
 
-	return nil
+	var alsoIn []string
+
+	if post.AlsoIn != "" {
+		alsoIn = strings.Split(post.AlsoIn, "\n")
+	}
+
+	tags := post.Tags
+
+	for _, tag := range outcome.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return postResult{
+		ID:          post.ID,
+		ShortID:     post.Seq,
+		Title:       post.Title,
+		Url:         post.Url,
+		Description: description,
+		PublishedAt: post.PublishedAt.Time,
+		FeedID:      post.FeedID,
+		AlsoIn:      alsoIn,
+		Starred:     post.Starred || outcome.Star,
+		Tags:        tags,
+	}
+}
+
+// renderOptions reads --width and --color. Colors are only used for text
+// output, and with auto only when stdout is a terminal and NO_COLOR is unset.
+func renderOptions(cmd command) (htmltext.Options, error) {
+	options := htmltext.Options{
+		Width: cmd.intFlag("width"),
+	}
+
+	terminal := term.IsTerminal(int(os.Stdout.Fd()))
+
+	if options.Width <= 0 && terminal {
+		width, _, err := term.GetSize(int(os.Stdout.Fd()))
+
+		if err == nil {
+			options.Width = width
+		}
+	}
+
+	switch cmd.stringFlag("color") {
+	case "always":
+		options.Color = true
+	case "auto":
+		options.Color = terminal && os.Getenv("NO_COLOR") == ""
+	case "never":
+	default:
+		return options, fmt.Errorf("invalid --color %q, expecting auto, always or never", cmd.stringFlag("color"))
+	}
+
+	if cmd.stringFlag("output") != string(output.Text) {
+		options.Color = false
+	}
 
+	return options, nil
 }
 
 type RSSFeed struct {
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Title string `xml:"title"`
+		// AtomLinks comes before Link so that atom:link elements are not
+		// taken for the site link.
+		AtomLinks   []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+		Link        string     `xml:"link"`
+		Description string     `xml:"description"`
+		Item        []RSSItem  `xml:"item"`
 	} `xml:"channel"`
+	// Hub and Self are the WebSub hub and topic urls the feed advertises,
+	// in its Link headers or its atom:link elements.
+	Hub  string `xml:"-"`
+	Self string `xml:"-"`
+}
+
+type AtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string `xml:"category"`
+}
+
+// author returns the item's author, which many feeds only give as the
+// Dublin Core creator.
+func (item RSSItem) author() string {
+	if strings.TrimSpace(item.Author) != "" {
+		return strings.TrimSpace(item.Author)
+	}
+
+	return strings.TrimSpace(item.Creator)
+}
+
+// pubDateLayouts are the layouts RSS pubDates are parsed with, in order:
+// RFC 822 dates with four digit years as the spec asks, the same with a zone
+// name, and the RFC 3339 dates some feeds use instead.
+var pubDateLayouts = []string{time.RFC1123Z, time.RFC1123, time.RFC3339}
+
+// published returns the item's publication date, or the current time when
+// it has none gator can parse.
+func (item RSSItem) published() time.Time {
+	value := strings.TrimSpace(item.PubDate)
+
+	for _, layout := range pubDateLayouts {
+		published, err := time.Parse(layout, value)
+
+		if err == nil {
+			return published
+		}
+	}
+
+	return time.Now()
+}
+
+// itemBaseURL returns the first absolute url among links, used to resolve
+// the relative links of an item: its own link, the site and the feed.
+func itemBaseURL(links ...string) *url.URL {
+	for _, link := range links {
+		parsed, err := url.Parse(strings.TrimSpace(link))
+
+		if err == nil && parsed.IsAbs() {
+			return parsed
+		}
+	}
+
+	return nil
 }
 
 func fetchFeed(ctx context.Context, feedUrl string) (*RSSFeed, error) {
 	request, err := http.NewRequestWithContext(ctx, "GET", feedUrl, nil)
 
-	request.Header.Set("User-Agent", "gator")
-
 	if err != nil {
-		log.Fatalf("Error fetching feed: %v\n", err.Error())
+		return nil, fmt.Errorf("error fetching feed: %v", err)
 	}
 
+	request.Header.Set("User-Agent", "gator")
+
+	start := time.Now()
+
 	resp, err := http.DefaultClient.Do(request)
 
 	if err != nil {
-		log.Fatalf("Error returning response: %v\n", err.Error())
+		metricFetches.Inc("", "network_error")
+		return nil, fmt.Errorf("error returning response: %v", err)
 	}
 
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 
+	metricFetchDuration.Observe(time.Since(start).Seconds())
+	metricFetchBytes.Add(float64(len(body)))
+	status := strconv.Itoa(resp.StatusCode)
+
 	if err != nil {
-		log.Fatalf("Error reading body: %v\n", err.Error())
+		metricFetches.Inc(status, "network_error")
+		return nil, fmt.Errorf("error reading body: %v", err)
 	}
 
 	if resp.StatusCode > 299 {
-		log.Fatalf("Response failed with status code: %d and\nbody: %s\n", resp.StatusCode, body)
+		metricFetches.Inc(status, "http_error")
+		return nil, fmt.Errorf("response failed with status code: %d and\nbody: %s", resp.StatusCode, body)
 	}
 
-	rssFeed := &RSSFeed{}
-
-	err = xml.Unmarshal(body, rssFeed)
+	rssFeed, err := parseFeed(body)
 
 	if err != nil {
-		log.Fatalf("Failed to unmarshal xml: %v\n", err.Error())
+		metricFetches.Inc(status, "parse_error")
+		metricParseErrors.Inc(feedFormat(body))
+		return nil, err
 	}
 
-	html.UnescapeString(rssFeed.Channel.Title)
-	html.UnescapeString(rssFeed.Channel.Description)
+	metricFetches.Inc(status, "ok")
 
-	rssFeed.Channel.Description = html.UnescapeString(rssFeed.Channel.Description)
-	rssFeed.Channel.Title = html.UnescapeString(rssFeed.Channel.Title)
-
-	return rssFeed, nil
-
-}
+	hub, self := websub.Links(resp.Header)
 
-type commands struct {
-	commands map[string]func(*state, command) error
-}
-
-func (c *commands) register(name string, f func(*state, command) error) {
-
-	_, ok := c.commands[name]
+	if hub != "" {
+		rssFeed.Hub = hub
+	}
 
-	if ok {
-		log.Fatal("command already registered")
+	if self != "" {
+		rssFeed.Self = self
 	}
 
-	c.commands[name] = f
+	return rssFeed, nil
 
 }
 
-func (c *commands) run(s *state, cmd command) error {
-	handler, ok := c.commands[cmd.name]
+// parseFeed parses a feed document, fetched or pushed by a WebSub hub.
+func parseFeed(body []byte) (*RSSFeed, error) {
+	rssFeed := &RSSFeed{}
+
+	err := xml.Unmarshal(body, rssFeed)
 
-	if !ok {
-		return errors.New("no such command")
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal xml: %v", err)
 	}
 
-	err := handler(s, cmd)
+	rssFeed.Channel.Description = html.UnescapeString(rssFeed.Channel.Description)
+	rssFeed.Channel.Title = html.UnescapeString(rssFeed.Channel.Title)
 
-	if err != nil {
-		return errors.New(err.Error())
+	for _, link := range rssFeed.Channel.AtomLinks {
+		switch {
+		case link.Rel == "hub" && rssFeed.Hub == "":
+			rssFeed.Hub = strings.TrimSpace(link.Href)
+		case link.Rel == "self" && rssFeed.Self == "":
+			rssFeed.Self = strings.TrimSpace(link.Href)
+		}
 	}
 
-	return nil
+	return rssFeed, nil
 }
 
-func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
+func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) (any, error)) func(*state, command) (any, error) {
 
-	return func(s *state, c command) error {
+	return func(s *state, c command) (any, error) {
 
 		dbQuery := s.db
 
-		user, err := dbQuery.GetUser(context.Background(), s.config.CurrentUserName)
+		user, err := dbQuery.GetUser(c.ctx, s.config.CurrentUserName)
 
 		if err != nil {
-			return errors.New("could not get logged in user.")
+			return nil, errors.New("could not get logged in user.")
 		}
 
 		return handler(s, c, user)
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRSSItemPublished(t *testing.T) {
+	tests := []struct {
+		pubDate string
+		want    time.Time
+	}{
+		{pubDate: "Tue, 13 Aug 2024 09:30:00 +0200", want: time.Date(2024, 8, 13, 7, 30, 0, 0, time.UTC)},
+		{pubDate: "Tue, 13 Aug 2024 07:30:00 GMT", want: time.Date(2024, 8, 13, 7, 30, 0, 0, time.UTC)},
+		{pubDate: "  Tue, 13 Aug 2024 07:30:00 +0000\n", want: time.Date(2024, 8, 13, 7, 30, 0, 0, time.UTC)},
+		{pubDate: "2024-08-13T07:30:00Z", want: time.Date(2024, 8, 13, 7, 30, 0, 0, time.UTC)},
+		{pubDate: "2024-08-13T09:30:00+02:00", want: time.Date(2024, 8, 13, 7, 30, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		if got := (RSSItem{PubDate: test.pubDate}).published(); !got.Equal(test.want) {
+			t.Errorf("published(%q) = %v, want %v", test.pubDate, got, test.want)
+		}
+	}
+
+	for _, pubDate := range []string{"", "yesterday", "2024-08-13"} {
+		before := time.Now()
+
+		if got := (RSSItem{PubDate: pubDate}).published(); got.Before(before) || got.After(time.Now()) {
+			t.Errorf("published(%q) = %v, want the current time", pubDate, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/metrics"
+)
+
+// registry holds the metrics agg serves with --metrics-addr.
+var registry = metrics.NewRegistry()
+
+var (
+	metricFetches = registry.NewCounter("gator_feed_fetches_total",
+		"Feed fetches by HTTP status, empty when no response was received, and outcome: ok, network_error, http_error or parse_error.",
+		"status", "outcome")
+	metricFetchDuration = registry.NewHistogram("gator_feed_fetch_duration_seconds",
+		"Time taken to fetch a feed, from sending the request to reading the whole body.",
+		metrics.DefaultBuckets)
+	metricFetchBytes = registry.NewCounter("gator_feed_fetch_bytes_total",
+		"Bytes of feed bodies downloaded.")
+	metricParseErrors = registry.NewCounter("gator_feed_parse_errors_total",
+		"Feeds that could not be parsed, by the format their root element names: rss, atom, rdf or unknown.",
+		"format")
+	metricPostsInserted = registry.NewCounter("gator_posts_inserted_total",
+		"New posts stored from fetched or pushed feeds.")
+	metricPostsDuplicate = registry.NewCounter("gator_posts_duplicate_total",
+		"Feed items skipped because their post was already stored.")
+	metricQueueLag = registry.NewGauge("gator_feed_queue_lag_seconds",
+		"Time since the next feed to fetch, the one fetched longest ago, was last fetched or added.")
+	metricQueryDuration = registry.NewHistogram("gator_db_query_duration_seconds",
+		"Time taken by database queries, by query name.",
+		metrics.DefaultBuckets, "query")
+)
+
+// instrumentedDB times the queries of the database package, run on the
+// database or in a transaction. Row iteration of :many queries is not
+// included.
+type instrumentedDB struct {
+	db database.DBTX
+}
+
+func (i instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery(query, time.Now())
+
+	return i.db.ExecContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+func (i instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery(query, time.Now())
+
+	return i.db.QueryContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observeQuery(query, time.Now())
+
+	return i.db.QueryRowContext(ctx, query, args...)
+}
+
+func observeQuery(query string, start time.Time) {
+	metricQueryDuration.Observe(time.Since(start).Seconds(), queryName(query))
+}
+
+// queryName returns the name sqlc puts in the first line of every query,
+// like GetNextFeedToFetch.
+func queryName(query string) string {
+	line, _, _ := strings.Cut(query, "\n")
+	name, ok := strings.CutPrefix(line, "-- name: ")
+
+	if !ok {
+		return "unknown"
+	}
+
+	name, _, _ = strings.Cut(name, " ")
+
+	return name
+}
+
+// feedFormat guesses the format of a feed body from its root element, to
+// label parse errors.
+func feedFormat(body []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+
+	for {
+		token, err := decoder.Token()
+
+		if err != nil {
+			return "unknown"
+		}
+
+		start, ok := token.(xml.StartElement)
+
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(start.Name.Local) {
+		case "rss":
+			return "rss"
+		case "feed":
+			return "atom"
+		case "rdf":
+			return "rdf"
+		}
+
+		return "unknown"
+	}
+}
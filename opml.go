@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/opml"
+)
+
+type importSummary struct {
+	Created  int      `json:"created"`
+	Followed int      `json:"followed"`
+	Skipped  int      `json:"skipped"`
+	Invalid  []string `json:"invalid"`
+}
+
+func (r importSummary) Text() string {
+	var text strings.Builder
+
+	for _, title := range r.Invalid {
+		fmt.Fprintf(&text, "invalid: %v\n", title)
+	}
+
+	fmt.Fprintf(&text, "created: %d\nfollowed: %d\nskipped: %d\ninvalid: %d\n", r.Created, r.Followed, r.Skipped, len(r.Invalid))
+
+	return text.String()
+}
+
+var importCommand = &commandSpec{
+	name:        "import",
+	description: "Import subscriptions from another reader.",
+	subcommands: []*commandSpec{
+		{
+			name:        "opml",
+			description: "Create missing feeds from an OPML file, follow them and keep their folders.",
+			args:        []string{"<file>"},
+			handler:     middlewareLoggedIn(handlerImportOPML),
+		},
+	},
+}
+
+var exportCommand = &commandSpec{
+	name:        "export",
+	description: "Export your subscriptions.",
+	subcommands: []*commandSpec{
+		{
+			name:        "opml",
+			description: "Write the feeds you follow as OPML 2.0, grouped by folder. Prints to stdout when no file is given.",
+			args:        []string{"[file]"},
+			handler:     middlewareLoggedIn(handlerExportOPML),
+		},
+	},
+}
+
+func handlerImportOPML(s *state, cmd command, user database.User) (any, error) {
+	file, err := os.Open(cmd.arguments[0])
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open %v", err)
+	}
+
+	defer file.Close()
+
+	document, err := opml.Parse(file)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse opml %v", err)
+	}
+
+	return importSubscriptions(cmd.ctx, s, user, document.Subscriptions())
+}
+
+type exportResult struct {
+	File  string `json:"file"`
+	Feeds int    `json:"feeds"`
+}
+
+func (r exportResult) Text() string {
+	return fmt.Sprintf("exported %d feeds to %v", r.Feeds, r.File)
+}
+
+// handlerExportOPML writes the OPML document itself to stdout when no file is
+// given, so it only returns a result when writing to a file.
+func handlerExportOPML(s *state, cmd command, user database.User) (any, error) {
+	feeds, err := s.db.GetFollowedFeedsForUser(cmd.ctx, uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	})
+
+	if err != nil {
+		return nil, errors.New("could not get feed follows")
+	}
+
+	subscriptions := make([]opml.Subscription, 0, len(feeds))
+
+	for _, feed := range feeds {
+		subscriptions = append(subscriptions, opml.Subscription{
+			Title:   feed.Name,
+			XMLURL:  feed.Url,
+			HTMLURL: feed.SiteUrl.String,
+			Folder:  feed.Folder.String,
+		})
+	}
+
+	document := opml.NewDocument(fmt.Sprintf("%v's gator subscriptions", user.Name), subscriptions, time.Now())
+
+	if len(cmd.arguments) < 1 {
+		err = opml.Write(cmd.out, document)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not write opml %v", err)
+		}
+
+		return nil, nil
+	}
+
+	file, err := os.Create(cmd.arguments[0])
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create %v", err)
+	}
+
+	defer file.Close()
+
+	err = opml.Write(file, document)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not write opml %v", err)
+	}
+
+	return exportResult{
+		File:  cmd.arguments[0],
+		Feeds: len(subscriptions),
+	}, nil
+}
+
+func importSubscriptions(ctx context.Context, s *state, user database.User, subscriptions []opml.Subscription) (importSummary, error) {
+	summary := importSummary{
+		Invalid: []string{},
+	}
+
+	userID := uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	}
+
+	following, err := s.db.GetFollowedFeedsForUser(ctx, userID)
+
+	if err != nil {
+		return summary, errors.New("could not get feed follows")
+	}
+
+	seen := make(map[string]bool)
+
+	for _, feed := range following {
+		seen[feed.Url] = true
+	}
+
+	for _, subscription := range subscriptions {
+		if !validFeedUrl(subscription.XMLURL) {
+			summary.Invalid = append(summary.Invalid, subscription.Title)
+			continue
+		}
+
+		// Follows are stored with canonical urls, so the subscription's
+		// url is compared in the same form.
+		feedUrl := canonicalUrl(ctx, s, subscription.XMLURL)
+
+		if seen[feedUrl] {
+			summary.Skipped++
+			continue
+		}
+
+		seen[feedUrl] = true
+
+		feedID, created, err := findOrCreateFeed(ctx, s, user, feedUrl, subscription)
+
+		if err != nil {
+			return summary, err
+		}
+
+		if created {
+			summary.Created++
+		}
+
+		_, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+			ID:     uuid.New(),
+			UserID: userID,
+			FeedID: uuid.NullUUID{
+				UUID:  feedID,
+				Valid: true,
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+
+		if isUniqueViolation(err) {
+			summary.Skipped++
+			continue
+		}
+
+		if err != nil {
+			return summary, fmt.Errorf("could not follow %v %v", subscription.XMLURL, err)
+		}
+
+		if subscription.Folder != "" {
+			err = s.db.SetFeedFollowFolder(ctx, database.SetFeedFollowFolderParams{
+				UserID: userID,
+				FeedID: uuid.NullUUID{
+					UUID:  feedID,
+					Valid: true,
+				},
+				Folder: sql.NullString{
+					String: subscription.Folder,
+					Valid:  true,
+				},
+			})
+
+			if err != nil {
+				return summary, fmt.Errorf("could not set folder for %v %v", subscription.XMLURL, err)
+			}
+		}
+
+		summary.Followed++
+	}
+
+	return summary, nil
+}
+
+// findOrCreateFeed returns the id of the feed with the canonical url of a
+// subscription, creating it when it is not in the database yet.
+func findOrCreateFeed(ctx context.Context, s *state, user database.User, feedUrl string, subscription opml.Subscription) (uuid.UUID, bool, error) {
+	feed, err := s.db.GetFeed(ctx, feedUrl)
+
+	if err == nil {
+		return feed.ID, false, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, false, fmt.Errorf("could not get feed %v", err)
+	}
+
+	name := subscription.Title
+
+	if name == "" {
+		name = subscription.XMLURL
+	}
+
+	createdFeed, err := s.db.CreateFeed(ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		Name:      name,
+		Url:       feedUrl,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+	})
+
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("could not create feed %v %v", subscription.XMLURL, err)
+	}
+
+	if subscription.HTMLURL != "" {
+		err = s.db.SetFeedSiteUrl(ctx, database.SetFeedSiteUrlParams{
+			ID: createdFeed.ID,
+			SiteUrl: sql.NullString{
+				String: subscription.HTMLURL,
+				Valid:  true,
+			},
+		})
+
+		if err != nil {
+			return uuid.Nil, false, fmt.Errorf("could not set site url for %v %v", subscription.XMLURL, err)
+		}
+	}
+
+	return createdFeed.ID, true, nil
+}
+
+func validFeedUrl(feedUrl string) bool {
+	parsed, err := url.Parse(feedUrl)
+
+	if err != nil {
+		return false
+	}
+
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
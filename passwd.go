@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/password"
+	"golang.org/x/term"
+)
+
+// minPasswordLength is the shortest password passwd accepts.
+const minPasswordLength = 8
+
+var passwdCommand = &commandSpec{
+	name:        "passwd",
+	description: "Set the password used to sign in to the web reader and to Fever or Google Reader clients. Read from the terminal, or from the first line of stdin.",
+	handler:     middlewareLoggedIn(handlerPasswd),
+}
+
+func handlerPasswd(s *state, cmd command, user database.User) (any, error) {
+	secret, err := readNewPassword()
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := password.Hash(secret)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not hash password %v", err)
+	}
+
+	err = s.db.SetUserPassword(cmd.ctx, database.SetUserPasswordParams{
+		ID: user.ID,
+		PasswordHash: sql.NullString{
+			String: hash,
+			Valid:  true,
+		},
+		FeverApiKey: sql.NullString{
+			String: feverApiKey(user.Name, secret),
+			Valid:  true,
+		},
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not set password %v", err)
+	}
+
+	return messageResult{Message: fmt.Sprintf("password set for %v", user.Name)}, nil
+}
+
+// readNewPassword prompts twice without echo on a terminal and otherwise
+// reads one line, so passwords can be piped in.
+func readNewPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+		if err != nil && line == "" {
+			return "", fmt.Errorf("could not read password %v", err)
+		}
+
+		return checkPassword(strings.TrimRight(line, "\r\n"))
+	}
+
+	fmt.Fprint(os.Stderr, "New password: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		return "", fmt.Errorf("could not read password %v", err)
+	}
+
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		return "", fmt.Errorf("could not read password %v", err)
+	}
+
+	if string(first) != string(second) {
+		return "", errors.New("passwords do not match")
+	}
+
+	return checkPassword(string(first))
+}
+
+// feverApiKey is the key Fever clients send, md5 of "user:password". The
+// protocol fixes the scheme, so it is stored next to the real password hash.
+func feverApiKey(name, secret string) string {
+	sum := md5.Sum([]byte(name + ":" + secret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func checkPassword(secret string) (string, error) {
+	if len([]rune(secret)) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	return secret, nil
+}
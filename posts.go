@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/htmltext"
+	"github.com/mambo-dev/gator/internal/output"
+	"golang.org/x/term"
+)
+
+var openCommand = &commandSpec{
+	name:        "open",
+	description: "Open a post in $BROWSER, or the system browser, and mark it read.",
+	args:        []string{"<post-id>"},
+	handler:     middlewareLoggedIn(handlerOpen),
+}
+
+var showCommand = &commandSpec{
+	name:        "show",
+	description: "Show a post rendered in full through $PAGER and mark it read.",
+	args:        []string{"<post-id>"},
+	flags:       renderFlags,
+	handler:     middlewareLoggedIn(handlerShow),
+}
+
+// renderFlags are the flags of the commands that render post HTML.
+func renderFlags(flags *flag.FlagSet) {
+	flags.Int("width", 0, "wrap descriptions at `columns`, the terminal width by default")
+	flags.String("color", "auto", "style descriptions with ANSI colors: auto, always or never")
+	flags.Bool("raw", false, "print descriptions as the feed sent them, without rendering HTML")
+}
+
+// lookupPost finds a post by the short id browse shows, with or without a
+// leading #.
+func lookupPost(ctx context.Context, s *state, id string) (database.GetPostBySeqRow, error) {
+	seq, err := strconv.ParseInt(strings.TrimPrefix(id, "#"), 10, 64)
+
+	if err != nil {
+		return database.GetPostBySeqRow{}, fmt.Errorf("invalid post id %q, expecting the number shown by browse", id)
+	}
+
+	post, err := s.db.GetPostBySeq(ctx, seq)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return post, fmt.Errorf("no post with id %v", seq)
+	}
+
+	if err != nil {
+		return post, fmt.Errorf("could not get post %v", err)
+	}
+
+	return post, nil
+}
+
+func markPostRead(ctx context.Context, s *state, user database.User, postID uuid.UUID) error {
+	err := s.db.MarkPostRead(ctx, database.MarkPostReadParams{
+		ID:     uuid.New(),
+		UserID: user.ID,
+		PostID: postID,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not mark post as read %v", err)
+	}
+
+	return nil
+}
+
+func handlerOpen(s *state, cmd command, user database.User) (any, error) {
+	post, err := lookupPost(cmd.ctx, s, cmd.arguments[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = openBrowser(post.Url)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open browser %v", err)
+	}
+
+	err = markPostRead(cmd.ctx, s, user, post.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return messageResult{Message: fmt.Sprintf("opened %v", post.Url)}, nil
+}
+
+func handlerShow(s *state, cmd command, user database.User) (any, error) {
+	post, err := lookupPost(cmd.ctx, s, cmd.arguments[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = markPostRead(cmd.ctx, s, user, post.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.stringFlag("output") != string(output.Text) {
+		return postResult{
+			ID:          post.ID,
+			ShortID:     post.Seq,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description.String,
+			PublishedAt: post.PublishedAt.Time,
+			FeedID:      post.FeedID,
+		}, nil
+	}
+
+	options, err := renderOptions(cmd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byline := html.EscapeString(post.FeedName)
+
+	if post.PublishedAt.Valid {
+		byline += " · " + post.PublishedAt.Time.Local().Format("Mon, 02 Jan 2006 15:04")
+	}
+
+	content := post.Description.String
+
+	if post.Content.Valid {
+		content = post.Content.String
+	}
+
+	if cmd.boolFlag("raw") {
+		content = "<pre>" + html.EscapeString(content) + "</pre>"
+	}
+
+	document := fmt.Sprintf("<h1>%v</h1><p>%v<br>%v</p><hr>%v",
+		html.EscapeString(post.Title), byline, html.EscapeString(post.Url), content)
+
+	return nil, page(cmd.out, htmltext.Render(document, options)+"\n")
+}
+
+// page shows text through $PAGER, less by default, when w is a terminal, and
+// writes it out directly otherwise.
+func page(w io.Writer, text string) error {
+	pager := strings.Fields(os.Getenv("PAGER"))
+
+	if len(pager) == 0 {
+		pager = []string{"less"}
+	}
+
+	file, ok := w.(*os.File)
+
+	if !ok || !term.IsTerminal(int(file.Fd())) || pager[0] == "cat" {
+		_, err := fmt.Fprint(w, text)
+		return err
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = file
+	cmd.Stderr = os.Stderr
+
+	// Like git, let less quit on short posts and pass colors through.
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	err := cmd.Run()
+
+	if errors.Is(err, exec.ErrNotFound) {
+		_, err = fmt.Fprint(w, text)
+	}
+
+	return err
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeBrowser writes a $BROWSER script that records its arguments, and
+// returns a function waiting for them.
+func fakeBrowser(t *testing.T) (string, func() string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake browser is a shell script")
+	}
+
+	dir := t.TempDir()
+	opened := filepath.Join(dir, "opened")
+	script := filepath.Join(dir, "browser")
+
+	err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > "+opened+".tmp && mv "+opened+".tmp "+opened+"\n"), 0o755)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// openBrowser does not wait for the browser, so neither can the test.
+	wait := func() string {
+		deadline := time.Now().Add(5 * time.Second)
+
+		for time.Now().Before(deadline) {
+			args, err := os.ReadFile(opened)
+
+			if err == nil {
+				return strings.TrimSpace(string(args))
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		t.Fatal("the browser was not started")
+		return ""
+	}
+
+	return script, wait
+}
+
+func TestOpenPost(t *testing.T) {
+	postID := uuid.New()
+	postUrl := "https://go.dev/blog/go1.23"
+
+	tests := []struct {
+		name     string
+		browser  func(script string) string
+		wantArgs string
+	}{
+		{
+			name:     "url appended",
+			browser:  func(script string) string { return script + " --new-tab" },
+			wantArgs: "--new-tab " + postUrl,
+		},
+		{
+			name:     "url substituted",
+			browser:  func(script string) string { return script + " --url=%s --raise:firefox" },
+			wantArgs: "--url=" + postUrl + " --raise",
+		},
+	}
+
+	for _, test := range tests {
+		script, opened := fakeBrowser(t)
+		t.Setenv("BROWSER", test.browser(script))
+
+		db := newFakeDB(t)
+		alice := uuid.New()
+
+		db.on("GetUser", oneRow(userColumns, alice.String(), time.Now(), time.Now(), "alice", nil, nil))
+		db.on("GetPostBySeq", func(args []driver.Value) (fakeRows, error) {
+			if args[0] != int64(7) {
+				return fakeRows{}, nil
+			}
+
+			return oneRow(postBySeqColumns,
+				postID.String(), time.Now(), time.Now(), "Go 1.23", postUrl, "", nil,
+				uuid.NewString(), int64(7), nil, nil, nil, nil, []byte("{}"), nil, "Go Blog")(args)
+		})
+		db.on("MarkPostRead", noRows)
+
+		commands := newCommands()
+		commands.register(openCommand)
+
+		err := commands.run(context.Background(), db.state("alice"), []string{"open", "#7"}, io.Discard)
+
+		if err != nil {
+			t.Fatalf("%v: open: %v", test.name, err)
+		}
+
+		if args := opened(); args != test.wantArgs {
+			t.Errorf("%v: browser got %q, want %q", test.name, args, test.wantArgs)
+		}
+
+		calls := db.called("MarkPostRead")
+
+		if len(calls) != 1 || !containsValue(calls[0], alice.String()) || !containsValue(calls[0], postID.String()) {
+			t.Errorf("%v: MarkPostRead calls = %v, want one for alice and post %v", test.name, calls, postID)
+		}
+	}
+}
+
+func TestOpenMissingPost(t *testing.T) {
+	script, _ := fakeBrowser(t)
+	t.Setenv("BROWSER", script)
+
+	db := newFakeDB(t)
+
+	db.on("GetUser", oneRow(userColumns, uuid.NewString(), time.Now(), time.Now(), "alice", nil, nil))
+	db.on("GetPostBySeq", noRows)
+
+	commands := newCommands()
+	commands.register(openCommand)
+
+	err := commands.run(context.Background(), db.state("alice"), []string{"open", "8"}, io.Discard)
+
+	if err == nil || err.Error() != "no post with id 8" {
+		t.Errorf("open error = %v, want no post with id 8", err)
+	}
+
+	if calls := db.called("MarkPostRead"); len(calls) != 0 {
+		t.Errorf("MarkPostRead calls = %v, want none", calls)
+	}
+}
+
+func TestOpenRejectsUnsafeUrls(t *testing.T) {
+	script, _ := fakeBrowser(t)
+	t.Setenv("BROWSER", script)
+
+	for _, postUrl := range []string{
+		"file:///etc/passwd",
+		"javascript:alert(1)",
+		"JavaScript:alert(1)",
+		"data:text/html,<script>alert(1)</script>",
+		"steam://run/1",
+		"/relative/path",
+		"https:///no-host",
+	} {
+		db := newFakeDB(t)
+
+		db.on("GetUser", oneRow(userColumns, uuid.NewString(), time.Now(), time.Now(), "alice", nil, nil))
+		db.on("GetPostBySeq", oneRow(postBySeqColumns,
+			uuid.NewString(), time.Now(), time.Now(), "Trap", postUrl, "", nil,
+			uuid.NewString(), int64(7), nil, nil, nil, nil, []byte("{}"), nil, "Evil Feed"))
+
+		commands := newCommands()
+		commands.register(openCommand)
+
+		err := commands.run(context.Background(), db.state("alice"), []string{"open", "7"}, io.Discard)
+
+		if err == nil || !strings.Contains(err.Error(), "expecting an http or https url") {
+			t.Errorf("open %v: error = %v, want the url refused", postUrl, err)
+		}
+
+		if calls := db.called("MarkPostRead"); len(calls) != 0 {
+			t.Errorf("open %v: MarkPostRead calls = %v, want none", postUrl, calls)
+		}
+	}
+}
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/publish"
+	"github.com/mambo-dev/gator/internal/rules"
+)
+
+const (
+	// defaultBaseUrl is where gator serve listens by default.
+	defaultBaseUrl = "http://localhost:8080"
+	// publishPageSize is how many posts are read at a time when looking
+	// for the posts a rule applies to.
+	publishPageSize = 200
+	// publishMaxScanned bounds how far back a rule feed looks.
+	publishMaxScanned = 2000
+)
+
+var publishCommand = &commandSpec{
+	name:        "publish",
+	description: "List the feeds you publish from gator serve, with their RSS, Atom and JSON Feed urls.",
+	flags:       baseUrlFlag,
+	handler:     middlewareLoggedIn(handlerPublished),
+	subcommands: []*commandSpec{
+		{
+			name:        "add",
+			description: "Publish the posts of the feeds you follow, of a folder or that a rule applies to as a feed.",
+			args:        []string{"<name>"},
+			flags: func(flags *flag.FlagSet) {
+				baseUrlFlag(flags)
+				flags.String("folder", "", "only publish the posts of the feeds in `folder`")
+				flags.String("rule", "", "only publish the posts the rule with this `id` applies to")
+				flags.Int("limit", 50, "publish the newest `N` posts")
+			},
+			handler: middlewareLoggedIn(handlerPublishAdd),
+		},
+		{
+			name:        "remove",
+			description: "Stop publishing a feed. Its urls stop working.",
+			args:        []string{"<name>"},
+			handler:     middlewareLoggedIn(handlerPublishRemove),
+		},
+		{
+			name:        "render",
+			description: "Write a published feed as a document. Prints to stdout when no file is given.",
+			args:        []string{"<name>", "[file]"},
+			flags: func(flags *flag.FlagSet) {
+				baseUrlFlag(flags)
+				flags.String("format", string(publish.RSS), "document `format`: rss, atom or json")
+			},
+			handler: middlewareLoggedIn(handlerPublishRender),
+		},
+	},
+}
+
+func baseUrlFlag(flags *flag.FlagSet) {
+	flags.String("base-url", defaultBaseUrl, "`url` gator serve is reached at, used in feed links")
+}
+
+type publishedFeedResult struct {
+	Name    string `json:"name"`
+	Scope   string `json:"scope"`
+	Limit   int32  `json:"limit"`
+	RSSUrl  string `json:"rss_url"`
+	AtomUrl string `json:"atom_url"`
+	JSONUrl string `json:"json_url"`
+}
+
+func (r publishedFeedResult) Text() string {
+	var text strings.Builder
+
+	fmt.Fprintf(&text, "- %v: %v, newest %d\n", r.Name, r.Scope, r.Limit)
+
+	for _, url := range []string{r.RSSUrl, r.AtomUrl, r.JSONUrl} {
+		fmt.Fprintf(&text, "    %v\n", url)
+	}
+
+	return text.String()
+}
+
+type publishedFeedsResult []publishedFeedResult
+
+func (r publishedFeedsResult) Text() string {
+	if len(r) == 0 {
+		return "No published feeds, add one with \"gator publish add <name>\"."
+	}
+
+	var text strings.Builder
+
+	for _, feed := range r {
+		text.WriteString(feed.Text())
+	}
+
+	return text.String()
+}
+
+func handlerPublished(s *state, cmd command, user database.User) (any, error) {
+	feeds, err := s.db.GetPublishedFeedsForUser(cmd.ctx, user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get published feeds %v", err)
+	}
+
+	ruleSeqs, err := ruleSeqsForUser(cmd.ctx, s, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(publishedFeedsResult, 0, len(feeds))
+
+	for _, feed := range feeds {
+		result = append(result, newPublishedFeedResult(feed, ruleSeqs, cmd.stringFlag("base-url")))
+	}
+
+	return result, nil
+}
+
+func handlerPublishAdd(s *state, cmd command, user database.User) (any, error) {
+	limit := cmd.intFlag("limit")
+
+	if limit < 1 || limit > apiMaxLimit {
+		return nil, fmt.Errorf("expecting a --limit between 1 and %d.", apiMaxLimit)
+	}
+
+	folder := strings.Trim(cmd.stringFlag("folder"), "/ ")
+
+	ruleID := uuid.NullUUID{}
+	ruleSeqs := map[uuid.UUID]int64{}
+
+	if cmd.isSet("rule") {
+		rule, err := ruleBySeq(cmd.ctx, s, user.ID, cmd.stringFlag("rule"))
+
+		if err != nil {
+			return nil, err
+		}
+
+		ruleID = uuid.NullUUID{
+			UUID:  rule.ID,
+			Valid: true,
+		}
+		ruleSeqs[rule.ID] = rule.Seq
+	}
+
+	token, err := randomToken()
+
+	if err != nil {
+		return nil, fmt.Errorf("could not generate token %v", err)
+	}
+
+	feed, err := s.db.CreatePublishedFeed(cmd.ctx, database.CreatePublishedFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      cmd.arguments[0],
+		Token:     token,
+		Folder: sql.NullString{
+			String: folder,
+			Valid:  folder != "",
+		},
+		RuleID:   ruleID,
+		MaxPosts: int32(limit),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not publish feed, is the name already used? %v", err)
+	}
+
+	return newPublishedFeedResult(feed, ruleSeqs, cmd.stringFlag("base-url")), nil
+}
+
+func handlerPublishRemove(s *state, cmd command, user database.User) (any, error) {
+	removed, err := s.db.DeletePublishedFeed(cmd.ctx, database.DeletePublishedFeedParams{
+		UserID: user.ID,
+		Name:   cmd.arguments[0],
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not remove published feed %v", err)
+	}
+
+	if removed == 0 {
+		return nil, fmt.Errorf("no published feed named %v", cmd.arguments[0])
+	}
+
+	return messageResult{Message: fmt.Sprintf("feed %v is no longer published", cmd.arguments[0])}, nil
+}
+
+type renderResult struct {
+	File  string `json:"file"`
+	Posts int    `json:"posts"`
+}
+
+func (r renderResult) Text() string {
+	return fmt.Sprintf("rendered %d posts to %v", r.Posts, r.File)
+}
+
+// handlerPublishRender writes the document itself to stdout when no file is
+// given, so it only returns a result when writing to a file.
+func handlerPublishRender(s *state, cmd command, user database.User) (any, error) {
+	format, err := publish.ParseFormat(cmd.stringFlag("format"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	feed, err := s.db.GetPublishedFeed(cmd.ctx, database.GetPublishedFeedParams{
+		UserID: user.ID,
+		Name:   cmd.arguments[0],
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no published feed named %v", cmd.arguments[0])
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get published feed %v", err)
+	}
+
+	document, err := publishedDocument(cmd.ctx, s, feed, user.Name, cmd.stringFlag("base-url"), format)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cmd.arguments) < 2 {
+		err = publish.Write(cmd.out, format, document)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not write feed %v", err)
+		}
+
+		return nil, nil
+	}
+
+	file, err := os.Create(cmd.arguments[1])
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create %v", err)
+	}
+
+	defer file.Close()
+
+	err = publish.Write(file, format, document)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not write feed %v", err)
+	}
+
+	return renderResult{
+		File:  cmd.arguments[1],
+		Posts: len(document.Items),
+	}, nil
+}
+
+func (srv *server) routePublish() {
+	srv.mux.HandleFunc("GET /published/{token}/{file}", srv.published)
+}
+
+// published serves a published feed. The token in the url is the only
+// credential, so feed readers can subscribe without signing in.
+func (srv *server) published(w http.ResponseWriter, r *http.Request) {
+	format, ok := publish.FormatForFile(r.PathValue("file"))
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	row, err := srv.s.db.GetPublishedFeedByToken(r.Context(), r.PathValue("token"))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		webInternalError(w, "could not get published feed", err)
+		return
+	}
+
+	feed := database.PublishedFeed{
+		ID:        row.ID,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+		UserID:    row.UserID,
+		Name:      row.Name,
+		Token:     row.Token,
+		Folder:    row.Folder,
+		RuleID:    row.RuleID,
+		MaxPosts:  row.MaxPosts,
+	}
+
+	document, err := publishedDocument(r.Context(), srv.s, feed, row.UserName, requestBaseUrl(r), format)
+
+	if err != nil {
+		webInternalError(w, "could not render published feed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Last-Modified", document.Updated.UTC().Format(http.TimeFormat))
+
+	err = publish.Write(w, format, document)
+
+	if err != nil {
+		log.Printf("could not write published feed %v\n", err)
+	}
+}
+
+// requestBaseUrl is the url the server was reached at, as seen by the
+// client.
+func requestBaseUrl(r *http.Request) string {
+	scheme := "http"
+
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded == "http" || forwarded == "https" {
+		scheme = forwarded
+	}
+
+	return scheme + "://" + r.Host
+}
+
+func newPublishedFeedResult(feed database.PublishedFeed, ruleSeqs map[uuid.UUID]int64, baseUrl string) publishedFeedResult {
+	return publishedFeedResult{
+		Name:    feed.Name,
+		Scope:   publishedScope(feed, ruleSeqs),
+		Limit:   feed.MaxPosts,
+		RSSUrl:  publishedUrl(baseUrl, feed.Token, publish.RSS),
+		AtomUrl: publishedUrl(baseUrl, feed.Token, publish.Atom),
+		JSONUrl: publishedUrl(baseUrl, feed.Token, publish.JSON),
+	}
+}
+
+// publishedScope describes which posts a published feed has.
+func publishedScope(feed database.PublishedFeed, ruleSeqs map[uuid.UUID]int64) string {
+	scope := "all followed feeds"
+
+	if feed.Folder.Valid {
+		scope = "folder " + feed.Folder.String
+	}
+
+	if feed.RuleID.Valid {
+		scope += fmt.Sprintf(", matching rule #%v", ruleSeqs[feed.RuleID.UUID])
+	}
+
+	return scope
+}
+
+func publishedUrl(baseUrl, token string, format publish.Format) string {
+	return fmt.Sprintf("%v/published/%v/%v", strings.TrimRight(baseUrl, "/"), token, format.Filename())
+}
+
+// publishedDocument collects the posts of a published feed and describes
+// them as a feed document reached at baseUrl.
+func publishedDocument(ctx context.Context, s *state, feed database.PublishedFeed, author, baseUrl string, format publish.Format) (publish.Feed, error) {
+	var rule *rules.Rule
+
+	if feed.RuleID.Valid {
+		rows, err := s.db.GetRulesForUser(ctx, feed.UserID)
+
+		if err != nil {
+			return publish.Feed{}, fmt.Errorf("could not get rules %v", err)
+		}
+
+		for _, row := range rows {
+			if row.ID == feed.RuleID.UUID {
+				rule, err = ruleFromRow(row)
+
+				if err != nil {
+					return publish.Feed{}, err
+				}
+			}
+		}
+	}
+
+	posts, err := publishedPosts(ctx, s, feed, rule)
+
+	if err != nil {
+		return publish.Feed{}, err
+	}
+
+	description := fmt.Sprintf("Posts from the feeds %v follows", author)
+
+	if feed.Folder.Valid {
+		description = fmt.Sprintf("Posts from the feeds in %v's %v folder", author, feed.Folder.String)
+	}
+
+	if rule != nil {
+		description += ", " + rule.String()
+	}
+
+	document := publish.Feed{
+		ID:          "urn:uuid:" + feed.ID.String(),
+		Title:       feed.Name,
+		Description: description,
+		Link:        strings.TrimRight(baseUrl, "/") + "/",
+		Self:        publishedUrl(baseUrl, feed.Token, format),
+		Author:      author,
+		Updated:     feed.UpdatedAt,
+		Items:       make([]publish.Item, 0, len(posts)),
+	}
+
+	for _, post := range posts {
+		item := publishedItem(post)
+
+		if item.Updated.After(document.Updated) {
+			document.Updated = item.Updated
+		}
+
+		document.Items = append(document.Items, item)
+	}
+
+	return document, nil
+}
+
+// publishedPosts returns the newest posts of a published feed. Without a
+// rule that is a single query; with one, pages of recent posts are matched
+// until the feed is full.
+func publishedPosts(ctx context.Context, s *state, feed database.PublishedFeed, rule *rules.Rule) ([]database.ListStreamPostsForUserRow, error) {
+	params := database.ListStreamPostsForUserParams{
+		UserID: uuid.NullUUID{
+			UUID:  feed.UserID,
+			Valid: true,
+		},
+		Folder:   feed.Folder,
+		Seqs:     []int64{},
+		MaxPosts: feed.MaxPosts,
+	}
+
+	if rule != nil {
+		params.MaxPosts = publishPageSize
+	}
+
+	posts := []database.ListStreamPostsForUserRow{}
+
+	for params.SkipPosts < publishMaxScanned {
+		page, err := s.db.ListStreamPostsForUser(ctx, params)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get posts %v", err)
+		}
+
+		for _, post := range page {
+			if rule != nil && !rule.Applies(streamRulePost(post)) {
+				continue
+			}
+
+			posts = append(posts, post)
+
+			if len(posts) == int(feed.MaxPosts) {
+				return posts, nil
+			}
+		}
+
+		if rule == nil || len(page) < int(params.MaxPosts) {
+			break
+		}
+
+		params.SkipPosts += params.MaxPosts
+	}
+
+	return posts, nil
+}
+
+func publishedItem(post database.ListStreamPostsForUserRow) publish.Item {
+	content := post.Content.String
+
+	if content == "" {
+		content = post.Description.String
+	}
+
+	published := post.CreatedAt
+
+	if post.PublishedAt.Valid {
+		published = post.PublishedAt.Time
+	}
+
+	return publish.Item{
+		ID:         "urn:uuid:" + post.ID.String(),
+		Title:      post.Title,
+		Link:       post.Url,
+		Content:    content,
+		Author:     post.Author.String,
+		Categories: post.Categories,
+		Published:  published,
+		Updated:    post.UpdatedAt,
+		Source: publish.Source{
+			Title:   post.FeedName,
+			Link:    post.FeedSiteUrl.String,
+			FeedUrl: post.FeedUrl,
+		},
+	}
+}
+
+// streamRulePost is what rules see of a post listed for a stream.
+func streamRulePost(post database.ListStreamPostsForUserRow) rules.Post {
+	content := post.Description.String
+
+	if post.Content.Valid {
+		content = post.Content.String
+	}
+
+	return rules.Post{
+		Title:      post.Title,
+		Content:    content,
+		Author:     post.Author.String,
+		Categories: post.Categories,
+		Feed:       post.FeedName,
+	}
+}
+
+// ruleBySeq finds a rule of a user by the id rule shows.
+func ruleBySeq(ctx context.Context, s *state, userID uuid.UUID, id string) (database.Rule, error) {
+	seq, err := strconv.ParseInt(strings.TrimPrefix(id, "#"), 10, 64)
+
+	if err != nil {
+		return database.Rule{}, fmt.Errorf("invalid rule id %q, expecting the number shown by rule", id)
+	}
+
+	rows, err := s.db.GetRulesForUser(ctx, userID)
+
+	if err != nil {
+		return database.Rule{}, fmt.Errorf("could not get rules %v", err)
+	}
+
+	for _, row := range rows {
+		if row.Seq == seq {
+			return row, nil
+		}
+	}
+
+	return database.Rule{}, fmt.Errorf("no rule with id %v", seq)
+}
+
+func ruleSeqsForUser(ctx context.Context, s *state, userID uuid.UUID) (map[uuid.UUID]int64, error) {
+	rows, err := s.db.GetRulesForUser(ctx, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get rules %v", err)
+	}
+
+	seqs := make(map[uuid.UUID]int64, len(rows))
+
+	for _, row := range rows {
+		seqs[row.ID] = row.Seq
+	}
+
+	return seqs, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/tui"
+)
+
+// readerPostLimit caps how many posts of a feed the reader loads at once.
+const readerPostLimit = 200
+
+var tuiCommand = &commandSpec{
+	name:        "tui",
+	description: "Read followed feeds in an interactive terminal reader.",
+	flags: func(flags *flag.FlagSet) {
+		flags.Duration("refresh", 30*time.Second, "how often to reload feeds and posts")
+		flags.Duration("agg", 0, "also collect feeds in the background every `interval`")
+	},
+	handler: middlewareLoggedIn(handlerTui),
+}
+
+// readerSource serves the followed feeds and posts of a user to the reader.
+// ctx is the tui command's, as the reader asks for data without one.
+type readerSource struct {
+	ctx  context.Context
+	s    *state
+	user database.User
+}
+
+func (r readerSource) Feeds() ([]tui.Feed, error) {
+	userID := uuid.NullUUID{
+		UUID:  r.user.ID,
+		Valid: true,
+	}
+
+	followed, err := r.s.db.GetFollowedFeedsForUser(r.ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := r.s.db.GetUnreadCountsForUser(r.ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	unread := make(map[uuid.UUID]int)
+
+	for _, count := range counts {
+		unread[count.FeedID] = int(count.Unread)
+	}
+
+	feeds := make([]tui.Feed, 0, len(followed))
+
+	for _, feed := range followed {
+		feeds = append(feeds, tui.Feed{
+			ID:     feed.ID,
+			Name:   feed.Name,
+			Url:    feed.Url,
+			Folder: feed.Folder.String,
+			Unread: unread[feed.ID],
+		})
+	}
+
+	return feeds, nil
+}
+
+func (r readerSource) Posts(feedID uuid.UUID) ([]tui.Post, error) {
+	rows, err := r.s.db.GetFeedPostsForUser(r.ctx, database.GetFeedPostsForUserParams{
+		UserID: r.user.ID,
+		FeedID: feedID,
+		Limit:  readerPostLimit,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]tui.Post, 0, len(rows))
+
+	for _, row := range rows {
+		description := row.Description.String
+
+		if row.Content.Valid {
+			description = row.Content.String
+		}
+
+		posts = append(posts, tui.Post{
+			ID:          row.ID,
+			Title:       row.Title,
+			Url:         row.Url,
+			Description: description,
+			PublishedAt: row.PublishedAt.Time,
+			Read:        row.ReadAt.Valid,
+			Starred:     row.Starred,
+		})
+	}
+
+	return posts, nil
+}
+
+func (r readerSource) SetRead(postID uuid.UUID, read bool) error {
+	if !read {
+		return r.s.db.MarkPostUnread(r.ctx, database.MarkPostUnreadParams{
+			UserID: r.user.ID,
+			PostID: postID,
+		})
+	}
+
+	return r.s.db.MarkPostRead(r.ctx, database.MarkPostReadParams{
+		ID:     uuid.New(),
+		UserID: r.user.ID,
+		PostID: postID,
+	})
+}
+
+func (r readerSource) SetStarred(postID uuid.UUID, starred bool) error {
+	return r.s.db.SetPostStarred(r.ctx, database.SetPostStarredParams{
+		ID:      uuid.New(),
+		UserID:  r.user.ID,
+		PostID:  postID,
+		Starred: starred,
+	})
+}
+
+func handlerTui(s *state, cmd command, user database.User) (any, error) {
+	// The reader owns the screen, so log output from the background
+	// collector would only garble it.
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	interval := cmd.durationFlag("agg")
+
+	if interval > 0 {
+		ctx, cancel := context.WithCancel(cmd.ctx)
+		defer cancel()
+
+		go aggregate(ctx, s, interval, nil)
+	}
+
+	err := tui.Run(os.Stdin, os.Stdout, readerSource{ctx: cmd.ctx, s: s, user: user}, tui.Options{
+		Title:   "gator - " + user.Name,
+		Refresh: cmd.durationFlag("refresh"),
+		Open:    openBrowser,
+	})
+
+	return nil, err
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+const globalRetentionPolicy = "global"
+
+// pruneInterval limits how often agg runs its automatic prune pass.
+const pruneInterval = time.Hour
+
+// pruneBatchSize is how many posts one delete of prune removes.
+const pruneBatchSize = 500
+
+var retentionCommand = &commandSpec{
+	name:        "retention",
+	description: "List post retention policies. A feed policy replaces the global one.",
+	handler:     handlerRetention,
+	subcommands: []*commandSpec{
+		{
+			name:        "set",
+			description: "Set the retention policy of a feed, or the global one.",
+			args:        []string{"<feed-url|global>"},
+			flags: func(flags *flag.FlagSet) {
+				flags.Int("keep-last", 0, "keep only the newest `N` posts of each feed")
+				flags.Int("max-age-days", 0, "remove posts published more than `N` days ago")
+				flags.Bool("keep-starred", true, "never remove starred posts")
+				flags.Bool("keep-unread", true, "never remove posts unread by a follower")
+			},
+			handler:  handlerRetentionSet,
+			complete: completeRetentionTargets,
+		},
+		{
+			name:        "clear",
+			description: "Remove the retention policy of a feed, or the global one.",
+			args:        []string{"<feed-url|global>"},
+			handler:     handlerRetentionClear,
+			complete:    completeRetentionTargets,
+		},
+	},
+}
+
+var pruneCommand = &commandSpec{
+	name:        "prune",
+	description: "Remove posts outside their retention policy.",
+	flags: func(flags *flag.FlagSet) {
+		flags.Bool("dry-run", false, "report what would be removed without deleting")
+	},
+	handler: handlerPrune,
+}
+
+type retentionPolicyResult struct {
+	Target      string `json:"target"`
+	KeepLast    int32  `json:"keep_last"`
+	MaxAgeDays  int32  `json:"max_age_days"`
+	KeepStarred bool   `json:"keep_starred"`
+	KeepUnread  bool   `json:"keep_unread"`
+}
+
+type retentionPoliciesResult []retentionPolicyResult
+
+func (r retentionPoliciesResult) Text() string {
+	if len(r) == 0 {
+		return "No retention policies set, posts are kept forever."
+	}
+
+	var text strings.Builder
+
+	for _, policy := range r {
+		keepLast := "unlimited"
+
+		if policy.KeepLast > 0 {
+			keepLast = fmt.Sprint(policy.KeepLast)
+		}
+
+		maxAge := "unlimited"
+
+		if policy.MaxAgeDays > 0 {
+			maxAge = fmt.Sprintf("%v days", policy.MaxAgeDays)
+		}
+
+		fmt.Fprintf(&text, "* %v\n - keep last: %v\n - max age: %v\n - keep starred: %v\n - keep unread: %v\n", policy.Target, keepLast, maxAge, policy.KeepStarred, policy.KeepUnread)
+	}
+
+	return text.String()
+}
+
+func handlerRetention(s *state, cmd command) (any, error) {
+	policies, err := s.db.GetRetentionPolicies(cmd.ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get retention policies %v", err)
+	}
+
+	result := make(retentionPoliciesResult, 0, len(policies))
+
+	for _, policy := range policies {
+		target := globalRetentionPolicy
+
+		if policy.FeedUrl.Valid {
+			target = policy.FeedUrl.String
+		}
+
+		result = append(result, retentionPolicyResult{
+			Target:      target,
+			KeepLast:    policy.KeepLast.Int32,
+			MaxAgeDays:  policy.MaxAgeDays.Int32,
+			KeepStarred: policy.KeepStarred,
+			KeepUnread:  policy.KeepUnread,
+		})
+	}
+
+	return result, nil
+}
+
+func handlerRetentionSet(s *state, cmd command) (any, error) {
+	keepLast := cmd.intFlag("keep-last")
+	maxAgeDays := cmd.intFlag("max-age-days")
+
+	if keepLast <= 0 && maxAgeDays <= 0 {
+		return nil, errors.New("expecting --keep-last or --max-age-days.")
+	}
+
+	feedID, err := retentionTarget(cmd.ctx, s, cmd.arguments[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The old policy is only replaced once the new one is stored, so a
+	// failed create leaves the feed with the policy it had.
+	err = s.inTx(cmd.ctx, func(q *database.Queries) error {
+		err := q.DeleteRetentionPolicy(cmd.ctx, feedID)
+
+		if err != nil {
+			return fmt.Errorf("could not replace retention policy %v", err)
+		}
+
+		_, err = q.CreateRetentionPolicy(cmd.ctx, database.CreateRetentionPolicyParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			FeedID:    feedID,
+			KeepLast: sql.NullInt32{
+				Int32: int32(keepLast),
+				Valid: keepLast > 0,
+			},
+			MaxAgeDays: sql.NullInt32{
+				Int32: int32(maxAgeDays),
+				Valid: maxAgeDays > 0,
+			},
+			KeepStarred: cmd.boolFlag("keep-starred"),
+			KeepUnread:  cmd.boolFlag("keep-unread"),
+		})
+
+		if err != nil {
+			return fmt.Errorf("could not create retention policy %v", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return messageResult{Message: fmt.Sprintf("retention policy for %v saved", cmd.arguments[0])}, nil
+}
+
+func handlerRetentionClear(s *state, cmd command) (any, error) {
+	feedID, err := retentionTarget(cmd.ctx, s, cmd.arguments[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.DeleteRetentionPolicy(cmd.ctx, feedID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not delete retention policy %v", err)
+	}
+
+	return messageResult{Message: fmt.Sprintf("retention policy for %v cleared", cmd.arguments[0])}, nil
+}
+
+// retentionTarget resolves "global" to a NULL feed id and anything else to
+// the id of the feed with that url.
+func retentionTarget(ctx context.Context, s *state, target string) (uuid.NullUUID, error) {
+	if target == globalRetentionPolicy {
+		return uuid.NullUUID{}, nil
+	}
+
+	feed, err := s.db.GetFeed(ctx, canonicalUrl(ctx, s, target))
+
+	if err != nil {
+		return uuid.NullUUID{}, errors.New("could not get specified feed.")
+	}
+
+	return uuid.NullUUID{
+		UUID:  feed.ID,
+		Valid: true,
+	}, nil
+}
+
+type prunedPost struct {
+	ID          uuid.UUID `json:"id"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+type pruneResult struct {
+	DryRun bool         `json:"dry_run"`
+	Posts  []prunedPost `json:"posts"`
+}
+
+func (r pruneResult) Text() string {
+	var text strings.Builder
+
+	verb := "Removed"
+
+	if r.DryRun {
+		verb = "Would remove"
+	}
+
+	for _, post := range r.Posts {
+		fmt.Fprintf(&text, "- %v (%v)\n", post.Title, post.Url)
+	}
+
+	fmt.Fprintf(&text, "%v %d posts\n", verb, len(r.Posts))
+
+	return text.String()
+}
+
+func (r pruneResult) Records() any {
+	return r.Posts
+}
+
+func handlerPrune(s *state, cmd command) (any, error) {
+	dryRun := cmd.boolFlag("dry-run")
+
+	pruned, err := prunePosts(cmd.ctx, s, dryRun)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := pruneResult{
+		DryRun: dryRun,
+		Posts:  make([]prunedPost, 0, len(pruned)),
+	}
+
+	for _, post := range pruned {
+		result.Posts = append(result.Posts, prunedPost{
+			ID:          post.ID,
+			FeedID:      post.FeedID,
+			Title:       post.Title,
+			Url:         post.Url,
+			PublishedAt: post.PublishedAt.Time,
+		})
+	}
+
+	return result, nil
+}
+
+// prunePosts deletes every post outside its feed's retention policy unless
+// dryRun is set, and returns the posts that were (or would be) removed. The
+// posts are selected and deleted in one transaction, so a post starred or
+// read in between is not removed by a stale selection.
+func prunePosts(ctx context.Context, s *state, dryRun bool) ([]database.GetExpiredPostsRow, error) {
+	if dryRun {
+		expired, err := s.db.GetExpiredPosts(ctx)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get posts to prune %v", err)
+		}
+
+		return expired, nil
+	}
+
+	var expired []database.GetExpiredPostsRow
+
+	err := s.inTx(ctx, func(q *database.Queries) error {
+		var err error
+
+		expired, err = q.GetExpiredPosts(ctx)
+
+		if err != nil {
+			return fmt.Errorf("could not get posts to prune %v", err)
+		}
+
+		for start := 0; start < len(expired); start += pruneBatchSize {
+			batch := expired[start:min(start+pruneBatchSize, len(expired))]
+			ids := make([]uuid.UUID, 0, len(batch))
+
+			for _, post := range batch {
+				ids = append(ids, post.ID)
+			}
+
+			err = q.DeletePosts(ctx, ids)
+
+			if err != nil {
+				return fmt.Errorf("could not delete posts %v", err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var retentionPolicyColumns = []string{
+	"id", "created_at", "updated_at", "feed_id", "keep_last", "max_age_days", "keep_starred", "keep_unread",
+}
+
+// transactionCalls returns the transaction boundaries and the queries named
+// names, in the order they ran.
+func transactionCalls(db *fakeDB, names ...string) []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	wanted := map[string]bool{"BEGIN": true, "COMMIT": true, "ROLLBACK": true}
+
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var calls []string
+
+	for _, call := range db.calls {
+		if wanted[call.name] {
+			calls = append(calls, call.name)
+		}
+	}
+
+	return calls
+}
+
+func TestRetentionSetReplacesPolicyInTransaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		createErr error
+		want      []string
+	}{
+		{
+			name: "saved",
+			want: []string{"BEGIN", "DeleteRetentionPolicy", "CreateRetentionPolicy", "COMMIT"},
+		},
+		{
+			name:      "create fails",
+			createErr: io.ErrUnexpectedEOF,
+			want:      []string{"BEGIN", "DeleteRetentionPolicy", "CreateRetentionPolicy", "ROLLBACK"},
+		},
+	}
+
+	for _, test := range tests {
+		db := newFakeDB(t)
+		db.on("DeleteRetentionPolicy", noRows)
+		db.on("CreateRetentionPolicy", func(args []driver.Value) (fakeRows, error) {
+			if test.createErr != nil {
+				return fakeRows{}, test.createErr
+			}
+
+			return oneRow(retentionPolicyColumns, uuid.NewString(), time.Now(), time.Now(), nil, int64(10), nil, true, true)(args)
+		})
+
+		c := newCommands()
+		c.register(retentionCommand)
+
+		err := c.run(context.Background(), db.state("alice"), []string{"retention", "set", globalRetentionPolicy, "--keep-last", "10"}, &bytes.Buffer{})
+
+		if (err != nil) != (test.createErr != nil) {
+			t.Errorf("%v: err = %v, want error %v", test.name, err, test.createErr != nil)
+		}
+
+		got := transactionCalls(db, "DeleteRetentionPolicy", "CreateRetentionPolicy")
+
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%v: calls = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+var expiredPostColumns = []string{"id", "feed_id", "title", "url", "published_at"}
+
+func TestPruneDeletesExpiredPostsInTransaction(t *testing.T) {
+	feedID := uuid.NewString()
+	rows := [][]driver.Value{}
+
+	for i := range pruneBatchSize + 2 {
+		rows = append(rows, []driver.Value{uuid.NewString(), feedID, fmt.Sprintf("Post %d", i), fmt.Sprintf("https://go.dev/blog/%d", i), nil})
+	}
+
+	tests := []struct {
+		name   string
+		dryRun bool
+		want   []string
+	}{
+		{
+			name: "prune",
+			want: []string{"BEGIN", "GetExpiredPosts", "DeletePosts", "DeletePosts", "COMMIT"},
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+			want:   []string{"GetExpiredPosts"},
+		},
+	}
+
+	for _, test := range tests {
+		db := newFakeDB(t)
+		db.on("GetExpiredPosts", func(args []driver.Value) (fakeRows, error) {
+			return fakeRows{columns: expiredPostColumns, rows: rows}, nil
+		})
+		db.on("DeletePosts", noRows)
+
+		pruned, err := prunePosts(context.Background(), db.state("alice"), test.dryRun)
+
+		if err != nil {
+			t.Fatalf("%v: prunePosts: %v", test.name, err)
+		}
+
+		if len(pruned) != len(rows) {
+			t.Errorf("%v: pruned %d posts, want %d", test.name, len(pruned), len(rows))
+		}
+
+		got := transactionCalls(db, "GetExpiredPosts", "DeletePosts")
+
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%v: calls = %v, want %v", test.name, got, test.want)
+		}
+
+		// The ids are sent as one Postgres array per batch.
+		deleted := 0
+
+		for _, call := range db.called("DeletePosts") {
+			deleted += strings.Count(call[0].(string), ",") + 1
+		}
+
+		if !test.dryRun && deleted != len(rows) {
+			t.Errorf("%v: deleted %d posts, want %d", test.name, deleted, len(rows))
+		}
+	}
+}
+
+func TestPruneRollsBackOnDeleteFailure(t *testing.T) {
+	db := newFakeDB(t)
+	db.on("GetExpiredPosts", oneRow(expiredPostColumns, uuid.NewString(), uuid.NewString(), "Post", "https://go.dev/blog/1", nil))
+	db.on("DeletePosts", func(args []driver.Value) (fakeRows, error) {
+		return fakeRows{}, io.ErrUnexpectedEOF
+	})
+
+	_, err := prunePosts(context.Background(), db.state("alice"), false)
+
+	if err == nil {
+		t.Fatal("prunePosts succeeded, want the delete error")
+	}
+
+	want := []string{"BEGIN", "GetExpiredPosts", "DeletePosts", "ROLLBACK"}
+
+	if got := transactionCalls(db, "GetExpiredPosts", "DeletePosts"); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
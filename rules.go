@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/rules"
+)
+
+var ruleCommand = &commandSpec{
+	name:        "rule",
+	description: "List your filter rules. Rules run on new posts as they are fetched and on browse.",
+	handler:     middlewareLoggedIn(handlerRules),
+	subcommands: []*commandSpec{
+		{
+			name:        "add",
+			description: "Add a rule applying an action to the posts matching a keyword or regular expression.",
+			args:        []string{"<pattern>"},
+			flags:       ruleFlags,
+			handler:     middlewareLoggedIn(handlerRuleAdd),
+		},
+		{
+			name:        "list",
+			description: "List your filter rules.",
+			handler:     middlewareLoggedIn(handlerRules),
+		},
+		{
+			name:        "remove",
+			description: "Remove a rule by the id shown by rule list.",
+			args:        []string{"<rule-id>"},
+			handler:     middlewareLoggedIn(handlerRuleRemove),
+		},
+		{
+			name:        "test",
+			description: "Show which recent posts a rule would apply to, without saving it.",
+			args:        []string{"<pattern>"},
+			flags: func(flags *flag.FlagSet) {
+				ruleFlags(flags)
+				flags.Int("limit", 100, "test against the newest `N` posts of followed feeds")
+			},
+			handler: middlewareLoggedIn(handlerRuleTest),
+		},
+	},
+}
+
+func ruleFlags(flags *flag.FlagSet) {
+	flags.String("field", string(rules.FieldAny), "`field` to match: any, title, content, author, category or feed")
+	flags.Bool("regex", false, "treat the pattern as a regular expression instead of a keyword")
+	flags.Bool("exclude", false, "apply the action to the posts that do not match")
+	flags.String("action", string(rules.ActionHide), "`action` to apply: hide, read, star or tag")
+	flags.String("tag", "", "`name` of the tag added by the tag action")
+}
+
+type ruleResult struct {
+	ID          int64  `json:"id"`
+	Field       string `json:"field"`
+	Pattern     string `json:"pattern"`
+	Regex       bool   `json:"regex"`
+	Exclude     bool   `json:"exclude"`
+	Action      string `json:"action"`
+	Tag         string `json:"tag,omitempty"`
+	Description string `json:"description"`
+}
+
+func (r ruleResult) Text() string {
+	return fmt.Sprintf("#%v %v", r.ID, r.Description)
+}
+
+type rulesResult []ruleResult
+
+func (r rulesResult) Text() string {
+	if len(r) == 0 {
+		return "No rules, add one with \"gator rule add\"."
+	}
+
+	var text strings.Builder
+
+	for _, rule := range r {
+		fmt.Fprintln(&text, rule.Text())
+	}
+
+	return text.String()
+}
+
+func newRuleResult(seq int64, rule *rules.Rule) ruleResult {
+	return ruleResult{
+		ID:          seq,
+		Field:       string(rule.Field),
+		Pattern:     rule.Pattern,
+		Regex:       rule.Regex,
+		Exclude:     rule.Exclude,
+		Action:      string(rule.Action),
+		Tag:         rule.Tag,
+		Description: rule.String(),
+	}
+}
+
+func handlerRules(s *state, cmd command, user database.User) (any, error) {
+	rows, err := s.db.GetRulesForUser(cmd.ctx, user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get rules %v", err)
+	}
+
+	result := make(rulesResult, 0, len(rows))
+
+	for _, row := range rows {
+		rule, err := ruleFromRow(row)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, newRuleResult(row.Seq, rule))
+	}
+
+	return result, nil
+}
+
+func handlerRuleAdd(s *state, cmd command, user database.User) (any, error) {
+	rule, err := ruleFromFlags(cmd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := s.db.CreateRule(cmd.ctx, database.CreateRuleParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		Field:     string(rule.Field),
+		Pattern:   rule.Pattern,
+		Regex:     rule.Regex,
+		Exclude:   rule.Exclude,
+		Action:    string(rule.Action),
+		Tag: sql.NullString{
+			String: rule.Tag,
+			Valid:  rule.Tag != "",
+		},
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create rule %v", err)
+	}
+
+	return newRuleResult(row.Seq, rule), nil
+}
+
+func handlerRuleRemove(s *state, cmd command, user database.User) (any, error) {
+	seq, err := strconv.ParseInt(strings.TrimPrefix(cmd.arguments[0], "#"), 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule id %q, expecting the number shown by rule", cmd.arguments[0])
+	}
+
+	removed, err := s.db.DeleteRule(cmd.ctx, database.DeleteRuleParams{
+		UserID: user.ID,
+		Seq:    seq,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not delete rule %v", err)
+	}
+
+	if removed == 0 {
+		return nil, fmt.Errorf("no rule with id %v", seq)
+	}
+
+	return messageResult{Message: fmt.Sprintf("rule #%v removed", seq)}, nil
+}
+
+type ruleMatch struct {
+	ShortID int64  `json:"short_id"`
+	Title   string `json:"title"`
+	Feed    string `json:"feed"`
+}
+
+type ruleTestResult struct {
+	Rule    string      `json:"rule"`
+	Tested  int         `json:"tested"`
+	Matches []ruleMatch `json:"matches"`
+}
+
+func (r ruleTestResult) Text() string {
+	var text strings.Builder
+
+	for _, match := range r.Matches {
+		fmt.Fprintf(&text, "- #%v %v (%v)\n", match.ShortID, match.Title, match.Feed)
+	}
+
+	fmt.Fprintf(&text, "%v: applies to %d of the %d newest posts\n", r.Rule, len(r.Matches), r.Tested)
+
+	return text.String()
+}
+
+func (r ruleTestResult) Records() any {
+	return r.Matches
+}
+
+func handlerRuleTest(s *state, cmd command, user database.User) (any, error) {
+	rule, err := ruleFromFlags(cmd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	limit := cmd.intFlag("limit")
+
+	if limit < 1 {
+		return nil, errors.New("expecting a positive --limit.")
+	}
+
+	posts, err := s.db.GetStoriesForUser(cmd.ctx, database.GetStoriesForUserParams{
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+		Limit: int32(limit),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get posts %v", err)
+	}
+
+	result := ruleTestResult{
+		Rule:    rule.String(),
+		Tested:  len(posts),
+		Matches: []ruleMatch{},
+	}
+
+	for _, post := range posts {
+		if !rule.Applies(storyRulePost(post)) {
+			continue
+		}
+
+		result.Matches = append(result.Matches, ruleMatch{
+			ShortID: post.Seq,
+			Title:   post.Title,
+			Feed:    post.FeedName,
+		})
+	}
+
+	return result, nil
+}
+
+func ruleFromFlags(cmd command) (*rules.Rule, error) {
+	return rules.New(
+		cmd.stringFlag("field"),
+		cmd.arguments[0],
+		cmd.boolFlag("regex"),
+		cmd.boolFlag("exclude"),
+		cmd.stringFlag("action"),
+		cmd.stringFlag("tag"),
+	)
+}
+
+func ruleFromRow(row database.Rule) (*rules.Rule, error) {
+	rule, err := rules.New(row.Field, row.Pattern, row.Regex, row.Exclude, row.Action, row.Tag.String)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule #%v %v", row.Seq, err)
+	}
+
+	return rule, nil
+}
+
+func loadRules(ctx context.Context, s *state, userID uuid.UUID) (rules.Set, error) {
+	rows, err := s.db.GetRulesForUser(ctx, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get rules %v", err)
+	}
+
+	set := make(rules.Set, 0, len(rows))
+
+	for _, row := range rows {
+		rule, err := ruleFromRow(row)
+
+		if err != nil {
+			return nil, err
+		}
+
+		set = append(set, rule)
+	}
+
+	return set, nil
+}
+
+// loadFeedRules returns the rules of every follower of a feed, by user.
+func loadFeedRules(ctx context.Context, s *state, feedID uuid.UUID) (map[uuid.UUID]rules.Set, error) {
+	rows, err := s.db.GetRulesForFeed(ctx, uuid.NullUUID{
+		UUID:  feedID,
+		Valid: true,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get rules %v", err)
+	}
+
+	sets := make(map[uuid.UUID]rules.Set)
+
+	for _, row := range rows {
+		rule, err := ruleFromRow(row)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sets[row.UserID] = append(sets[row.UserID], rule)
+	}
+
+	return sets, nil
+}
+
+// applyRules runs the rules of each follower against a new post and stores
+// what they decided in the follower's state of the post.
+func applyRules(ctx context.Context, s *state, sets map[uuid.UUID]rules.Set, postID uuid.UUID, post rules.Post) error {
+	for userID, set := range sets {
+		outcome := set.Apply(post)
+
+		if outcome.Empty() {
+			continue
+		}
+
+		err := storeRuleOutcome(ctx, s, userID, postID, outcome)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func storeRuleOutcome(ctx context.Context, s *state, userID, postID uuid.UUID, outcome rules.Outcome) error {
+	if outcome.Hide {
+		err := s.db.SetPostHidden(ctx, database.SetPostHiddenParams{
+			ID:     uuid.New(),
+			UserID: userID,
+			PostID: postID,
+			Hidden: true,
+		})
+
+		if err != nil {
+			return fmt.Errorf("could not hide post %v", err)
+		}
+	}
+
+	if outcome.Read {
+		err := s.db.MarkPostRead(ctx, database.MarkPostReadParams{
+			ID:     uuid.New(),
+			UserID: userID,
+			PostID: postID,
+		})
+
+		if err != nil {
+			return fmt.Errorf("could not mark post as read %v", err)
+		}
+	}
+
+	if outcome.Star {
+		err := s.db.SetPostStarred(ctx, database.SetPostStarredParams{
+			ID:      uuid.New(),
+			UserID:  userID,
+			PostID:  postID,
+			Starred: true,
+		})
+
+		if err != nil {
+			return fmt.Errorf("could not star post %v", err)
+		}
+	}
+
+	if len(outcome.Tags) > 0 {
+		err := s.db.AddPostTags(ctx, database.AddPostTagsParams{
+			ID:     uuid.New(),
+			UserID: userID,
+			PostID: postID,
+			Tags:   outcome.Tags,
+		})
+
+		if err != nil {
+			return fmt.Errorf("could not tag post %v", err)
+		}
+	}
+
+	return nil
+}
+
+// storyRulePost is what rules see of a post listed by browse.
+func storyRulePost(post database.GetStoriesForUserRow) rules.Post {
+	content := post.Description.String
+
+	if post.Content.Valid {
+		content = post.Content.String
+	}
+
+	return rules.Post{
+		Title:      post.Title,
+		Content:    content,
+		Author:     post.Author.String,
+		Categories: post.Categories,
+		Feed:       post.FeedName,
+	}
+}
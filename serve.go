@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+// shutdownTimeout bounds how long serve waits for requests in flight when
+// it is stopped.
+const shutdownTimeout = 10 * time.Second
+
+// maxRequestBody limits the size of JSON request bodies.
+const maxRequestBody = 1 << 20
+
+var serveCommand = &commandSpec{
+	name:        "serve",
+	description: "Serve the web reader and the gator HTTP API. Sign in to the reader with a password from \"gator passwd\", API clients authenticate with a token from \"gator token create\".",
+	flags: func(flags *flag.FlagSet) {
+		flags.String("addr", ":8080", "`address` to listen on")
+		flags.String("public-url", "", "`url` hubs can reach the server at, enables WebSub push for feeds that advertise a hub")
+	},
+	handler: handlerServe,
+}
+
+// server holds the HTTP handlers of gator serve.
+type server struct {
+	s     *state
+	mux   *http.ServeMux
+	pages map[string]*template.Template
+}
+
+func newServer(s *state) *server {
+	srv := &server{
+		s:     s,
+		mux:   http.NewServeMux(),
+		pages: parseWebPages(),
+	}
+
+	srv.routeAPI()
+	srv.routeWeb()
+	srv.routeFever()
+	srv.routeGReader()
+	srv.routePublish()
+	srv.routeWebSub()
+
+	return srv
+}
+
+func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	srv.mux.ServeHTTP(recorder, r)
+
+	log.Printf("%v %v %d %v\n", r.Method, r.URL.Path, recorder.status, time.Since(start).Round(time.Millisecond))
+}
+
+// statusRecorder remembers the status code written, for the request log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func handlerServe(s *state, cmd command) (any, error) {
+	addr := cmd.stringFlag("addr")
+	srv := newServer(s)
+	d := newDaemon(0)
+	d.route(srv.mux)
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if publicUrl := cmd.stringFlag("public-url"); publicUrl != "" {
+		parsed, err := url.Parse(publicUrl)
+
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid public url %q", publicUrl)
+		}
+
+		go srv.renewWebSub(cmd.ctx, publicUrl)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %v %v", addr, err)
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- httpServer.Serve(listener)
+	}()
+
+	log.Printf("Serving on %v\n", addr)
+
+	d.heartbeat("Serving on " + addr)
+
+	// The watchdog keeps going while requests in flight are drained after
+	// cmd.ctx is cancelled.
+	statusCtx, stopStatus := context.WithCancel(context.WithoutCancel(cmd.ctx))
+	defer stopStatus()
+
+	go d.watchdog(statusCtx)
+
+	select {
+	case err := <-errs:
+		return nil, fmt.Errorf("could not serve %v", err)
+	case <-cmd.ctx.Done():
+	}
+
+	d.stopping()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(cmd.ctx), shutdownTimeout)
+	defer cancel()
+
+	err = httpServer.Shutdown(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not stop server %v", err)
+	}
+
+	return messageResult{Message: "server stopped"}, nil
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(body)
+
+	if err != nil {
+		log.Printf("Failed to write response %v\n", err.Error())
+	}
+}
+
+// writeError answers with a JSON error whose code is derived from the
+// status, like not_found or bad_request.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{
+		Error: apiErrorDetail{
+			Status:  status,
+			Code:    strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_"),
+			Message: message,
+		},
+	})
+}
+
+// writeInternalError logs err and answers with message only, keeping
+// database details out of responses.
+func writeInternalError(w http.ResponseWriter, message string, err error) {
+	log.Printf("%v %v\n", message, err)
+	writeError(w, http.StatusInternalServerError, message)
+}
+
+// readJSON decodes a request body into v, rejecting unknown fields.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+
+	if err != nil {
+		return fmt.Errorf("invalid request body %v", err)
+	}
+
+	return nil
+}
+
+// authenticated resolves the user of a request from its bearer token.
+func (srv *server) authenticated(handler func(w http.ResponseWriter, r *http.Request, user database.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if !ok || strings.TrimSpace(token) == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		user, err := srv.s.db.GetUserByApiToken(r.Context(), hashToken(strings.TrimSpace(token)))
+
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		if err != nil {
+			writeInternalError(w, "could not check token", err)
+			return
+		}
+
+		handler(w, r, user)
+	}
+}
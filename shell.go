@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+const historyFileName = ".gator_history"
+
+// maxHistory bounds the lines kept in memory and in the history file.
+const maxHistory = 1000
+
+var shellBuiltins = []string{"exit", "quit", "history", "jobs", "kill"}
+
+func shellCommand(c *commands) *commandSpec {
+	return &commandSpec{
+		name:        "shell",
+		description: "Run commands interactively over a single connection. End a line with & to run it in the background.",
+		handler:     handlerShell(c),
+	}
+}
+
+// job is a command line running in the background.
+type job struct {
+	id     int
+	line   string
+	cancel context.CancelFunc
+}
+
+type shell struct {
+	// ctx is the context of the shell command, for work done outside of a
+	// command line, like completion.
+	ctx      context.Context
+	commands *commands
+	state    *state
+	out      io.Writer
+	terminal *term.Terminal
+	history  *history
+
+	mu      sync.Mutex
+	jobs    map[int]*job
+	nextJob int
+}
+
+func handlerShell(c *commands) func(*state, command) (any, error) {
+	return func(s *state, cmd command) (any, error) {
+		sh := &shell{
+			ctx:      cmd.ctx,
+			commands: c,
+			state:    s,
+			out:      os.Stdout,
+			jobs:     make(map[int]*job),
+			nextJob:  1,
+		}
+
+		defer sh.killJobs()
+
+		// Ctrl-C belongs to the command running in the shell, see execute,
+		// and must not cancel the shell itself.
+		signal.Reset(os.Interrupt)
+
+		fd := int(os.Stdin.Fd())
+
+		if !term.IsTerminal(fd) {
+			return nil, sh.runScript(cmd.ctx, os.Stdin)
+		}
+
+		return nil, sh.runInteractive(cmd.ctx, fd)
+	}
+}
+
+// runScript runs the lines of in one after the other, without prompt or line
+// editing, so "gator shell < commands.txt" works.
+func (sh *shell) runScript(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+
+	for scanner.Scan() {
+		if sh.execute(ctx, scanner.Text()) {
+			break
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (sh *shell) runInteractive(ctx context.Context, fd int) error {
+	sh.history = loadHistory()
+
+	sh.terminal = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	sh.terminal.History = sh.history
+	sh.terminal.AutoCompleteCallback = sh.autoComplete
+	sh.out = sh.terminal
+
+	// Background jobs log through the terminal so their output does not
+	// garble the line being edited.
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(sh.terminal)
+
+	fmt.Fprintln(sh.terminal, "gator shell, type \"help\" for commands and \"exit\" to quit.")
+
+	for {
+		line, err := sh.readLine(fd)
+
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(sh.terminal)
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if sh.execute(ctx, line) {
+			return nil
+		}
+	}
+}
+
+// readLine puts the terminal in raw mode only while a line is edited, so the
+// commands themselves see a normal terminal.
+func (sh *shell) readLine(fd int) (string, error) {
+	previous, err := term.MakeRaw(fd)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer term.Restore(fd, previous)
+
+	width, height, err := term.GetSize(fd)
+
+	if err == nil && width > 0 {
+		sh.terminal.SetSize(width, height)
+	}
+
+	sh.terminal.SetPrompt(sh.prompt())
+
+	return sh.terminal.ReadLine()
+}
+
+func (sh *shell) prompt() string {
+	if sh.state.config.CurrentUserName == "" {
+		return "gator> "
+	}
+
+	return fmt.Sprintf("gator (%v)> ", sh.state.config.CurrentUserName)
+}
+
+// execute runs one line and reports whether the shell should exit.
+func (sh *shell) execute(ctx context.Context, line string) bool {
+	line = strings.TrimSpace(line)
+	background := strings.HasSuffix(line, "&") && !strings.HasSuffix(line, "\\&")
+
+	if background {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "&"))
+	}
+
+	args, err := splitWords(line)
+
+	if err != nil {
+		fmt.Fprintln(sh.out, err)
+		return false
+	}
+
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "exit", "quit":
+		return true
+	case "history":
+		sh.printHistory()
+		return false
+	case "jobs":
+		sh.printJobs()
+		return false
+	case "kill":
+		sh.kill(args[1:])
+		return false
+	case "shell":
+		fmt.Fprintln(sh.out, "already in the shell")
+		return false
+	}
+
+	if args[0] == "-h" || args[0] == "--help" {
+		args[0] = "help"
+	}
+
+	if background {
+		sh.startJob(ctx, line, args)
+		return false
+	}
+
+	// Ctrl-C stops the running command, agg included, and returns to the
+	// prompt instead of ending the shell.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	err = sh.commands.run(ctx, sh.state, args, os.Stdout)
+
+	if err != nil {
+		fmt.Fprintln(sh.out, err)
+	}
+
+	return false
+}
+
+func (sh *shell) startJob(ctx context.Context, line string, args []string) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	sh.mu.Lock()
+	j := &job{
+		id:     sh.nextJob,
+		line:   line,
+		cancel: cancel,
+	}
+	sh.jobs[j.id] = j
+	sh.nextJob++
+	sh.mu.Unlock()
+
+	fmt.Fprintf(sh.out, "[%d] %v\n", j.id, j.line)
+
+	go func() {
+		err := sh.commands.run(ctx, sh.state, args, sh.out)
+
+		sh.mu.Lock()
+		delete(sh.jobs, j.id)
+		sh.mu.Unlock()
+
+		if err != nil {
+			fmt.Fprintf(sh.out, "[%d] failed %v: %v\n", j.id, j.line, err)
+			return
+		}
+
+		fmt.Fprintf(sh.out, "[%d] done %v\n", j.id, j.line)
+	}()
+}
+
+func (sh *shell) sortedJobs() []*job {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	jobs := make([]*job, 0, len(sh.jobs))
+
+	for _, j := range sh.jobs {
+		jobs = append(jobs, j)
+	}
+
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].id < jobs[k].id
+	})
+
+	return jobs
+}
+
+func (sh *shell) printJobs() {
+	jobs := sh.sortedJobs()
+
+	if len(jobs) == 0 {
+		fmt.Fprintln(sh.out, "no background jobs")
+		return
+	}
+
+	for _, j := range jobs {
+		fmt.Fprintf(sh.out, "[%d] running %v\n", j.id, j.line)
+	}
+}
+
+func (sh *shell) kill(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(sh.out, "usage: kill <job>...")
+		return
+	}
+
+	for _, arg := range args {
+		id, err := strconv.Atoi(strings.TrimPrefix(arg, "%"))
+
+		sh.mu.Lock()
+		j, ok := sh.jobs[id]
+		sh.mu.Unlock()
+
+		if err != nil || !ok {
+			fmt.Fprintf(sh.out, "no such job %v\n", arg)
+			continue
+		}
+
+		j.cancel()
+	}
+}
+
+func (sh *shell) killJobs() {
+	for _, j := range sh.sortedJobs() {
+		j.cancel()
+	}
+}
+
+func (sh *shell) printHistory() {
+	if sh.history == nil {
+		return
+	}
+
+	for i := sh.history.Len() - 1; i >= 0; i-- {
+		fmt.Fprintf(sh.out, "%5d  %v\n", sh.history.Len()-i, sh.history.At(i))
+	}
+}
+
+// autoComplete completes the word under the cursor on tab, using the same
+// candidates as the shell completion scripts. When several candidates share
+// no longer prefix they are listed above the prompt.
+func (sh *shell) autoComplete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	before := line[:pos]
+	words := strings.Fields(before)
+
+	if len(words) == 0 || strings.HasSuffix(before, " ") {
+		words = append(words, "")
+	}
+
+	current := words[len(words)-1]
+	candidates := sh.commands.complete(sh.ctx, sh.state, words)
+
+	if len(words) == 1 {
+		candidates = append(candidates, matching(shellBuiltins, current)...)
+		sort.Strings(candidates)
+	}
+
+	if len(candidates) == 0 {
+		return line, pos, true
+	}
+
+	completion := candidates[0]
+
+	if len(candidates) == 1 {
+		completion += " "
+	} else {
+		completion = commonPrefix(candidates)
+	}
+
+	if completion == current {
+		fmt.Fprintln(sh.terminal, strings.Join(candidates, "  "))
+		return line, pos, true
+	}
+
+	before = before[:len(before)-len(current)] + completion
+
+	return before + line[pos:], len(before), true
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+// splitWords splits a line into arguments the way a POSIX shell would for
+// plain words, single and double quotes and backslash escapes.
+func splitWords(line string) ([]string, error) {
+	words := []string{}
+
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// history keeps the lines typed in the shell and appends each new one to
+// ~/.gator_history so they survive between sessions.
+type history struct {
+	entries []string
+	path    string
+}
+
+func loadHistory() *history {
+	h := &history{}
+
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return h
+	}
+
+	h.path = filepath.Join(home, historyFileName)
+
+	dat, err := os.ReadFile(h.path)
+
+	if err != nil {
+		return h
+	}
+
+	for _, line := range strings.Split(string(dat), "\n") {
+		if strings.TrimSpace(line) != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		os.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600)
+	}
+
+	return h
+}
+
+func (h *history) Add(entry string) {
+	entry = strings.TrimSpace(entry)
+
+	if entry == "" {
+		return
+	}
+
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return
+	}
+
+	h.entries = append(h.entries, entry)
+
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+
+	if h.path == "" {
+		return
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return
+	}
+
+	defer file.Close()
+
+	fmt.Fprintln(file, entry)
+}
+
+func (h *history) Len() int {
+	return len(h.entries)
+}
+
+// At returns the entry idx lines back, 0 being the most recent one.
+func (h *history) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
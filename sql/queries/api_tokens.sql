@@ -0,0 +1,31 @@
+-- name: CreateApiToken :one
+INSERT INTO api_tokens (id, created_at, updated_at, user_id, name, token_hash)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetApiTokensForUser :many
+SELECT * FROM api_tokens
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: DeleteApiToken :execrows
+DELETE FROM api_tokens
+WHERE user_id = $1
+AND name = $2;
+
+-- name: GetUserByApiToken :one
+WITH used_token AS (
+    UPDATE api_tokens
+    SET last_used_at = NOW()
+    WHERE token_hash = $1
+    RETURNING user_id
+)
+SELECT users.* FROM users
+INNER JOIN used_token ON used_token.user_id = users.id;
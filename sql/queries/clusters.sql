@@ -0,0 +1,43 @@
+-- name: SetPostCluster :exec
+UPDATE posts
+SET simhash = $2, cluster_id = $3
+WHERE id = $1;
+
+-- name: GetClusterCandidates :many
+SELECT id, feed_id, url, simhash, cluster_id FROM posts
+WHERE feed_id <> $1
+AND created_at > $2
+AND simhash IS NOT NULL;
+
+-- name: GetStoriesForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.categories,
+    feeds.name AS feed_name,
+    COALESCE(post_states.starred, FALSE) AS starred,
+    COALESCE(post_states.hidden, FALSE) AS hidden,
+    CAST(COALESCE(post_states.tags, '{}') AS TEXT[]) AS tags,
+    CAST(COALESCE((
+        SELECT string_agg(DISTINCT feeds.name, E'\n')
+        FROM posts AS duplicates
+        INNER JOIN feeds ON feeds.id = duplicates.feed_id
+        INNER JOIN feed_follows AS duplicate_follows ON duplicate_follows.feed_id = duplicates.feed_id
+        AND duplicate_follows.user_id = $1
+        WHERE duplicates.cluster_id = posts.cluster_id
+        AND duplicates.feed_id <> posts.feed_id
+    ), '') AS TEXT) AS also_in
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM posts AS earlier
+    INNER JOIN feed_follows AS earlier_follows ON earlier_follows.feed_id = earlier.feed_id
+    AND earlier_follows.user_id = $1
+    WHERE earlier.cluster_id = posts.cluster_id
+    AND (earlier.created_at, earlier.id) < (posts.created_at, posts.id)
+)
+ORDER BY posts.published_at DESC, posts.id
+LIMIT $2
+OFFSET $3;
@@ -0,0 +1,47 @@
+-- name: SetDigest :one
+INSERT INTO digests (id, created_at, updated_at, user_id, email, frequency, weekday, send_minute, timezone, max_posts, next_send_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
+)
+ON CONFLICT (user_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    email = EXCLUDED.email,
+    frequency = EXCLUDED.frequency,
+    weekday = EXCLUDED.weekday,
+    send_minute = EXCLUDED.send_minute,
+    timezone = EXCLUDED.timezone,
+    max_posts = EXCLUDED.max_posts,
+    next_send_at = EXCLUDED.next_send_at
+RETURNING *;
+
+-- name: GetDigestForUser :one
+SELECT * FROM digests
+WHERE user_id = $1;
+
+-- name: DeleteDigest :execrows
+DELETE FROM digests
+WHERE user_id = $1;
+
+-- name: GetDueDigests :many
+SELECT digests.*, users.name AS user_name
+FROM digests
+INNER JOIN users ON users.id = digests.user_id
+WHERE digests.next_send_at <= $1
+ORDER BY digests.next_send_at;
+
+-- name: MarkDigestSent :exec
+UPDATE digests
+SET updated_at = NOW(),
+    last_sent_at = $2,
+    next_send_at = $3
+WHERE id = $1;
@@ -29,9 +29,47 @@ INNER JOIN users on feed_follows.user_id = $1
 INNER JOIN feeds on feed_follows.feed_id = feeds.id;
 
 
+-- name: DeleteFeedFollow :execrows
+DELETE FROM feed_follows
+WHERE user_id = $1
+AND feed_id = $2;
+
+
 -- name: DeleteFeedFollowForUser :exec
 
 DELETE FROM feed_follows
 USING feeds
-WHERE feed_follows.user_id = $1 
+WHERE feed_follows.feed_id = feeds.id
+AND feed_follows.user_id = $1 
 AND feeds.url = $2;
+
+
+-- name: GetFollowedFeedsForUser :many
+SELECT
+    feeds.id,
+    feeds.name,
+    feeds.url,
+    feeds.site_url,
+    feed_follows.folder,
+    feeds.seq,
+    feeds.last_fetched_at,
+    COALESCE(feeds.favicon, '') <> '' AS has_favicon
+FROM feed_follows
+INNER JOIN feeds on feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follows.folder ASC NULLS FIRST, feeds.name ASC;
+
+
+-- name: SetFeedFollowFolder :exec
+UPDATE feed_follows
+SET folder = $3, updated_at = NOW()
+WHERE user_id = $1
+AND feed_id = $2;
+
+
+-- name: GetFaviconsForUser :many
+SELECT feeds.seq, feeds.favicon FROM feed_follows
+INNER JOIN feeds on feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+AND feeds.favicon <> ''
+ORDER BY feeds.seq;
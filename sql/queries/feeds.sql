@@ -44,6 +44,42 @@ WHERE id = $1;
 
 
 -- name: GetNextFeedToFetch :one
-SELECT id, last_fetched_at , url  FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1;
\ No newline at end of file
+SELECT feeds.id, feeds.created_at, feeds.last_fetched_at, feeds.url, feeds.fetch_full_content, feeds.name, feeds.favicon IS NOT NULL AS has_favicon
+FROM feeds
+LEFT JOIN websub_subscriptions ON websub_subscriptions.feed_id = feeds.id
+AND websub_subscriptions.state = 'active'
+AND websub_subscriptions.lease_expires_at > NOW()
+WHERE websub_subscriptions.id IS NULL
+OR feeds.last_fetched_at IS NULL
+OR feeds.last_fetched_at < NOW() - INTERVAL '1 day'
+ORDER BY feeds.last_fetched_at ASC NULLS FIRST
+LIMIT 1;
+
+
+-- name: SetFeedSiteUrl :exec
+UPDATE feeds
+SET site_url = $2, updated_at = NOW()
+WHERE id = $1;
+
+
+-- name: SetFeedFetchFullContent :exec
+UPDATE feeds
+SET fetch_full_content = $2, updated_at = NOW()
+WHERE id = $1;
+
+
+-- name: SetFeedFavicon :exec
+UPDATE feeds
+SET favicon = $2, updated_at = NOW()
+WHERE id = $1;
+
+
+-- name: GetFeedUrls :many
+SELECT id, url FROM feeds
+ORDER BY seq;
+
+
+-- name: SetFeedUrl :exec
+UPDATE feeds
+SET url = $2, updated_at = NOW()
+WHERE id = $1;
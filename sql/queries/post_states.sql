@@ -0,0 +1,118 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, read_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, NOW()), updated_at = NOW();
+
+-- name: MarkPostUnread :exec
+UPDATE post_states
+SET read_at = NULL, updated_at = NOW()
+WHERE user_id = $1
+AND post_id = $2;
+
+-- name: SetPostStarred :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, starred)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred = EXCLUDED.starred, updated_at = NOW();
+
+-- name: GetFeedPostsForUser :many
+SELECT
+    posts.*,
+    post_states.read_at,
+    COALESCE(post_states.starred, FALSE) AS starred
+FROM posts
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = $1
+WHERE posts.feed_id = $2
+ORDER BY posts.published_at DESC NULLS LAST
+LIMIT $3;
+
+-- name: GetPostState :one
+SELECT * FROM post_states
+WHERE user_id = $1
+AND post_id = $2;
+
+-- name: GetUnreadCountsForUser :many
+SELECT
+    posts.feed_id,
+    COUNT(*) AS unread
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE post_states.read_at IS NULL
+AND NOT COALESCE(post_states.hidden, FALSE)
+GROUP BY posts.feed_id;
+
+-- name: SetPostHidden :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, hidden)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET hidden = EXCLUDED.hidden, updated_at = NOW();
+
+-- name: AddPostTags :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, tags)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET tags = ARRAY(
+    SELECT DISTINCT tag FROM unnest(post_states.tags || EXCLUDED.tags) AS tag
+    ORDER BY tag
+), updated_at = NOW();
+
+-- name: MarkPostsReadBefore :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, read_at)
+SELECT gen_random_uuid(), NOW(), NOW(), sqlc.arg(user_id), posts.id, NOW()
+FROM posts
+WHERE posts.feed_id = ANY(sqlc.arg(feed_ids)::uuid[])
+AND COALESCE(posts.published_at, posts.created_at) <= sqlc.arg(before)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, NOW()), updated_at = NOW();
+
+-- name: GetUnreadPostSeqsForUser :many
+SELECT posts.seq FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE post_states.read_at IS NULL
+AND NOT COALESCE(post_states.hidden, FALSE)
+ORDER BY posts.seq;
+
+-- name: GetStarredPostSeqsForUser :many
+SELECT posts.seq FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+INNER JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE post_states.starred
+ORDER BY posts.seq;
@@ -1,5 +1,5 @@
 -- name: CreatePost :one
-INSERT INTO posts ( id,created_at,updated_at,title,url,description,published_at,feed_id)
+INSERT INTO posts ( id,created_at,updated_at,title,url,description,published_at,feed_id,author,categories,source_url)
 VALUES (
  $1,
     $2,
@@ -8,11 +8,164 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11
 )
 RETURNING *;
 
 -- name: GetPostsForUser :many
 SELECT * FROM posts 
 ORDER BY published_at DESC
-LIMIT $1;
\ No newline at end of file
+LIMIT $1;
+
+-- name: DeletePost :exec
+DELETE FROM posts WHERE id = $1;
+
+-- name: GetPostBySeq :one
+SELECT posts.*, feeds.name AS feed_name FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+WHERE posts.seq = $1;
+
+-- name: GetPostBySeqForUser :one
+SELECT posts.*, feeds.name AS feed_name FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $2
+WHERE posts.seq = $1;
+
+-- name: SetPostContent :exec
+UPDATE posts
+SET content = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListPostsForUser :many
+SELECT
+    posts.*,
+    feeds.name AS feed_name,
+    post_states.read_at,
+    COALESCE(post_states.starred, FALSE) AS starred,
+    COALESCE(post_states.hidden, FALSE) AS hidden,
+    CAST(COALESCE(post_states.tags, '{}') AS TEXT[]) AS tags
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = sqlc.arg(user_id)
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+AND (NOT sqlc.arg(unread_only)::BOOLEAN OR post_states.read_at IS NULL)
+AND (NOT sqlc.arg(starred_only)::BOOLEAN OR COALESCE(post_states.starred, FALSE))
+AND (sqlc.narg(tag)::TEXT IS NULL OR sqlc.narg(tag) = ANY(post_states.tags))
+AND (sqlc.arg(include_hidden)::BOOLEAN OR NOT COALESCE(post_states.hidden, FALSE))
+ORDER BY posts.published_at DESC NULLS LAST, posts.seq DESC
+LIMIT sqlc.arg(max_posts)
+OFFSET sqlc.arg(skip_posts);
+
+-- name: ListPostsBySeqForUser :many
+SELECT
+    posts.*,
+    feeds.seq AS feed_seq,
+    post_states.read_at,
+    COALESCE(post_states.starred, FALSE) AS starred
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = sqlc.arg(user_id)
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE (sqlc.narg(since_seq)::BIGINT IS NULL OR posts.seq > sqlc.narg(since_seq))
+AND (sqlc.narg(max_seq)::BIGINT IS NULL OR posts.seq < sqlc.narg(max_seq))
+AND (cardinality(sqlc.arg(seqs)::BIGINT[]) = 0 OR posts.seq = ANY(sqlc.arg(seqs)::BIGINT[]))
+AND NOT COALESCE(post_states.hidden, FALSE)
+ORDER BY
+    CASE WHEN sqlc.narg(max_seq)::BIGINT IS NULL THEN posts.seq END ASC,
+    posts.seq DESC
+LIMIT sqlc.arg(max_posts);
+
+-- name: CountPostsForUser :one
+SELECT COUNT(*) FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = $1
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE NOT COALESCE(post_states.hidden, FALSE);
+
+-- name: ListStreamPostsForUser :many
+SELECT
+    posts.*,
+    feeds.seq AS feed_seq,
+    feeds.name AS feed_name,
+    feeds.site_url AS feed_site_url,
+    feeds.url AS feed_url,
+    feed_follows.folder,
+    post_states.read_at,
+    COALESCE(post_states.starred, FALSE) AS starred
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = sqlc.arg(user_id)
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+AND (sqlc.narg(folder)::TEXT IS NULL OR feed_follows.folder = sqlc.narg(folder))
+AND (NOT sqlc.arg(unread_only)::BOOLEAN OR post_states.read_at IS NULL)
+AND (NOT sqlc.arg(starred_only)::BOOLEAN OR COALESCE(post_states.starred, FALSE))
+AND (sqlc.narg(newer_than)::TIMESTAMP IS NULL OR posts.created_at > sqlc.narg(newer_than))
+AND (sqlc.narg(older_than)::TIMESTAMP IS NULL OR posts.created_at < sqlc.narg(older_than))
+AND (cardinality(sqlc.arg(seqs)::BIGINT[]) = 0 OR posts.seq = ANY(sqlc.arg(seqs)::BIGINT[]))
+AND NOT COALESCE(post_states.hidden, FALSE)
+ORDER BY
+    CASE WHEN sqlc.arg(oldest_first)::BOOLEAN THEN posts.published_at END ASC,
+    CASE WHEN sqlc.arg(oldest_first)::BOOLEAN THEN posts.seq END ASC,
+    posts.published_at DESC NULLS LAST,
+    posts.seq DESC
+LIMIT sqlc.arg(max_posts)
+OFFSET sqlc.arg(skip_posts);
+
+-- name: ListStreamPostSeqsForUser :many
+SELECT posts.seq
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+AND feed_follows.user_id = sqlc.arg(user_id)
+LEFT JOIN post_states ON post_states.post_id = posts.id
+AND post_states.user_id = feed_follows.user_id
+WHERE (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+AND (sqlc.narg(folder)::TEXT IS NULL OR feed_follows.folder = sqlc.narg(folder))
+AND (NOT sqlc.arg(unread_only)::BOOLEAN OR post_states.read_at IS NULL)
+AND (NOT sqlc.arg(starred_only)::BOOLEAN OR COALESCE(post_states.starred, FALSE))
+AND (sqlc.narg(newer_than)::TIMESTAMP IS NULL OR posts.created_at > sqlc.narg(newer_than))
+AND (sqlc.narg(older_than)::TIMESTAMP IS NULL OR posts.created_at < sqlc.narg(older_than))
+AND NOT COALESCE(post_states.hidden, FALSE)
+ORDER BY
+    CASE WHEN sqlc.arg(oldest_first)::BOOLEAN THEN posts.published_at END ASC,
+    CASE WHEN sqlc.arg(oldest_first)::BOOLEAN THEN posts.seq END ASC,
+    posts.published_at DESC NULLS LAST,
+    posts.seq DESC
+LIMIT sqlc.arg(max_posts)
+OFFSET sqlc.arg(skip_posts);
+
+-- name: GetPostUrlsAfter :many
+SELECT seq, id, url FROM posts
+WHERE seq > $1
+ORDER BY seq
+LIMIT $2;
+
+-- name: SetPostUrl :exec
+UPDATE posts
+SET url = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: PostSourceExists :one
+SELECT EXISTS (
+    SELECT 1 FROM posts
+    WHERE feed_id = $1
+    AND source_url = $2
+);
+
+-- name: SetPostSourceUrl :exec
+UPDATE posts
+SET source_url = $3
+WHERE feed_id = $1
+AND url = $2;
@@ -0,0 +1,35 @@
+-- name: CreatePublishedFeed :one
+INSERT INTO published_feeds (id, created_at, updated_at, user_id, name, token, folder, rule_id, max_posts)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+RETURNING *;
+
+-- name: GetPublishedFeedsForUser :many
+SELECT * FROM published_feeds
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: GetPublishedFeed :one
+SELECT * FROM published_feeds
+WHERE user_id = $1
+AND name = $2;
+
+-- name: GetPublishedFeedByToken :one
+SELECT published_feeds.*, users.name AS user_name
+FROM published_feeds
+INNER JOIN users ON users.id = published_feeds.user_id
+WHERE published_feeds.token = $1;
+
+-- name: DeletePublishedFeed :execrows
+DELETE FROM published_feeds
+WHERE user_id = $1
+AND name = $2;
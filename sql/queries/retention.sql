@@ -0,0 +1,88 @@
+-- name: CreateRetentionPolicy :one
+INSERT INTO retention_policies (id, created_at, updated_at, feed_id, keep_last, max_age_days, keep_starred, keep_unread)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: DeleteRetentionPolicy :exec
+DELETE FROM retention_policies
+WHERE feed_id IS NOT DISTINCT FROM $1;
+
+-- name: GetRetentionPolicies :many
+SELECT
+    retention_policies.*,
+    feeds.url AS feed_url
+FROM retention_policies
+LEFT JOIN feeds ON retention_policies.feed_id = feeds.id
+ORDER BY feeds.url ASC NULLS FIRST;
+
+-- name: GetExpiredPosts :many
+WITH ranked AS (
+    SELECT
+        posts.id,
+        posts.feed_id,
+        posts.title,
+        posts.url,
+        posts.published_at,
+        ROW_NUMBER() OVER (
+            PARTITION BY posts.feed_id
+            ORDER BY posts.published_at DESC NULLS LAST, posts.created_at DESC
+        ) AS position
+    FROM posts
+    WHERE EXISTS (
+        SELECT 1 FROM retention_policies
+        WHERE retention_policies.feed_id = posts.feed_id
+        OR retention_policies.feed_id IS NULL
+    )
+)
+SELECT
+    ranked.id,
+    ranked.feed_id,
+    ranked.title,
+    ranked.url,
+    ranked.published_at
+FROM ranked
+INNER JOIN LATERAL (
+    SELECT
+        retention_policies.keep_last,
+        retention_policies.max_age_days,
+        retention_policies.keep_starred,
+        retention_policies.keep_unread
+    FROM retention_policies
+    WHERE retention_policies.feed_id = ranked.feed_id
+    OR retention_policies.feed_id IS NULL
+    ORDER BY retention_policies.feed_id NULLS LAST
+    LIMIT 1
+) AS policy ON TRUE
+WHERE (
+    ranked.position > policy.keep_last
+    OR ranked.published_at < NOW() - make_interval(days => policy.max_age_days)
+)
+AND NOT (policy.keep_starred AND EXISTS (
+    SELECT 1 FROM post_states
+    WHERE post_states.post_id = ranked.id
+    AND post_states.starred
+))
+AND NOT (policy.keep_unread AND EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = ranked.feed_id
+    AND NOT EXISTS (
+        SELECT 1 FROM post_states
+        WHERE post_states.post_id = ranked.id
+        AND post_states.user_id = feed_follows.user_id
+        AND post_states.read_at IS NOT NULL
+    )
+))
+ORDER BY ranked.feed_id, ranked.position;
+
+-- name: DeletePosts :exec
+DELETE FROM posts
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
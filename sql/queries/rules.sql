@@ -0,0 +1,31 @@
+-- name: CreateRule :one
+INSERT INTO rules (id, created_at, updated_at, user_id, field, pattern, regex, exclude, action, tag)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+RETURNING *;
+
+-- name: GetRulesForUser :many
+SELECT * FROM rules
+WHERE user_id = $1
+ORDER BY seq;
+
+-- name: GetRulesForFeed :many
+SELECT rules.* FROM rules
+INNER JOIN feed_follows ON feed_follows.user_id = rules.user_id
+AND feed_follows.feed_id = $1
+ORDER BY rules.seq;
+
+-- name: DeleteRule :execrows
+DELETE FROM rules
+WHERE user_id = $1
+AND seq = $2;
@@ -0,0 +1,23 @@
+-- name: CreateSession :exec
+INSERT INTO sessions (id, created_at, token_hash, user_id, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: GetUserBySession :one
+SELECT users.* FROM users
+INNER JOIN sessions ON sessions.user_id = users.id
+WHERE sessions.token_hash = $1
+AND sessions.expires_at > NOW();
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions
+WHERE expires_at <= NOW();
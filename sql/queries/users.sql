@@ -15,4 +15,12 @@ SELECT * FROM users WHERE name=$1;
 DELETE FROM users;
 
 -- name: GetUsers :many
-SELECT * FROM users;
\ No newline at end of file
+SELECT * FROM users;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, fever_api_key = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetUserByFeverApiKey :one
+SELECT * FROM users WHERE fever_api_key = $1;
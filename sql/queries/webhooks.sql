@@ -0,0 +1,91 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, name, url, secret, events, feed_id, rule_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT webhooks.*, feeds.url AS feed_url
+FROM webhooks
+LEFT JOIN feeds ON feeds.id = webhooks.feed_id
+WHERE webhooks.user_id = $1
+ORDER BY webhooks.name;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE user_id = $1
+AND name = $2;
+
+-- name: GetWebhooksForFeed :many
+SELECT webhooks.* FROM webhooks
+INNER JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+AND feed_follows.feed_id = sqlc.arg(feed_id)
+WHERE (webhooks.feed_id IS NULL OR webhooks.feed_id = sqlc.arg(feed_id))
+AND sqlc.arg(event)::TEXT = ANY(webhooks.events)
+ORDER BY webhooks.created_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE user_id = $1
+AND name = $2;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, event, payload, next_attempt_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(claimed_until)
+FROM webhooks
+WHERE webhooks.id = webhook_deliveries.webhook_id
+AND webhook_deliveries.id IN (
+    SELECT id FROM webhook_deliveries AS due
+    WHERE due.next_attempt_at <= NOW()
+    ORDER BY due.next_attempt_at
+    LIMIT sqlc.arg(max_deliveries)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING
+    webhook_deliveries.*,
+    webhooks.url,
+    webhooks.secret;
+
+-- name: SetWebhookDeliveryResult :exec
+UPDATE webhook_deliveries
+SET updated_at = NOW(),
+    attempts = $2,
+    status_code = $3,
+    last_error = $4,
+    delivered_at = $5,
+    next_attempt_at = $6
+WHERE id = $1;
+
+-- name: GetWebhookDeliveriesForUser :many
+SELECT
+    webhook_deliveries.*,
+    webhooks.name AS webhook_name
+FROM webhook_deliveries
+INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+WHERE webhooks.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(webhook_name)::TEXT IS NULL OR webhooks.name = sqlc.narg(webhook_name))
+ORDER BY webhook_deliveries.created_at DESC
+LIMIT sqlc.arg(max_deliveries);
@@ -0,0 +1,63 @@
+-- name: SetWebSubHub :exec
+INSERT INTO websub_subscriptions (id, created_at, updated_at, feed_id, hub_url, topic_url, callback_token, secret)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+ON CONFLICT (feed_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    hub_url = EXCLUDED.hub_url,
+    topic_url = EXCLUDED.topic_url,
+    state = 'new',
+    lease_expires_at = NULL
+WHERE websub_subscriptions.hub_url <> EXCLUDED.hub_url
+OR websub_subscriptions.topic_url <> EXCLUDED.topic_url;
+
+-- name: DeleteWebSubSubscription :exec
+DELETE FROM websub_subscriptions
+WHERE feed_id = $1;
+
+-- name: GetWebSubSubscriptionsToRenew :many
+SELECT * FROM websub_subscriptions
+WHERE state = 'new'
+OR (state = 'active' AND lease_expires_at < NOW() + INTERVAL '1 day')
+OR (state NOT IN ('new', 'active') AND updated_at < NOW() - INTERVAL '1 hour')
+ORDER BY updated_at
+LIMIT $1;
+
+-- name: GetWebSubSubscriptionByToken :one
+SELECT
+    websub_subscriptions.*,
+    feeds.url AS feed_url,
+    feeds.name AS feed_name,
+    feeds.fetch_full_content,
+    feeds.favicon IS NOT NULL AS has_favicon
+FROM websub_subscriptions
+INNER JOIN feeds ON feeds.id = websub_subscriptions.feed_id
+WHERE websub_subscriptions.callback_token = $1;
+
+-- name: SetWebSubState :exec
+UPDATE websub_subscriptions
+SET updated_at = NOW(),
+    state = $2,
+    last_error = $3
+WHERE id = $1;
+
+-- name: ActivateWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET updated_at = NOW(),
+    state = 'active',
+    lease_expires_at = NOW() + sqlc.arg(lease_seconds)::INTEGER * INTERVAL '1 second',
+    last_error = NULL
+WHERE id = sqlc.arg(id);
+
+-- name: MarkWebSubPushed :exec
+UPDATE websub_subscriptions
+SET last_push_at = NOW()
+WHERE id = $1;
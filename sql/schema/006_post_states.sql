@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE post_states (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id uuid NOT NULL,
+    post_id uuid NOT NULL,
+    read_at TIMESTAMP,
+    starred BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (user_id, post_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE post_states;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE retention_policies (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    feed_id uuid UNIQUE,
+    keep_last INTEGER,
+    max_age_days INTEGER,
+    keep_starred BOOLEAN NOT NULL DEFAULT TRUE,
+    keep_unread BOOLEAN NOT NULL DEFAULT TRUE,
+    FOREIGN KEY (feed_id) REFERENCES feeds (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE retention_policies;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feed_follows
+ADD folder VARCHAR;
+
+-- +goose Down
+ALTER TABLE feed_follows
+DROP COLUMN folder;
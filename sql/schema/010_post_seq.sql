@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts
+ADD seq BIGSERIAL UNIQUE;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN seq;
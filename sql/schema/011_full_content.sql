@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD fetch_full_content BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE posts
+ADD content TEXT;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN content;
+
+ALTER TABLE feeds
+DROP COLUMN fetch_full_content;
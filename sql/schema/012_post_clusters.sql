@@ -0,0 +1,46 @@
+-- +goose Up
+ALTER TABLE posts
+DROP CONSTRAINT posts_url_key;
+
+ALTER TABLE posts
+ADD CONSTRAINT posts_feed_id_url_key UNIQUE (feed_id, url);
+
+ALTER TABLE posts
+ADD simhash BIGINT;
+
+ALTER TABLE posts
+ADD cluster_id uuid;
+
+UPDATE posts SET cluster_id = id;
+
+CREATE INDEX posts_cluster_id_idx ON posts (cluster_id);
+
+-- +goose Down
+-- Since Up, posts of different feeds can share a url, which posts_url_key
+-- does not allow. Rather than deleting posts to restore it, Down stops when
+-- any url is stored more than once; remove those posts to roll back. Post
+-- fingerprints and clusters are dropped, so after migrating up again older
+-- posts each start in a cluster of their own.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM posts GROUP BY url HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'posts of several feeds share a url, remove the duplicates before rolling back';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+DROP INDEX posts_cluster_id_idx;
+
+ALTER TABLE posts
+DROP COLUMN cluster_id;
+
+ALTER TABLE posts
+DROP COLUMN simhash;
+
+ALTER TABLE posts
+DROP CONSTRAINT posts_feed_id_url_key;
+
+ALTER TABLE posts
+ADD CONSTRAINT posts_url_key UNIQUE (url);
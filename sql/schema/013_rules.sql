@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE posts
+ADD author TEXT,
+ADD categories TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE post_states
+ADD hidden BOOLEAN NOT NULL DEFAULT FALSE,
+ADD tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE rules (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    seq BIGSERIAL NOT NULL UNIQUE,
+    user_id uuid NOT NULL,
+    field VARCHAR NOT NULL,
+    pattern TEXT NOT NULL,
+    regex BOOLEAN NOT NULL DEFAULT FALSE,
+    exclude BOOLEAN NOT NULL DEFAULT FALSE,
+    action VARCHAR NOT NULL,
+    tag VARCHAR,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE rules;
+
+ALTER TABLE post_states
+DROP COLUMN tags,
+DROP COLUMN hidden;
+
+ALTER TABLE posts
+DROP COLUMN categories,
+DROP COLUMN author;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE api_tokens (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id uuid NOT NULL,
+    name VARCHAR NOT NULL,
+    token_hash VARCHAR NOT NULL UNIQUE,
+    last_used_at TIMESTAMP,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE api_tokens;
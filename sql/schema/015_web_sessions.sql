@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users
+ADD password_hash VARCHAR;
+
+CREATE TABLE sessions (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    token_hash VARCHAR NOT NULL UNIQUE,
+    user_id uuid NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE sessions;
+
+ALTER TABLE users
+DROP COLUMN password_hash;
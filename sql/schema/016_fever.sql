@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD seq BIGSERIAL UNIQUE;
+
+ALTER TABLE feeds
+ADD favicon TEXT;
+
+ALTER TABLE users
+ADD fever_api_key VARCHAR UNIQUE;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN fever_api_key;
+
+ALTER TABLE feeds
+DROP COLUMN favicon;
+
+ALTER TABLE feeds
+DROP COLUMN seq;
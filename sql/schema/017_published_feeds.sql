@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE published_feeds (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id uuid NOT NULL,
+    name VARCHAR NOT NULL,
+    token VARCHAR NOT NULL UNIQUE,
+    folder VARCHAR,
+    rule_id uuid,
+    max_posts INTEGER NOT NULL DEFAULT 50,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (rule_id) REFERENCES rules (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE published_feeds;
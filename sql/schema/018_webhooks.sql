@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE webhooks (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id uuid NOT NULL,
+    name VARCHAR NOT NULL,
+    url VARCHAR NOT NULL,
+    secret VARCHAR NOT NULL,
+    events TEXT[] NOT NULL,
+    feed_id uuid,
+    rule_id uuid,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (feed_id) REFERENCES feeds (id) ON DELETE CASCADE,
+    FOREIGN KEY (rule_id) REFERENCES rules (id) ON DELETE CASCADE
+);
+
+CREATE TABLE webhook_deliveries (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    webhook_id uuid NOT NULL,
+    event VARCHAR NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP,
+    delivered_at TIMESTAMP,
+    status_code INTEGER,
+    last_error TEXT,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_next_attempt_at_idx ON webhook_deliveries (next_attempt_at)
+WHERE next_attempt_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE digests (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id uuid NOT NULL UNIQUE,
+    email VARCHAR NOT NULL,
+    frequency VARCHAR NOT NULL,
+    weekday INTEGER NOT NULL DEFAULT 1,
+    send_minute INTEGER NOT NULL,
+    timezone VARCHAR NOT NULL DEFAULT 'UTC',
+    max_posts INTEGER NOT NULL DEFAULT 50,
+    last_sent_at TIMESTAMP,
+    next_send_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE digests;
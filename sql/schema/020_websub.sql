@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE websub_subscriptions (
+    id uuid PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    feed_id uuid NOT NULL UNIQUE,
+    hub_url VARCHAR NOT NULL,
+    topic_url VARCHAR NOT NULL,
+    callback_token VARCHAR NOT NULL UNIQUE,
+    secret VARCHAR NOT NULL,
+    state VARCHAR NOT NULL DEFAULT 'new',
+    lease_expires_at TIMESTAMP,
+    last_push_at TIMESTAMP,
+    last_error TEXT,
+    FOREIGN KEY (feed_id) REFERENCES feeds (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
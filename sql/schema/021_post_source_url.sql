@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE posts
+ADD source_url TEXT;
+
+CREATE INDEX posts_feed_id_source_url_idx ON posts (feed_id, source_url)
+WHERE source_url IS NOT NULL;
+
+-- +goose Down
+DROP INDEX posts_feed_id_source_url_idx;
+
+ALTER TABLE posts
+DROP COLUMN source_url;
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+// apiTokenPrefix makes tokens recognizable in configs and secret scanners.
+const apiTokenPrefix = "gator_"
+
+var tokenCommand = &commandSpec{
+	name:        "token",
+	description: "List your API tokens for gator serve.",
+	handler:     middlewareLoggedIn(handlerTokens),
+	subcommands: []*commandSpec{
+		{
+			name:        "create",
+			description: "Create an API token. It is only shown once.",
+			args:        []string{"<name>"},
+			handler:     middlewareLoggedIn(handlerTokenCreate),
+		},
+		{
+			name:        "revoke",
+			description: "Revoke an API token by name.",
+			args:        []string{"<name>"},
+			handler:     middlewareLoggedIn(handlerTokenRevoke),
+		},
+	},
+}
+
+type tokenResult struct {
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func (r tokenResult) Text() string {
+	if r.Token != "" {
+		return fmt.Sprintf("Token %v created, store it now as it will not be shown again:\n%v", r.Name, r.Token)
+	}
+
+	lastUsed := "never used"
+
+	if r.LastUsedAt != nil {
+		lastUsed = "last used " + r.LastUsedAt.Format(time.DateTime)
+	}
+
+	return fmt.Sprintf("- %v (created %v, %v)", r.Name, r.CreatedAt.Format(time.DateOnly), lastUsed)
+}
+
+type tokensResult []tokenResult
+
+func (r tokensResult) Text() string {
+	if len(r) == 0 {
+		return "No API tokens, create one with \"gator token create <name>\"."
+	}
+
+	var text strings.Builder
+
+	for _, token := range r {
+		fmt.Fprintln(&text, token.Text())
+	}
+
+	return text.String()
+}
+
+func handlerTokens(s *state, cmd command, user database.User) (any, error) {
+	tokens, err := s.db.GetApiTokensForUser(cmd.ctx, user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get tokens %v", err)
+	}
+
+	result := make(tokensResult, 0, len(tokens))
+
+	for _, token := range tokens {
+		var lastUsed *time.Time
+
+		if token.LastUsedAt.Valid {
+			lastUsed = &token.LastUsedAt.Time
+		}
+
+		result = append(result, tokenResult{
+			Name:       token.Name,
+			CreatedAt:  token.CreatedAt,
+			LastUsedAt: lastUsed,
+		})
+	}
+
+	return result, nil
+}
+
+func handlerTokenCreate(s *state, cmd command, user database.User) (any, error) {
+	token, err := newApiToken()
+
+	if err != nil {
+		return nil, fmt.Errorf("could not generate token %v", err)
+	}
+
+	created, err := s.db.CreateApiToken(cmd.ctx, database.CreateApiTokenParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      cmd.arguments[0],
+		TokenHash: hashToken(token),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create token, is the name already used? %v", err)
+	}
+
+	return tokenResult{
+		Name:      created.Name,
+		CreatedAt: created.CreatedAt,
+		Token:     token,
+	}, nil
+}
+
+func handlerTokenRevoke(s *state, cmd command, user database.User) (any, error) {
+	revoked, err := s.db.DeleteApiToken(cmd.ctx, database.DeleteApiTokenParams{
+		UserID: user.ID,
+		Name:   cmd.arguments[0],
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not revoke token %v", err)
+	}
+
+	if revoked == 0 {
+		return nil, fmt.Errorf("no token named %v", cmd.arguments[0])
+	}
+
+	return messageResult{Message: fmt.Sprintf("token %v revoked", cmd.arguments[0])}, nil
+}
+
+func newApiToken() (string, error) {
+	secret, err := randomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	return apiTokenPrefix + secret, nil
+}
+
+// randomToken returns 32 random bytes in hex.
+func randomToken() (string, error) {
+	secret := make([]byte, 32)
+
+	_, err := rand.Read(secret)
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// hashToken is what is stored of an API or session token: enough to
+// recognize it, not to use it.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/canonical"
+	"github.com/mambo-dev/gator/internal/database"
+)
+
+// redirectorTimeout bounds following one redirector link, so a slow
+// redirector cannot stall storing the rest of its feed.
+const redirectorTimeout = 10 * time.Second
+
+var redirectorClient = &http.Client{Timeout: redirectorTimeout}
+
+func canonicalizer(s *state) *canonical.Canonicalizer {
+	c := canonical.New(s.config.TrackingParams)
+	c.Client = redirectorClient
+
+	return c
+}
+
+// canonicalUrl normalizes a post or feed url, following feed redirectors.
+// Urls that cannot be parsed are returned as they are.
+func canonicalUrl(ctx context.Context, s *state, raw string) string {
+	canonicalized, err := canonicalizer(s).Canonicalize(ctx, raw)
+
+	if err != nil || canonicalized == "" {
+		return raw
+	}
+
+	return canonicalized
+}
+
+// canonicalizeBatchSize is how many posts canonicalize reads at a time.
+const canonicalizeBatchSize = 500
+
+var canonicalizeCommand = &commandSpec{
+	name:        "canonicalize",
+	description: "Rewrite stored feed and post urls in canonical form, so that feeds added before urls were canonicalized can be named by any form of their url and new posts are deduplicated against old ones. Redirector links are not followed. Urls whose canonical form is already taken are left as they are.",
+	flags: func(flags *flag.FlagSet) {
+		flags.Bool("dry-run", false, "report what would be rewritten without changing anything")
+	},
+	handler: handlerCanonicalize,
+}
+
+type canonicalizedUrl struct {
+	Kind   string `json:"kind"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status string `json:"status"`
+}
+
+type canonicalizeResult struct {
+	DryRun bool               `json:"dry_run"`
+	Urls   []canonicalizedUrl `json:"urls"`
+}
+
+func (r canonicalizeResult) Text() string {
+	var text strings.Builder
+
+	counts := map[string]int{}
+
+	for _, rewritten := range r.Urls {
+		counts[rewritten.Kind+" "+rewritten.Status]++
+
+		if rewritten.Status == "conflict" {
+			fmt.Fprintf(&text, "- %v %v is already stored as %v, left as it is\n", rewritten.Kind, rewritten.From, rewritten.To)
+		}
+	}
+
+	verb := "Rewrote"
+
+	if r.DryRun {
+		verb = "Would rewrite"
+	}
+
+	fmt.Fprintf(&text, "%v %d feed urls and %d post urls, %d conflicts\n", verb,
+		counts["feed rewritten"], counts["post rewritten"], counts["feed conflict"]+counts["post conflict"])
+
+	return text.String()
+}
+
+func (r canonicalizeResult) Records() any {
+	return r.Urls
+}
+
+func handlerCanonicalize(s *state, cmd command) (any, error) {
+	result := canonicalizeResult{
+		DryRun: cmd.boolFlag("dry-run"),
+		Urls:   []canonicalizedUrl{},
+	}
+
+	c := canonicalizer(s)
+
+	feeds, err := s.db.GetFeedUrls(cmd.ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get feeds %v", err)
+	}
+
+	for _, feed := range feeds {
+		cleaned, err := c.Clean(feed.Url)
+
+		if err != nil || cleaned == feed.Url {
+			continue
+		}
+
+		status := "rewritten"
+
+		if !result.DryRun {
+			err = s.db.SetFeedUrl(cmd.ctx, database.SetFeedUrlParams{
+				ID:  feed.ID,
+				Url: cleaned,
+			})
+
+			if isUniqueViolation(err) {
+				status = "conflict"
+			} else if err != nil {
+				return nil, fmt.Errorf("could not rewrite feed url %v", err)
+			}
+		}
+
+		result.Urls = append(result.Urls, canonicalizedUrl{Kind: "feed", From: feed.Url, To: cleaned, Status: status})
+	}
+
+	var after int64
+
+	for {
+		posts, err := s.db.GetPostUrlsAfter(cmd.ctx, database.GetPostUrlsAfterParams{
+			Seq:   after,
+			Limit: canonicalizeBatchSize,
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get posts %v", err)
+		}
+
+		for _, post := range posts {
+			after = post.Seq
+
+			cleaned, err := c.Clean(post.Url)
+
+			if err != nil || cleaned == post.Url {
+				continue
+			}
+
+			status := "rewritten"
+
+			if !result.DryRun {
+				err = s.db.SetPostUrl(cmd.ctx, database.SetPostUrlParams{
+					ID:  post.ID,
+					Url: cleaned,
+				})
+
+				if isUniqueViolation(err) {
+					status = "conflict"
+				} else if err != nil {
+					return nil, fmt.Errorf("could not rewrite post url %v", err)
+				}
+			}
+
+			result.Urls = append(result.Urls, canonicalizedUrl{Kind: "post", From: post.Url, To: cleaned, Status: status})
+		}
+
+		if len(posts) < canonicalizeBatchSize {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// redirectedItemUrl returns the cleaned link of a feed item that goes
+// through a feed redirector, to be stored with its post, and whether the
+// feed already has a post for it. Known links are not followed again, which
+// saves a request per item on every scrape.
+func redirectedItemUrl(ctx context.Context, s *state, feedID uuid.UUID, link string) (sql.NullString, bool) {
+	cleaned, err := canonicalizer(s).Clean(link)
+
+	if err != nil || !canonical.Redirects(cleaned) {
+		return sql.NullString{}, false
+	}
+
+	sourceUrl := sql.NullString{
+		String: cleaned,
+		Valid:  true,
+	}
+
+	exists, err := s.db.PostSourceExists(ctx, database.PostSourceExistsParams{
+		FeedID:    feedID,
+		SourceUrl: sourceUrl,
+	})
+
+	if err != nil {
+		log.Printf("Failed to look up post source url %v\n", err.Error())
+	}
+
+	return sourceUrl, exists
+}
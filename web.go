@@ -0,0 +1,764 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/htmltext"
+	"github.com/mambo-dev/gator/internal/password"
+	"github.com/mambo-dev/gator/internal/sanitize"
+)
+
+//go:embed web/templates/*.html web/static
+var webFiles embed.FS
+
+const (
+	sessionCookie   = "gator_session"
+	sessionDuration = 30 * 24 * time.Hour
+	webPageSize     = 30
+	excerptLength   = 240
+)
+
+// webPage is the data every page template renders. Pages only fill in the
+// fields they show.
+type webPage struct {
+	Title   string
+	User    string
+	CSRF    string
+	Path    string
+	Error   string
+	Folders []webFolder
+
+	// login
+	Name string
+
+	// posts
+	Heading  string
+	Posts    []webPostItem
+	PrevPage string
+	NextPage string
+
+	// post
+	Post *webArticle
+
+	// feeds
+	Feeds []webFeed
+}
+
+type webFolder struct {
+	Name  string
+	Feeds []webFolderFeed
+}
+
+type webFolderFeed struct {
+	ID      uuid.UUID
+	Name    string
+	Unread  int64
+	Current bool
+}
+
+type webPostItem struct {
+	ID        int64
+	Title     string
+	Feed      string
+	Published string
+	Excerpt   string
+	Read      bool
+	Starred   bool
+	Tags      []string
+}
+
+type webArticle struct {
+	ID        int64
+	Title     string
+	Url       string
+	Feed      string
+	Author    string
+	Published string
+	Content   template.HTML
+	Starred   bool
+}
+
+type webFeed struct {
+	ID        uuid.UUID
+	Name      string
+	Url       string
+	CreatedBy string
+	Following bool
+}
+
+// webSession is the signed in user of a web request.
+type webSession struct {
+	User  database.User
+	CSRF  string
+	token string
+}
+
+// parseWebPages parses each page template together with the layout.
+func parseWebPages() map[string]*template.Template {
+	pages := map[string]*template.Template{}
+
+	for _, name := range []string{"login", "posts", "post", "feeds"} {
+		pages[name] = template.Must(template.ParseFS(webFiles, "web/templates/layout.html", "web/templates/"+name+".html"))
+	}
+
+	return pages
+}
+
+// routeWeb registers the web reader.
+func (srv *server) routeWeb() {
+	static, err := fs.Sub(webFiles, "web/static")
+
+	if err != nil {
+		panic(err)
+	}
+
+	srv.mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	srv.mux.HandleFunc("GET /login", srv.webLoginForm)
+	srv.mux.HandleFunc("POST /login", srv.webLogin)
+	srv.mux.HandleFunc("POST /logout", srv.signedIn(srv.webLogout))
+	srv.mux.HandleFunc("GET /{$}", srv.signedIn(srv.webPosts))
+	srv.mux.HandleFunc("GET /posts/{id}", srv.signedIn(srv.webPost))
+	srv.mux.HandleFunc("POST /posts/{id}/read", srv.signedIn(srv.webSetRead))
+	srv.mux.HandleFunc("POST /posts/{id}/star", srv.signedIn(srv.webSetStarred))
+	srv.mux.HandleFunc("GET /feeds", srv.signedIn(srv.webFeeds))
+	srv.mux.HandleFunc("POST /feeds/follow", srv.signedIn(srv.webFollow))
+	srv.mux.HandleFunc("POST /feeds/unfollow", srv.signedIn(srv.webUnfollow))
+}
+
+// render executes a page into a buffer first, so a template error still
+// results in a clean error response.
+func (srv *server) render(w http.ResponseWriter, status int, name string, page webPage) {
+	var body bytes.Buffer
+
+	err := srv.pages[name].ExecuteTemplate(&body, "layout", page)
+
+	if err != nil {
+		webInternalError(w, "could not render page", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+func webInternalError(w http.ResponseWriter, message string, err error) {
+	log.Printf("%v %v\n", message, err)
+	http.Error(w, message, http.StatusInternalServerError)
+}
+
+// signedIn resolves the user of a request from its session cookie and
+// sends visitors without one to the login page. Form posts must carry the
+// session's CSRF token.
+func (srv *server) signedIn(handler func(w http.ResponseWriter, r *http.Request, session webSession)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+
+		if err != nil || cookie.Value == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		user, err := srv.s.db.GetUserBySession(r.Context(), hashToken(cookie.Value))
+
+		if errors.Is(err, sql.ErrNoRows) {
+			clearSessionCookie(w, r)
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		if err != nil {
+			webInternalError(w, "could not check session", err)
+			return
+		}
+
+		session := webSession{
+			User:  user,
+			CSRF:  csrfToken(cookie.Value),
+			token: cookie.Value,
+		}
+
+		if r.Method == http.MethodPost {
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+
+			if subtle.ConstantTimeCompare([]byte(r.PostFormValue("csrf")), []byte(session.CSRF)) != 1 {
+				http.Error(w, "invalid form token, reload the page and try again", http.StatusForbidden)
+				return
+			}
+		}
+
+		handler(w, r, session)
+	}
+}
+
+// csrfToken is derived from the session token, so it needs no storage and
+// changes with every sign in.
+func csrfToken(sessionToken string) string {
+	return hashToken("csrf:" + sessionToken)
+}
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (srv *server) webLoginForm(w http.ResponseWriter, r *http.Request) {
+	srv.render(w, http.StatusOK, "login", webPage{Title: "Sign in"})
+}
+
+func (srv *server) webLogin(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+	name := strings.TrimSpace(r.PostFormValue("name"))
+
+	user, ok, err := srv.checkPassword(r.Context(), name, r.PostFormValue("password"))
+
+	if err != nil {
+		webInternalError(w, "could not get user", err)
+		return
+	}
+
+	if !ok {
+		srv.render(w, http.StatusUnauthorized, "login", webPage{
+			Title: "Sign in",
+			Name:  name,
+			Error: "Wrong user or password.",
+		})
+		return
+	}
+
+	expires := time.Now().Add(sessionDuration)
+	token, err := srv.newSession(r.Context(), user, expires)
+
+	if err != nil {
+		webInternalError(w, "could not create session", err)
+		return
+	}
+
+	setSessionCookie(w, r, token, expires)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// checkPassword looks up the user called name and reports whether secret is
+// their password. Users who never ran passwd cannot sign in. Unknown users
+// take as long to turn away as wrong passwords, so sign in does not tell
+// which names exist.
+func (srv *server) checkPassword(ctx context.Context, name, secret string) (database.User, bool, error) {
+	user, err := srv.s.db.GetUser(ctx, name)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		password.VerifyNothing(secret)
+		return user, false, nil
+	}
+
+	if err != nil {
+		return user, false, err
+	}
+
+	if !user.PasswordHash.Valid {
+		password.VerifyNothing(secret)
+		return user, false, nil
+	}
+
+	return user, password.Verify(secret, user.PasswordHash.String), nil
+}
+
+// newSession signs user in until expires and returns the session token.
+func (srv *server) newSession(ctx context.Context, user database.User, expires time.Time) (string, error) {
+	token, err := randomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	err = srv.s.db.CreateSession(ctx, database.CreateSessionParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: expires,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	err = srv.s.db.DeleteExpiredSessions(ctx)
+
+	if err != nil {
+		log.Printf("Failed to delete expired sessions %v\n", err)
+	}
+
+	return token, nil
+}
+
+func (srv *server) webLogout(w http.ResponseWriter, r *http.Request, session webSession) {
+	err := srv.s.db.DeleteSession(r.Context(), hashToken(session.token))
+
+	if err != nil {
+		webInternalError(w, "could not sign out", err)
+		return
+	}
+
+	clearSessionCookie(w, r)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// page starts the data of a signed in page, with the sidebar of followed
+// feeds. current marks the feed being shown, if any.
+func (srv *server) page(r *http.Request, session webSession, title string, current uuid.UUID) (webPage, error) {
+	folders, err := srv.folders(r, session.User, current)
+
+	if err != nil {
+		return webPage{}, err
+	}
+
+	return webPage{
+		Title:   title,
+		User:    session.User.Name,
+		CSRF:    session.CSRF,
+		Path:    r.URL.RequestURI(),
+		Folders: folders,
+	}, nil
+}
+
+// folders groups the followed feeds of user by folder, feeds without a
+// folder first, with their unread counts.
+func (srv *server) folders(r *http.Request, user database.User, current uuid.UUID) ([]webFolder, error) {
+	userID := uuid.NullUUID{
+		UUID:  user.ID,
+		Valid: true,
+	}
+
+	feeds, err := srv.s.db.GetFollowedFeedsForUser(r.Context(), userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := srv.s.db.GetUnreadCountsForUser(r.Context(), userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	unread := make(map[uuid.UUID]int64, len(counts))
+
+	for _, count := range counts {
+		unread[count.FeedID] = count.Unread
+	}
+
+	var folders []webFolder
+
+	for _, feed := range feeds {
+		if len(folders) == 0 || folders[len(folders)-1].Name != feed.Folder.String {
+			folders = append(folders, webFolder{Name: feed.Folder.String})
+		}
+
+		folder := &folders[len(folders)-1]
+		folder.Feeds = append(folder.Feeds, webFolderFeed{
+			ID:      feed.ID,
+			Name:    feed.Name,
+			Unread:  unread[feed.ID],
+			Current: feed.ID == current,
+		})
+	}
+
+	return folders, nil
+}
+
+// webPosts lists posts of followed feeds, newest first, filtered by the
+// feed, unread and starred query parameters.
+func (srv *server) webPosts(w http.ResponseWriter, r *http.Request, session webSession) {
+	query := r.URL.Query()
+	params := database.ListPostsForUserParams{
+		UserID: uuid.NullUUID{
+			UUID:  session.User.ID,
+			Valid: true,
+		},
+		UnreadOnly:  query.Get("unread") == "true",
+		StarredOnly: query.Get("starred") == "true",
+		MaxPosts:    webPageSize,
+	}
+
+	offset, err := queryInt(query.Get("offset"), 0)
+
+	if err != nil || offset < 0 {
+		http.Error(w, "offset must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	params.SkipPosts = int32(offset)
+
+	if query.Get("feed") != "" {
+		feedID, err := uuid.Parse(query.Get("feed"))
+
+		if err != nil {
+			http.Error(w, "invalid feed id", http.StatusBadRequest)
+			return
+		}
+
+		params.FeedID = uuid.NullUUID{
+			UUID:  feedID,
+			Valid: true,
+		}
+	}
+
+	posts, err := srv.s.db.ListPostsForUser(r.Context(), params)
+
+	if err != nil {
+		webInternalError(w, "could not get posts", err)
+		return
+	}
+
+	page, err := srv.page(r, session, "", params.FeedID.UUID)
+
+	if err != nil {
+		webInternalError(w, "could not get feeds", err)
+		return
+	}
+
+	switch {
+	case params.FeedID.Valid:
+		page.Heading = "Feed"
+
+		for _, folder := range page.Folders {
+			for _, feed := range folder.Feeds {
+				if feed.Current {
+					page.Heading = feed.Name
+				}
+			}
+		}
+	case params.StarredOnly:
+		page.Heading = "Starred"
+	case params.UnreadOnly:
+		page.Heading = "Unread"
+	default:
+		page.Heading = "All posts"
+	}
+
+	page.Title = page.Heading
+
+	for _, post := range posts {
+		page.Posts = append(page.Posts, webPostItem{
+			ID:        post.Seq,
+			Title:     post.Title,
+			Feed:      post.FeedName,
+			Published: webTime(post.PublishedAt),
+			Excerpt:   excerpt(post.Description.String),
+			Read:      post.ReadAt.Valid,
+			Starred:   post.Starred,
+			Tags:      post.Tags,
+		})
+	}
+
+	if offset > 0 {
+		page.PrevPage = pageLink(r.URL, max(offset-webPageSize, 0))
+	}
+
+	if len(posts) == webPageSize {
+		page.NextPage = pageLink(r.URL, offset+webPageSize)
+	}
+
+	srv.render(w, http.StatusOK, "posts", page)
+}
+
+// webPost shows a post and marks it read.
+func (srv *server) webPost(w http.ResponseWriter, r *http.Request, session webSession) {
+	post, ok := srv.webLookupPost(w, r, session.User)
+
+	if !ok {
+		return
+	}
+
+	state, err := srv.s.db.GetPostState(r.Context(), database.GetPostStateParams{
+		UserID: session.User.ID,
+		PostID: post.ID,
+	})
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		webInternalError(w, "could not get post state", err)
+		return
+	}
+
+	err = srv.s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+		ID:     uuid.New(),
+		UserID: session.User.ID,
+		PostID: post.ID,
+	})
+
+	if err != nil {
+		webInternalError(w, "could not update read state", err)
+		return
+	}
+
+	page, err := srv.page(r, session, post.Title, post.FeedID)
+
+	if err != nil {
+		webInternalError(w, "could not get feeds", err)
+		return
+	}
+
+	content := post.Content.String
+
+	if content == "" {
+		content = post.Description.String
+	}
+
+	// Content is sanitized when it is stored; doing it again here keeps
+	// posts stored before that safe to render.
+	page.Post = &webArticle{
+		ID:        post.Seq,
+		Title:     post.Title,
+		Url:       post.Url,
+		Feed:      post.FeedName,
+		Author:    post.Author.String,
+		Published: webTime(post.PublishedAt),
+		Content:   template.HTML(sanitize.HTML(content, nil)),
+		Starred:   state.Starred,
+	}
+
+	srv.render(w, http.StatusOK, "post", page)
+}
+
+func (srv *server) webSetRead(w http.ResponseWriter, r *http.Request, session webSession) {
+	post, ok := srv.webLookupPost(w, r, session.User)
+
+	if !ok {
+		return
+	}
+
+	var err error
+
+	if r.PostFormValue("read") == "true" {
+		err = srv.s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+			ID:     uuid.New(),
+			UserID: session.User.ID,
+			PostID: post.ID,
+		})
+	} else {
+		err = srv.s.db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{
+			UserID: session.User.ID,
+			PostID: post.ID,
+		})
+	}
+
+	if err != nil {
+		webInternalError(w, "could not update read state", err)
+		return
+	}
+
+	redirectBack(w, r)
+}
+
+func (srv *server) webSetStarred(w http.ResponseWriter, r *http.Request, session webSession) {
+	post, ok := srv.webLookupPost(w, r, session.User)
+
+	if !ok {
+		return
+	}
+
+	err := srv.s.db.SetPostStarred(r.Context(), database.SetPostStarredParams{
+		ID:      uuid.New(),
+		UserID:  session.User.ID,
+		PostID:  post.ID,
+		Starred: r.PostFormValue("starred") == "true",
+	})
+
+	if err != nil {
+		webInternalError(w, "could not update starred state", err)
+		return
+	}
+
+	redirectBack(w, r)
+}
+
+// webFeeds lists every feed with a button to follow or unfollow it.
+func (srv *server) webFeeds(w http.ResponseWriter, r *http.Request, session webSession) {
+	feeds, err := srv.s.db.GetFeeds(r.Context())
+
+	if err != nil {
+		webInternalError(w, "could not get feeds", err)
+		return
+	}
+
+	page, err := srv.page(r, session, "Feeds", uuid.Nil)
+
+	if err != nil {
+		webInternalError(w, "could not get feeds", err)
+		return
+	}
+
+	following := map[uuid.UUID]bool{}
+
+	for _, folder := range page.Folders {
+		for _, feed := range folder.Feeds {
+			following[feed.ID] = true
+		}
+	}
+
+	for _, feed := range feeds {
+		page.Feeds = append(page.Feeds, webFeed{
+			ID:        feed.ID,
+			Name:      feed.FeedName,
+			Url:       feed.Url,
+			CreatedBy: feed.UserName,
+			Following: following[feed.ID],
+		})
+	}
+
+	srv.render(w, http.StatusOK, "feeds", page)
+}
+
+func (srv *server) webFollow(w http.ResponseWriter, r *http.Request, session webSession) {
+	_, _, err := followFeed(r.Context(), srv.s, session.User, r.PostFormValue("url"))
+
+	switch {
+	case errors.Is(err, errFeedNotFound):
+		http.Error(w, "no feed with this url", http.StatusNotFound)
+		return
+	case err != nil && !errors.Is(err, errAlreadyFollowing):
+		webInternalError(w, "could not follow feed", err)
+		return
+	}
+
+	http.Redirect(w, r, "/feeds", http.StatusSeeOther)
+}
+
+func (srv *server) webUnfollow(w http.ResponseWriter, r *http.Request, session webSession) {
+	feedID, err := uuid.Parse(r.PostFormValue("feed_id"))
+
+	if err != nil {
+		http.Error(w, "invalid feed id", http.StatusBadRequest)
+		return
+	}
+
+	_, err = srv.s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		UserID: uuid.NullUUID{
+			UUID:  session.User.ID,
+			Valid: true,
+		},
+		FeedID: uuid.NullUUID{
+			UUID:  feedID,
+			Valid: true,
+		},
+	})
+
+	if err != nil {
+		webInternalError(w, "could not unfollow feed", err)
+		return
+	}
+
+	http.Redirect(w, r, "/feeds", http.StatusSeeOther)
+}
+
+// webLookupPost finds the post named by the {id} path value among the feeds
+// user follows and answers with an error when there is none.
+func (srv *server) webLookupPost(w http.ResponseWriter, r *http.Request, user database.User) (database.GetPostBySeqForUserRow, bool) {
+	seq, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return database.GetPostBySeqForUserRow{}, false
+	}
+
+	post, err := srv.s.db.GetPostBySeqForUser(r.Context(), database.GetPostBySeqForUserParams{
+		Seq: seq,
+		UserID: uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		},
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return post, false
+	}
+
+	if err != nil {
+		webInternalError(w, "could not get post", err)
+		return post, false
+	}
+
+	return post, true
+}
+
+// redirectBack sends the browser to the next form value when it is a path
+// on this server, and to the post list otherwise.
+func redirectBack(w http.ResponseWriter, r *http.Request) {
+	next := r.PostFormValue("next")
+
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/"
+	}
+
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func pageLink(current *url.URL, offset int) string {
+	query := current.Query()
+
+	if offset == 0 {
+		query.Del("offset")
+	} else {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+
+	if len(query) == 0 {
+		return current.Path
+	}
+
+	return current.Path + "?" + query.Encode()
+}
+
+func webTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "undated"
+	}
+
+	return t.Time.Local().Format("Jan 2, 2006 15:04")
+}
+
+// excerpt is the start of a post description as plain text.
+func excerpt(description string) string {
+	text := []rune(htmltext.Plain(description))
+
+	if len(text) <= excerptLength {
+		return string(text)
+	}
+
+	return strings.TrimSpace(string(text[:excerptLength])) + "…"
+}
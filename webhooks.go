@@ -0,0 +1,731 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/rules"
+	"github.com/mambo-dev/gator/internal/webhook"
+)
+
+const (
+	// eventPostCreated is sent for every new post of a feed a webhook
+	// applies to.
+	eventPostCreated = "post.created"
+	// eventPing is sent by webhook test.
+	eventPing = "ping"
+
+	webhookTimeout = 10 * time.Second
+	// webhookMaxAttempts is how often a delivery is tried before it is
+	// given up on.
+	webhookMaxAttempts = 8
+	// webhookBatchSize is how many due deliveries are sent per agg tick.
+	webhookBatchSize = 50
+	// webhookWorkers is how many deliveries are sent at once, so a tick
+	// spends at most webhookBatchSize / webhookWorkers * webhookTimeout on
+	// slow receivers before agg fetches the next feed.
+	webhookWorkers = 10
+	// webhookClaimTimeout is how long a claimed delivery is kept from other
+	// agg processes. A delivery whose outcome was not recorded, because agg
+	// stopped or the database failed, is sent again after it.
+	webhookClaimTimeout = 5 * time.Minute
+)
+
+// webhookEvents lists the events a webhook can subscribe to.
+var webhookEvents = []string{eventPostCreated}
+
+var webhookCommand = &commandSpec{
+	name:        "webhook",
+	description: "List your webhooks. agg posts a signed JSON payload to them for every new post.",
+	handler:     middlewareLoggedIn(handlerWebhooks),
+	subcommands: []*commandSpec{
+		{
+			name:        "add",
+			description: "Add a webhook for the new posts of the feeds you follow, of one feed or that a rule applies to.",
+			args:        []string{"<name>", "<url>"},
+			flags: func(flags *flag.FlagSet) {
+				flags.String("secret", "", "`secret` payloads are signed with, generated when not given")
+				flags.String("events", eventPostCreated, "comma separated `events` to send: "+strings.Join(webhookEvents, ", "))
+				flags.String("feed", "", "only send the posts of the followed feed with this `url`")
+				flags.String("rule", "", "only send the posts the rule with this `id` applies to")
+			},
+			handler: middlewareLoggedIn(handlerWebhookAdd),
+		},
+		{
+			name:        "list",
+			description: "List your webhooks.",
+			handler:     middlewareLoggedIn(handlerWebhooks),
+		},
+		{
+			name:        "remove",
+			description: "Remove a webhook and its delivery log.",
+			args:        []string{"<name>"},
+			handler:     middlewareLoggedIn(handlerWebhookRemove),
+		},
+		{
+			name:        "test",
+			description: "Send a ping to a webhook right away and show the answer.",
+			args:        []string{"<name>"},
+			handler:     middlewareLoggedIn(handlerWebhookTest),
+		},
+		{
+			name:        "log",
+			description: "Show recent deliveries, of all webhooks or of one.",
+			args:        []string{"[name]"},
+			flags: func(flags *flag.FlagSet) {
+				flags.Int("limit", 20, "show the newest `N` deliveries")
+			},
+			handler: middlewareLoggedIn(handlerWebhookLog),
+		},
+	},
+}
+
+type webhookResult struct {
+	Name   string   `json:"name"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+	Feed   string   `json:"feed,omitempty"`
+	Rule   int64    `json:"rule,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+func (r webhookResult) Text() string {
+	var text strings.Builder
+
+	fmt.Fprintf(&text, "- %v: %v on %v", r.Name, r.Url, strings.Join(r.Events, ", "))
+
+	if r.Feed != "" {
+		fmt.Fprintf(&text, ", feed %v", r.Feed)
+	}
+
+	if r.Rule != 0 {
+		fmt.Fprintf(&text, ", rule #%v", r.Rule)
+	}
+
+	if r.Secret != "" {
+		fmt.Fprintf(&text, "\nPayloads are signed with this secret, store it now as it will not be shown again:\n%v", r.Secret)
+	}
+
+	return text.String()
+}
+
+type webhooksResult []webhookResult
+
+func (r webhooksResult) Text() string {
+	if len(r) == 0 {
+		return "No webhooks, add one with \"gator webhook add <name> <url>\"."
+	}
+
+	var text strings.Builder
+
+	for _, hook := range r {
+		fmt.Fprintln(&text, hook.Text())
+	}
+
+	return text.String()
+}
+
+type deliveryResult struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Webhook     string     `json:"webhook"`
+	Event       string     `json:"event"`
+	Status      string     `json:"status"`
+	Attempts    int32      `json:"attempts"`
+	StatusCode  int32      `json:"status_code,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+}
+
+func (r deliveryResult) Text() string {
+	status := r.Status
+
+	if r.NextAttempt != nil {
+		status += " at " + r.NextAttempt.Format(time.DateTime)
+	}
+
+	text := fmt.Sprintf("%v %v %v: %v after %d attempts", r.CreatedAt.Format(time.DateTime), r.Webhook, r.Event, status, r.Attempts)
+
+	if r.Error != "" {
+		text += " (" + r.Error + ")"
+	}
+
+	return text
+}
+
+type deliveriesResult []deliveryResult
+
+func (r deliveriesResult) Text() string {
+	if len(r) == 0 {
+		return "No deliveries yet."
+	}
+
+	var text strings.Builder
+
+	for _, delivery := range r {
+		fmt.Fprintln(&text, delivery.Text())
+	}
+
+	return text.String()
+}
+
+func handlerWebhooks(s *state, cmd command, user database.User) (any, error) {
+	hooks, err := s.db.GetWebhooksForUser(cmd.ctx, user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get webhooks %v", err)
+	}
+
+	ruleSeqs, err := ruleSeqsForUser(cmd.ctx, s, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(webhooksResult, 0, len(hooks))
+
+	for _, hook := range hooks {
+		result = append(result, webhookResult{
+			Name:   hook.Name,
+			Url:    hook.Url,
+			Events: hook.Events,
+			Feed:   hook.FeedUrl.String,
+			Rule:   ruleSeqs[hook.RuleID.UUID],
+		})
+	}
+
+	return result, nil
+}
+
+func handlerWebhookAdd(s *state, cmd command, user database.User) (any, error) {
+	target, err := url.Parse(cmd.arguments[1])
+
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url %q, expecting an http or https url", cmd.arguments[1])
+	}
+
+	events := []string{}
+
+	for _, event := range strings.Split(cmd.stringFlag("events"), ",") {
+		event = strings.TrimSpace(event)
+
+		if !slices.Contains(webhookEvents, event) {
+			return nil, fmt.Errorf("unknown event %q, expecting %v", event, strings.Join(webhookEvents, ", "))
+		}
+
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+
+	result := webhookResult{
+		Name:   cmd.arguments[0],
+		Url:    target.String(),
+		Events: events,
+		Secret: cmd.stringFlag("secret"),
+	}
+
+	generated := result.Secret == ""
+
+	if generated {
+		result.Secret, err = randomToken()
+
+		if err != nil {
+			return nil, fmt.Errorf("could not generate secret %v", err)
+		}
+	}
+
+	feedID := uuid.NullUUID{}
+
+	if cmd.isSet("feed") {
+		feedUrl := canonicalUrl(cmd.ctx, s, cmd.stringFlag("feed"))
+
+		follows, err := s.db.GetFollowedFeedsForUser(cmd.ctx, uuid.NullUUID{
+			UUID:  user.ID,
+			Valid: true,
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get feed follows %v", err)
+		}
+
+		for _, follow := range follows {
+			if follow.Url == feedUrl {
+				feedID = uuid.NullUUID{
+					UUID:  follow.ID,
+					Valid: true,
+				}
+				result.Feed = follow.Url
+			}
+		}
+
+		if !feedID.Valid {
+			return nil, fmt.Errorf("you do not follow %v", cmd.stringFlag("feed"))
+		}
+	}
+
+	ruleID := uuid.NullUUID{}
+
+	if cmd.isSet("rule") {
+		rule, err := ruleBySeq(cmd.ctx, s, user.ID, cmd.stringFlag("rule"))
+
+		if err != nil {
+			return nil, err
+		}
+
+		ruleID = uuid.NullUUID{
+			UUID:  rule.ID,
+			Valid: true,
+		}
+		result.Rule = rule.Seq
+	}
+
+	_, err = s.db.CreateWebhook(cmd.ctx, database.CreateWebhookParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      result.Name,
+		Url:       result.Url,
+		Secret:    result.Secret,
+		Events:    events,
+		FeedID:    feedID,
+		RuleID:    ruleID,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create webhook, is the name already used? %v", err)
+	}
+
+	if !generated {
+		result.Secret = ""
+	}
+
+	return result, nil
+}
+
+func handlerWebhookRemove(s *state, cmd command, user database.User) (any, error) {
+	removed, err := s.db.DeleteWebhook(cmd.ctx, database.DeleteWebhookParams{
+		UserID: user.ID,
+		Name:   cmd.arguments[0],
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not remove webhook %v", err)
+	}
+
+	if removed == 0 {
+		return nil, fmt.Errorf("no webhook named %v", cmd.arguments[0])
+	}
+
+	return messageResult{Message: fmt.Sprintf("webhook %v removed", cmd.arguments[0])}, nil
+}
+
+// handlerWebhookTest sends a ping outside of the retry queue: it is tried
+// once, and the outcome is kept in the delivery log.
+func handlerWebhookTest(s *state, cmd command, user database.User) (any, error) {
+	hook, err := s.db.GetWebhook(cmd.ctx, database.GetWebhookParams{
+		UserID: user.ID,
+		Name:   cmd.arguments[0],
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no webhook named %v", cmd.arguments[0])
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get webhook %v", err)
+	}
+
+	delivery, err := queueWebhookDelivery(cmd.ctx, s, hook, eventPing, nil, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := sendWebhookDelivery(cmd.ctx, s, database.ClaimDueWebhookDeliveriesRow{
+		ID:        delivery.ID,
+		CreatedAt: delivery.CreatedAt,
+		WebhookID: delivery.WebhookID,
+		Event:     delivery.Event,
+		Payload:   delivery.Payload,
+		Url:       hook.Url,
+		Secret:    hook.Secret,
+	}, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.Webhook = hook.Name
+
+	return result, nil
+}
+
+func handlerWebhookLog(s *state, cmd command, user database.User) (any, error) {
+	limit := cmd.intFlag("limit")
+
+	if limit < 1 {
+		return nil, errors.New("expecting a positive --limit.")
+	}
+
+	name := sql.NullString{}
+
+	if len(cmd.arguments) > 0 {
+		name = sql.NullString{
+			String: cmd.arguments[0],
+			Valid:  true,
+		}
+	}
+
+	deliveries, err := s.db.GetWebhookDeliveriesForUser(cmd.ctx, database.GetWebhookDeliveriesForUserParams{
+		UserID:        user.ID,
+		WebhookName:   name,
+		MaxDeliveries: int32(limit),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get deliveries %v", err)
+	}
+
+	result := make(deliveriesResult, 0, len(deliveries))
+
+	for _, delivery := range deliveries {
+		result = append(result, newDeliveryResult(database.WebhookDelivery{
+			ID:            delivery.ID,
+			CreatedAt:     delivery.CreatedAt,
+			UpdatedAt:     delivery.UpdatedAt,
+			WebhookID:     delivery.WebhookID,
+			Event:         delivery.Event,
+			Payload:       delivery.Payload,
+			Attempts:      delivery.Attempts,
+			NextAttemptAt: delivery.NextAttemptAt,
+			DeliveredAt:   delivery.DeliveredAt,
+			StatusCode:    delivery.StatusCode,
+			LastError:     delivery.LastError,
+		}, delivery.WebhookName))
+	}
+
+	return result, nil
+}
+
+func newDeliveryResult(delivery database.WebhookDelivery, webhookName string) deliveryResult {
+	result := deliveryResult{
+		ID:         delivery.ID,
+		CreatedAt:  delivery.CreatedAt,
+		Webhook:    webhookName,
+		Event:      delivery.Event,
+		Attempts:   delivery.Attempts,
+		StatusCode: delivery.StatusCode.Int32,
+		Error:      delivery.LastError.String,
+	}
+
+	switch {
+	case delivery.DeliveredAt.Valid:
+		result.Status = "delivered"
+	case delivery.NextAttemptAt.Valid && delivery.Attempts == 0:
+		result.Status = "queued"
+	case delivery.NextAttemptAt.Valid:
+		result.Status = "retrying"
+		result.NextAttempt = &delivery.NextAttemptAt.Time
+	default:
+		result.Status = "failed"
+	}
+
+	return result
+}
+
+// webhookPayload is the JSON body of a delivery. Post is set for post
+// events.
+type webhookPayload struct {
+	ID        uuid.UUID    `json:"id"`
+	Event     string       `json:"event"`
+	CreatedAt time.Time    `json:"created_at"`
+	Webhook   string       `json:"webhook"`
+	Post      *webhookPost `json:"post,omitempty"`
+}
+
+type webhookPost struct {
+	ID          uuid.UUID   `json:"id"`
+	ShortID     int64       `json:"short_id"`
+	Title       string      `json:"title"`
+	Url         string      `json:"url"`
+	Author      string      `json:"author,omitempty"`
+	Categories  []string    `json:"categories"`
+	PublishedAt *time.Time  `json:"published_at,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Feed        webhookFeed `json:"feed"`
+}
+
+type webhookFeed struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Url  string    `json:"url"`
+}
+
+func newWebhookPost(post database.Post, feed database.GetNextFeedToFetchRow) *webhookPost {
+	result := &webhookPost{
+		ID:          post.ID,
+		ShortID:     post.Seq,
+		Title:       post.Title,
+		Url:         post.Url,
+		Author:      post.Author.String,
+		Categories:  post.Categories,
+		Description: post.Description.String,
+		Feed: webhookFeed{
+			ID:   feed.ID,
+			Name: feed.Name,
+			Url:  feed.Url,
+		},
+	}
+
+	if result.Categories == nil {
+		result.Categories = []string{}
+	}
+
+	if post.PublishedAt.Valid {
+		result.PublishedAt = &post.PublishedAt.Time
+	}
+
+	return result
+}
+
+// feedWebhook is a webhook that may fire for the new posts of a feed,
+// together with the rule filtering them.
+type feedWebhook struct {
+	hook database.Webhook
+	rule *rules.Rule
+}
+
+// loadFeedWebhooks returns the webhooks of the followers of a feed that
+// apply to its new posts.
+func loadFeedWebhooks(ctx context.Context, s *state, feedID uuid.UUID) ([]feedWebhook, error) {
+	hooks, err := s.db.GetWebhooksForFeed(ctx, database.GetWebhooksForFeedParams{
+		FeedID: uuid.NullUUID{
+			UUID:  feedID,
+			Valid: true,
+		},
+		Event: eventPostCreated,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get webhooks %v", err)
+	}
+
+	rulesByID := map[uuid.UUID]*rules.Rule{}
+
+	if slices.ContainsFunc(hooks, func(hook database.Webhook) bool { return hook.RuleID.Valid }) {
+		rows, err := s.db.GetRulesForFeed(ctx, uuid.NullUUID{
+			UUID:  feedID,
+			Valid: true,
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get rules %v", err)
+		}
+
+		for _, row := range rows {
+			rule, err := ruleFromRow(row)
+
+			if err != nil {
+				return nil, err
+			}
+
+			rulesByID[row.ID] = rule
+		}
+	}
+
+	result := make([]feedWebhook, 0, len(hooks))
+
+	for _, hook := range hooks {
+		result = append(result, feedWebhook{
+			hook: hook,
+			rule: rulesByID[hook.RuleID.UUID],
+		})
+	}
+
+	return result, nil
+}
+
+// queuePostWebhooks queues a post.created delivery of a new post for every
+// webhook whose rule, if any, applies to it.
+func queuePostWebhooks(ctx context.Context, s *state, hooks []feedWebhook, post *webhookPost, rulePost rules.Post) {
+	for _, hook := range hooks {
+		if hook.rule != nil && !hook.rule.Applies(rulePost) {
+			continue
+		}
+
+		_, err := queueWebhookDelivery(ctx, s, hook.hook, eventPostCreated, post, true)
+
+		if err != nil {
+			log.Printf("Failed to queue webhook %v\n", err.Error())
+		}
+	}
+}
+
+// queueWebhookDelivery stores a delivery with its payload, so that retries
+// send the same bytes. Queued deliveries are sent by agg.
+func queueWebhookDelivery(ctx context.Context, s *state, hook database.Webhook, event string, post *webhookPost, queued bool) (database.WebhookDelivery, error) {
+	payload := webhookPayload{
+		ID:        uuid.New(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Webhook:   hook.Name,
+		Post:      post,
+	}
+
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return database.WebhookDelivery{}, fmt.Errorf("could not encode webhook payload %v", err)
+	}
+
+	delivery, err := s.db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		ID:        payload.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		WebhookID: hook.ID,
+		Event:     event,
+		Payload:   string(body),
+		NextAttemptAt: sql.NullTime{
+			Time:  time.Now(),
+			Valid: queued,
+		},
+	})
+
+	if err != nil {
+		return database.WebhookDelivery{}, fmt.Errorf("could not queue webhook delivery %v", err)
+	}
+
+	return delivery, nil
+}
+
+// deliverWebhooks sends the deliveries that are due: new ones and failed
+// ones whose backoff has passed. They are claimed first, so agg processes
+// sharing a database do not send the same delivery twice.
+func deliverWebhooks(ctx context.Context, s *state) error {
+	deliveries, err := s.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		ClaimedUntil: sql.NullTime{
+			Time:  time.Now().Add(webhookClaimTimeout),
+			Valid: true,
+		},
+		MaxDeliveries: webhookBatchSize,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not get webhook deliveries %v", err)
+	}
+
+	queue := make(chan database.ClaimDueWebhookDeliveriesRow)
+	var wg sync.WaitGroup
+
+	for range min(webhookWorkers, len(deliveries)) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for delivery := range queue {
+				result, err := sendWebhookDelivery(ctx, s, delivery, true)
+
+				// The delivery is tried again once its claim runs out, the
+				// others in the batch are sent anyway.
+				if err != nil {
+					log.Printf("Failed to deliver webhook %v %v\n", delivery.ID, err.Error())
+					continue
+				}
+
+				if result.Status != "delivered" {
+					log.Printf("Webhook delivery %v %v: %v\n", delivery.ID, result.Status, result.Error)
+				}
+			}
+		}()
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+
+		queue <- delivery
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return nil
+}
+
+// sendWebhookDelivery tries a delivery once and records the outcome. A
+// failed delivery is retried later with exponential backoff when retry is
+// set, until webhookMaxAttempts is reached.
+func sendWebhookDelivery(ctx context.Context, s *state, delivery database.ClaimDueWebhookDeliveriesRow, retry bool) (deliveryResult, error) {
+	status, sendErr := webhook.Send(ctx, http.DefaultClient, webhook.Delivery{
+		ID:      delivery.ID.String(),
+		Event:   delivery.Event,
+		Url:     delivery.Url,
+		Secret:  delivery.Secret,
+		Payload: []byte(delivery.Payload),
+	}, webhookTimeout)
+
+	attempts := delivery.Attempts + 1
+
+	params := database.SetWebhookDeliveryResultParams{
+		ID:       delivery.ID,
+		Attempts: attempts,
+		StatusCode: sql.NullInt32{
+			Int32: int32(status),
+			Valid: status != 0,
+		},
+	}
+
+	if sendErr == nil {
+		params.DeliveredAt = sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		}
+	} else {
+		params.LastError = sql.NullString{
+			String: sendErr.Error(),
+			Valid:  true,
+		}
+
+		if retry && attempts < webhookMaxAttempts {
+			params.NextAttemptAt = sql.NullTime{
+				Time:  time.Now().Add(webhook.Backoff(int(attempts))),
+				Valid: true,
+			}
+		}
+	}
+
+	err := s.db.SetWebhookDeliveryResult(ctx, params)
+
+	if err != nil {
+		return deliveryResult{}, fmt.Errorf("could not record webhook delivery %v", err)
+	}
+
+	return newDeliveryResult(database.WebhookDelivery{
+		ID:            delivery.ID,
+		CreatedAt:     delivery.CreatedAt,
+		WebhookID:     delivery.WebhookID,
+		Event:         delivery.Event,
+		Attempts:      params.Attempts,
+		NextAttemptAt: params.NextAttemptAt,
+		DeliveredAt:   params.DeliveredAt,
+		StatusCode:    params.StatusCode,
+		LastError:     params.LastError,
+	}, ""), nil
+}
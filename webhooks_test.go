@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/webhook"
+)
+
+var dueDeliveryColumns = []string{
+	"id", "created_at", "updated_at", "webhook_id", "event", "payload", "attempts",
+	"next_attempt_at", "delivered_at", "status_code", "last_error", "url", "secret",
+}
+
+func dueDelivery(id uuid.UUID, attempts int64, url string) []driver.Value {
+	now := time.Now()
+
+	return []driver.Value{
+		id.String(), now, now, uuid.NewString(), eventPostCreated, `{"event":"post.created"}`,
+		attempts, now, nil, nil, nil, url, "s3cret",
+	}
+}
+
+// deliveryResults returns the recorded results of deliverWebhooks by
+// delivery id.
+func deliveryResults(t *testing.T, db *fakeDB) map[string][]driver.Value {
+	results := map[string][]driver.Value{}
+
+	for _, args := range db.called("SetWebhookDeliveryResult") {
+		results[args[0].(string)] = args
+	}
+
+	return results
+}
+
+func TestDeliverWebhooksSignsAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	signatures := map[string]bool{}
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		signatures[r.Header.Get(webhook.DeliveryHeader)] = webhook.Verify("s3cret", body, r.Header.Get(webhook.SignatureHeader))
+		mu.Unlock()
+
+		if r.URL.Path == "/down" {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	delivered := uuid.New()
+	retried := uuid.New()
+	givenUp := uuid.New()
+
+	db := newFakeDB(t)
+	db.on("ClaimDueWebhookDeliveries", func(args []driver.Value) (fakeRows, error) {
+		return fakeRows{
+			columns: dueDeliveryColumns,
+			rows: [][]driver.Value{
+				dueDelivery(delivered, 0, receiver.URL+"/up"),
+				dueDelivery(retried, 2, receiver.URL+"/down"),
+				dueDelivery(givenUp, webhookMaxAttempts-1, receiver.URL+"/down"),
+			},
+		}, nil
+	})
+	db.on("SetWebhookDeliveryResult", noRows)
+
+	start := time.Now()
+
+	err := deliverWebhooks(context.Background(), db.state("alice"))
+
+	if err != nil {
+		t.Fatalf("deliverWebhooks: %v", err)
+	}
+
+	for _, id := range []uuid.UUID{delivered, retried, givenUp} {
+		if !signatures[id.String()] {
+			t.Errorf("delivery %v was not received with a valid signature", id)
+		}
+	}
+
+	claims := db.called("ClaimDueWebhookDeliveries")
+
+	if claimedUntil := claims[0][0].(time.Time); claimedUntil.Before(start.Add(webhookClaimTimeout)) {
+		t.Errorf("deliveries claimed until %v, want at least %v", claimedUntil, start.Add(webhookClaimTimeout))
+	}
+
+	results := deliveryResults(t, db)
+
+	// id, attempts, status_code, last_error, delivered_at, next_attempt_at
+	if result := results[delivered.String()]; result[1] != int32(1) || result[2] != int64(200) || result[4] == nil || result[5] != nil {
+		t.Errorf("delivered result = %v, want 1 attempt, status 200, delivered and no next attempt", result)
+	}
+
+	result := results[retried.String()]
+
+	if result[1] != int32(3) || result[2] != int64(503) || result[3] != "receiver answered 503: down" || result[4] != nil {
+		t.Errorf("retried result = %v, want 3 attempts, status 503 and the error", result)
+	}
+
+	nextAttempt, ok := result[5].(time.Time)
+
+	if !ok || nextAttempt.Before(start.Add(webhook.Backoff(3))) || nextAttempt.After(time.Now().Add(webhook.Backoff(3))) {
+		t.Errorf("retried next attempt = %v, want %v from now", result[5], webhook.Backoff(3))
+	}
+
+	if result := results[givenUp.String()]; result[1] != int32(webhookMaxAttempts) || result[5] != nil {
+		t.Errorf("given up result = %v, want %d attempts and no next attempt", result, webhookMaxAttempts)
+	}
+}
+
+func TestDeliverWebhooksContinuesAfterRecordFailure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	first := uuid.New()
+	second := uuid.New()
+
+	db := newFakeDB(t)
+	db.on("ClaimDueWebhookDeliveries", func(args []driver.Value) (fakeRows, error) {
+		return fakeRows{
+			columns: dueDeliveryColumns,
+			rows: [][]driver.Value{
+				dueDelivery(first, 0, receiver.URL),
+				dueDelivery(second, 0, receiver.URL),
+			},
+		}, nil
+	})
+	db.on("SetWebhookDeliveryResult", func(args []driver.Value) (fakeRows, error) {
+		if args[0] == first.String() {
+			return fakeRows{}, io.ErrUnexpectedEOF
+		}
+
+		return fakeRows{}, nil
+	})
+
+	err := deliverWebhooks(context.Background(), db.state("alice"))
+
+	if err != nil {
+		t.Fatalf("deliverWebhooks: %v", err)
+	}
+
+	if calls := db.called("SetWebhookDeliveryResult"); len(calls) != 2 {
+		t.Errorf("recorded %d results, want 2", len(calls))
+	}
+}
+
+func TestDeliverWebhooksConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+
+		if inFlight == webhookWorkers {
+			close(release)
+		}
+		mu.Unlock()
+
+		select {
+		case <-release:
+		case <-time.After(2 * time.Second):
+		}
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	rows := [][]driver.Value{}
+
+	for range webhookWorkers * 2 {
+		rows = append(rows, dueDelivery(uuid.New(), 0, receiver.URL))
+	}
+
+	db := newFakeDB(t)
+	db.on("ClaimDueWebhookDeliveries", func(args []driver.Value) (fakeRows, error) {
+		return fakeRows{columns: dueDeliveryColumns, rows: rows}, nil
+	})
+	db.on("SetWebhookDeliveryResult", noRows)
+
+	err := deliverWebhooks(context.Background(), db.state("alice"))
+
+	if err != nil {
+		t.Fatalf("deliverWebhooks: %v", err)
+	}
+
+	if maxInFlight != webhookWorkers {
+		t.Errorf("%d deliveries were sent at once, want %d", maxInFlight, webhookWorkers)
+	}
+
+	if results := deliveryResults(t, db); len(results) != len(rows) {
+		t.Errorf("recorded %d results, want %d", len(results), len(rows))
+	}
+}
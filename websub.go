@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mambo-dev/gator/internal/database"
+	"github.com/mambo-dev/gator/internal/websub"
+)
+
+const (
+	// websubLease is the lease asked for, hubs may grant a shorter one.
+	websubLease = 10 * 24 * time.Hour
+	// websubRenewInterval is how often serve looks for subscriptions to
+	// make or renew.
+	websubRenewInterval = time.Minute
+	websubBatchSize     = 20
+	websubTimeout       = 30 * time.Second
+	// maxPushBody limits the size of feeds pushed by a hub.
+	maxPushBody = 10 << 20
+)
+
+// storeWebSubHub remembers the hub a fetched feed advertises, so that serve
+// can subscribe to it. A feed that stops advertising a hub is polled again.
+func storeWebSubHub(ctx context.Context, s *state, feedID uuid.UUID, feedUrl string, feeds *RSSFeed) {
+	hub, err := url.Parse(feeds.Hub)
+
+	if feeds.Hub == "" || err != nil || (hub.Scheme != "http" && hub.Scheme != "https") {
+		err = s.db.DeleteWebSubSubscription(ctx, feedID)
+
+		if err != nil {
+			log.Printf("Failed to remove websub subscription %v\n", err.Error())
+		}
+
+		return
+	}
+
+	topic := feeds.Self
+
+	if topic == "" {
+		topic = feedUrl
+	}
+
+	callbackToken, err := randomToken()
+
+	if err != nil {
+		log.Printf("Failed to generate websub token %v\n", err.Error())
+		return
+	}
+
+	secret, err := randomToken()
+
+	if err != nil {
+		log.Printf("Failed to generate websub secret %v\n", err.Error())
+		return
+	}
+
+	err = s.db.SetWebSubHub(ctx, database.SetWebSubHubParams{
+		ID:            uuid.New(),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		FeedID:        feedID,
+		HubUrl:        hub.String(),
+		TopicUrl:      topic,
+		CallbackToken: callbackToken,
+		Secret:        secret,
+	})
+
+	if err != nil {
+		log.Printf("Failed to store websub hub %v\n", err.Error())
+	}
+}
+
+func (srv *server) routeWebSub() {
+	srv.mux.HandleFunc("GET /websub/{token}", srv.websubVerify)
+	srv.mux.HandleFunc("POST /websub/{token}", srv.websubPush)
+}
+
+// renewWebSub subscribes to the hubs agg found, and renews leases a day
+// before they expire, until ctx is cancelled. Callbacks are made under
+// publicUrl, which hubs must be able to reach.
+func (srv *server) renewWebSub(ctx context.Context, publicUrl string) {
+	ticker := time.NewTicker(websubRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		err := srv.renewWebSubSubscriptions(ctx, publicUrl)
+
+		if err != nil {
+			log.Printf("Failed to renew websub subscriptions %v\n", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (srv *server) renewWebSubSubscriptions(ctx context.Context, publicUrl string) error {
+	subscriptions, err := srv.s.db.GetWebSubSubscriptionsToRenew(ctx, websubBatchSize)
+
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		// Some hubs verify before answering, so the subscription is
+		// pending before the request is sent.
+		err = srv.s.db.SetWebSubState(ctx, database.SetWebSubStateParams{
+			ID:    subscription.ID,
+			State: "pending",
+		})
+
+		if err != nil {
+			return err
+		}
+
+		requestCtx, cancel := context.WithTimeout(ctx, websubTimeout)
+
+		err = websub.Subscribe(requestCtx, http.DefaultClient, websub.Subscription{
+			Hub:      subscription.HubUrl,
+			Topic:    subscription.TopicUrl,
+			Callback: strings.TrimRight(publicUrl, "/") + "/websub/" + subscription.CallbackToken,
+			Secret:   subscription.Secret,
+			Lease:    websubLease,
+		})
+
+		cancel()
+
+		if err == nil {
+			continue
+		}
+
+		log.Printf("Failed to subscribe to %v at %v %v\n", subscription.TopicUrl, subscription.HubUrl, err.Error())
+
+		err = srv.s.db.SetWebSubState(ctx, database.SetWebSubStateParams{
+			ID:    subscription.ID,
+			State: "failed",
+			LastError: sql.NullString{
+				String: err.Error(),
+				Valid:  true,
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// websubVerify answers a hub checking that gator asked for a subscription
+// by echoing its challenge. gator never unsubscribes, so unsubscribe
+// requests are refused.
+func (srv *server) websubVerify(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	subscription, err := srv.s.db.GetWebSubSubscriptionByToken(r.Context(), r.PathValue("token"))
+
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && query.Get("hub.topic") != subscription.TopicUrl) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		webInternalError(w, "could not get subscription", err)
+		return
+	}
+
+	switch query.Get("hub.mode") {
+	case "subscribe":
+		lease, err := strconv.Atoi(query.Get("hub.lease_seconds"))
+
+		if err != nil || lease <= 0 {
+			lease = int(websubLease.Seconds())
+		}
+
+		err = srv.s.db.ActivateWebSubSubscription(r.Context(), database.ActivateWebSubSubscriptionParams{
+			LeaseSeconds: int32(lease),
+			ID:           subscription.ID,
+		})
+
+		if err != nil {
+			webInternalError(w, "could not activate subscription", err)
+			return
+		}
+
+		log.Printf("Subscribed to %v for %v\n", subscription.TopicUrl, time.Duration(lease)*time.Second)
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, query.Get("hub.challenge"))
+	case "denied":
+		err = srv.s.db.SetWebSubState(r.Context(), database.SetWebSubStateParams{
+			ID:    subscription.ID,
+			State: "denied",
+			LastError: sql.NullString{
+				String: "denied by hub: " + query.Get("hub.reason"),
+				Valid:  true,
+			},
+		})
+
+		if err != nil {
+			webInternalError(w, "could not update subscription", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	case "unsubscribe":
+		http.NotFound(w, r)
+	default:
+		http.Error(w, "unknown hub.mode", http.StatusBadRequest)
+	}
+}
+
+// websubPush takes new content from a hub and stores its items like a
+// fetch would. Content without a valid signature is acknowledged but
+// ignored, as the WebSub spec asks.
+func (srv *server) websubPush(w http.ResponseWriter, r *http.Request) {
+	subscription, err := srv.s.db.GetWebSubSubscriptionByToken(r.Context(), r.PathValue("token"))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		webInternalError(w, "could not get subscription", err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushBody))
+
+	if err != nil {
+		http.Error(w, "could not read content", http.StatusBadRequest)
+		return
+	}
+
+	if !websub.Verify(subscription.Secret, body, r.Header.Get(websub.SignatureHeader)) {
+		log.Printf("Ignoring push for %v with an invalid signature\n", subscription.TopicUrl)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	feeds, err := parseFeed(body)
+
+	if err != nil {
+		metricParseErrors.Inc(feedFormat(body))
+		http.Error(w, "could not parse feed", http.StatusBadRequest)
+		return
+	}
+
+	ingestFeed(r.Context(), srv.s, database.GetNextFeedToFetchRow{
+		ID:               subscription.FeedID,
+		Url:              subscription.FeedUrl,
+		FetchFullContent: subscription.FetchFullContent,
+		Name:             subscription.FeedName,
+		HasFavicon:       subscription.HasFavicon,
+	}, feeds)
+
+	err = srv.s.db.MarkWebSubPushed(r.Context(), subscription.ID)
+
+	if err != nil {
+		log.Printf("Failed to mark websub push %v\n", err.Error())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}